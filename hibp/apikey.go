@@ -0,0 +1,66 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// apiKeyHeader carries the API key on requests that have one.
+const apiKeyHeader = "hibp-api-key"
+
+// APIKeyFunc returns the API key to send with a request. It is called for
+// every request, so a key held in a secrets manager can be rotated without
+// recreating anything, and without disturbing requests already in flight.
+type APIKeyFunc func(ctx context.Context) (string, error)
+
+// WithAPIKey sends the given key with every request. The range API doesn't
+// need one, but gateways and mirrors in front of it may.
+func WithAPIKey(key string) func(f *Finder) {
+	return WithAPIKeyFunc(func(context.Context) (string, error) {
+		return key, nil
+	})
+}
+
+// WithAPIKeyFunc has every request ask fn for the API key to send. An error
+// from fn fails the request.
+func WithAPIKeyFunc(fn APIKeyFunc) func(f *Finder) {
+	return func(f *Finder) {
+		f.apiKey = fn
+	}
+}
+
+// setAPIKey adds the API key header to req, if fn provides a key.
+func setAPIKey(ctx context.Context, req *http.Request, fn APIKeyFunc) error {
+	if fn == nil {
+		return nil
+	}
+	key, err := fn(ctx)
+	if err != nil {
+		return fmt.Errorf("hibp: getting API key: %w", err)
+	}
+	if key != "" {
+		req.Header.Set(apiKeyHeader, key)
+	}
+	return nil
+}
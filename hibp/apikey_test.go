@@ -0,0 +1,114 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"context"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAPIKeyFunc(t *testing.T) {
+	var got string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get("hibp-api-key")
+		w.Write([]byte(data))
+	}))
+	defer ts.Close()
+
+	key := "first"
+	var keyErr error
+	f := NewFinder(
+		WithClient(ts.Client()),
+		WithURLTemplate(fmt.Sprintf("%s/%%s", ts.URL)),
+		WithAPIKeyFunc(func(ctx context.Context) (string, error) {
+			return key, keyErr
+		}),
+	)
+	h := sha1.Sum([]byte("melobie"))
+
+	for _, k := range []string{"first", "rotated"} {
+		key = k
+		if _, err := f.Find(h[:]); err != nil {
+			t.Fatalf("unexpected: %v\n", err)
+		}
+		if got != k {
+			t.Errorf("expected %q: %q\n", k, got)
+		}
+	}
+
+	keyErr = errors.New("vault sealed")
+	_, err := f.Find(h[:])
+	if !errors.Is(err, keyErr) {
+		t.Errorf("expected %v: %v\n", keyErr, err)
+	}
+}
+
+func TestAPIKey(t *testing.T) {
+	var got []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Values("hibp-api-key")
+		w.Write([]byte(data))
+	}))
+	defer ts.Close()
+
+	h := sha1.Sum([]byte("melobie"))
+	testCases := []struct {
+		name    string
+		options []func(*Finder)
+		exp     string
+	}{
+		{
+			"none",
+			nil,
+			"[]",
+		},
+		{
+			"static",
+			[]func(*Finder){WithAPIKey("s3cret")},
+			"[s3cret]",
+		},
+		{
+			"empty",
+			[]func(*Finder){WithAPIKey("")},
+			"[]",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			f := NewFinder(append([]func(*Finder){
+				WithClient(ts.Client()),
+				WithURLTemplate(fmt.Sprintf("%s/%%s", ts.URL)),
+			}, tc.options...)...)
+			if _, err := f.Find(h[:]); err != nil {
+				t.Fatalf("unexpected: %v\n", err)
+			}
+			if fmt.Sprint(got) != tc.exp {
+				t.Errorf("expected %s: %v\n", tc.exp, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// DefaultBaseURL is the root of the Pwned Passwords API; ranges are
+// fetched from below it, at "/range/" and the prefix. It is what
+// DefaultTemplate is built from.
+const DefaultBaseURL = "https://api.pwnedpasswords.com"
+
+// WithBaseURL has ranges fetched from below base, the root of a service
+// laid out like the Pwned Passwords API (see DefaultBaseURL), in place of
+// the DefaultTemplate. The path of base, if any, is kept, and any query is
+// sent with every request. It replaces WithURLTemplate, which builds URLs
+// with fmt.Sprintf, and so breaks on a template with a stray verb or a
+// percent-encoded character.
+//
+// base is checked when the Finder is created. It must be an absolute http
+// or https URL; if it isn't, every lookup that would use it fails with an
+// error saying why, rather than asking some other URL.
+func WithBaseURL(base string) func(f *Finder) {
+	return func(f *Finder) {
+		f.base, f.baseErr = parseBaseURL(base)
+	}
+}
+
+// parseBaseURL parses and checks a URL given to WithBaseURL.
+func parseBaseURL(base string) (*url.URL, error) {
+	u, err := url.Parse(base)
+	if err != nil {
+		return nil, fmt.Errorf("hibp: invalid base URL: %w", err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("hibp: invalid base URL %q: not an absolute http or https URL", base)
+	}
+	if u.Fragment != "" {
+		return nil, fmt.Errorf("hibp: invalid base URL %q: has a fragment", base)
+	}
+	return u, nil
+}
@@ -0,0 +1,137 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWithBaseURL(t *testing.T) {
+	var got string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.URL.RequestURI()
+		w.Write([]byte(data))
+	}))
+	defer ts.Close()
+
+	testCases := []struct {
+		name string
+		base string
+		mode HashMode
+		exp  string
+	}{
+		{
+			"root",
+			ts.URL,
+			SHA1,
+			"/range/5BAA6",
+		},
+		{
+			"trailing slash",
+			ts.URL + "/",
+			SHA1,
+			"/range/5BAA6",
+		},
+		{
+			"path",
+			ts.URL + "/mirror/v2/",
+			SHA1,
+			"/mirror/v2/range/5BAA6",
+		},
+		{
+			"percent in path",
+			ts.URL + "/100%25",
+			SHA1,
+			"/100%25/range/5BAA6",
+		},
+		{
+			"query",
+			ts.URL + "/mirror?token=abc",
+			SHA1,
+			"/mirror/range/5BAA6?token=abc",
+		},
+		{
+			"ntlm",
+			ts.URL,
+			NTLM,
+			"/range/8846F?mode=ntlm",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			f := NewFinder(
+				WithClient(ts.Client()),
+				WithBaseURL(tc.base),
+				WithHashMode(tc.mode),
+			)
+			if _, err := f.FindPassword("password"); err != nil {
+				t.Fatalf("unexpected: %v\n", err)
+			}
+			if got != tc.exp {
+				t.Errorf("expected %q: %q\n", tc.exp, got)
+			}
+		})
+	}
+}
+
+func TestWithBaseURLInvalid(t *testing.T) {
+	var calls int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+	}))
+	defer ts.Close()
+
+	for _, base := range []string{"", "api.pwnedpasswords.com", "ftp://example.com", "http://", "https://example.com/#frag", "http://[::1"} {
+		t.Run(base, func(t *testing.T) {
+			f := NewFinder(
+				WithClient(ts.Client()),
+				WithBaseURL(base),
+			)
+			_, err := f.FindPassword("melobie")
+			if err == nil || !strings.HasPrefix(err.Error(), "hibp: invalid base URL") {
+				t.Errorf("expected an invalid base URL: %v\n", err)
+			}
+		})
+	}
+	if calls != 0 {
+		t.Errorf("expected no requests: %d\n", calls)
+	}
+}
+
+func TestWithBaseURLOverrides(t *testing.T) {
+	f := NewFinder(WithBaseURL("not a url"), WithURLTemplate("https://example.com/r/%s"))
+	if u, err := f.rangeURL(SHA1, []byte("21BD1")); err != nil || u != "https://example.com/r/21BD1" {
+		t.Errorf("expected the template to win: %q %v\n", u, err)
+	}
+	f = NewFinder(WithURLTemplate("https://example.com/r/%s"), WithBaseURL("https://example.com/api"))
+	exp := "https://example.com/api/range/21BD1"
+	if u, err := f.rangeURL(SHA1, []byte("21BD1")); err != nil || u != exp {
+		t.Errorf("expected %q: %q %v\n", exp, u, err)
+	}
+	if exp := fmt.Sprintf(DefaultTemplate, "21BD1"); exp != "https://api.pwnedpasswords.com/range/21BD1" {
+		t.Errorf("unexpected default: %q\n", exp)
+	}
+}
@@ -0,0 +1,140 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"io"
+	"strings"
+)
+
+// ErrBlocklisted is returned by Find when the given hash is on the Finder's
+// local Blocklist. No request is made upstream in that case.
+var ErrBlocklisted = errors.New("hibp: password is blocklisted")
+
+// Blocklist is a set of locally banned passwords, such as company or
+// product name variants, that should be rejected regardless of whether they
+// show up in the breach corpus. (NIST SP 800-63B asks for both kinds of
+// screening.)
+//
+// Only SHA1 and NTLM hashes are retained, never the plaintext entries.
+type Blocklist struct {
+	sums map[[sha1.Size]byte]struct{}
+	// ntlm maps the NTLM hash of each entry to whether it came from a
+	// plaintext password, and so is also in sums.
+	ntlm map[[ntlmSize]byte]bool
+}
+
+// NewBlocklist returns a Blocklist containing the given entries. Each entry
+// is either a 40 digit hex SHA1, a 32 digit hex NTLM hash (in either case),
+// or a plaintext password. A plaintext password is blocked in both hash
+// modes, a hash only in its own.
+func NewBlocklist(entries ...string) *Blocklist {
+	b := &Blocklist{
+		sums: make(map[[sha1.Size]byte]struct{}, len(entries)),
+		ntlm: make(map[[ntlmSize]byte]bool, len(entries)),
+	}
+	for _, e := range entries {
+		b.add(e)
+	}
+	return b
+}
+
+// LoadBlocklist reads a Blocklist with one entry per line, in the same form
+// accepted by NewBlocklist. Blank lines and lines starting with "#" are
+// skipped; surrounding whitespace is trimmed.
+func LoadBlocklist(r io.Reader) (*Blocklist, error) {
+	b := NewBlocklist()
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		b.add(line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (b *Blocklist) add(entry string) {
+	switch len(entry) {
+	case hex.EncodedLen(sha1.Size):
+		var key [sha1.Size]byte
+		if _, err := hex.Decode(key[:], []byte(entry)); err == nil {
+			b.sums[key] = struct{}{}
+			return
+		}
+	case hex.EncodedLen(ntlmSize):
+		var key [ntlmSize]byte
+		if _, err := hex.Decode(key[:], []byte(entry)); err == nil {
+			if !b.ntlm[key] {
+				b.ntlm[key] = false
+			}
+			return
+		}
+	}
+	b.sums[sha1.Sum([]byte(entry))] = struct{}{}
+	b.ntlm[ntlmSum(entry)] = true
+}
+
+// Contains reports whether the 20 byte output of a sha1.Sum(), or a 16
+// byte NTLM hash, is on the Blocklist.
+func (b *Blocklist) Contains(sum []byte) bool {
+	switch len(sum) {
+	case sha1.Size:
+		var key [sha1.Size]byte
+		copy(key[:], sum)
+		_, ok := b.sums[key]
+		return ok
+	case ntlmSize:
+		var key [ntlmSize]byte
+		copy(key[:], sum)
+		_, ok := b.ntlm[key]
+		return ok
+	}
+	return false
+}
+
+// Len returns the number of distinct entries on the Blocklist.
+func (b *Blocklist) Len() int {
+	n := len(b.sums)
+	for _, plain := range b.ntlm {
+		if !plain {
+			n++
+		}
+	}
+	return n
+}
+
+// WithBlocklist has Find check the given Blocklist before going to the
+// network, returning ErrBlocklisted for any match. It is consulted in
+// either hash mode.
+func WithBlocklist(b *Blocklist) func(f *Finder) {
+	return func(f *Finder) {
+		f.block = b
+	}
+}
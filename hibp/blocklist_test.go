@@ -0,0 +1,153 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const blocklistContent = `
+# company name variants
+Acme2017
+  acme-corp  
+
+# "password", pre-hashed in lower case
+5baa61e4c9b93f3f0682250b6cf8331b7ee68fd8
+`
+
+func TestBlocklist(t *testing.T) {
+	b, err := LoadBlocklist(strings.NewReader(blocklistContent))
+	if err != nil {
+		t.Fatalf("unexpected: %v\n", err)
+	}
+	if b.Len() != 3 {
+		t.Errorf("expected 3 entries: %d\n", b.Len())
+	}
+
+	testCases := []struct {
+		pwd string
+		exp bool
+	}{
+		{"Acme2017", true},
+		{"acme-corp", true},
+		{"password", true},
+		{"acme2017", false},
+		{"# company name variants", false},
+		{"5baa61e4c9b93f3f0682250b6cf8331b7ee68fd8", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.pwd, func(t *testing.T) {
+			h := sha1.Sum([]byte(tc.pwd))
+			if got := b.Contains(h[:]); got != tc.exp {
+				t.Errorf("expected %t: %t\n", tc.exp, got)
+			}
+		})
+	}
+
+	if b.Contains([]byte("short")) {
+		t.Errorf("expected short input to be absent")
+	}
+}
+
+func TestFindBlocklisted(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(data))
+	}))
+	defer ts.Close()
+
+	f := NewFinder(
+		WithClient(ts.Client()),
+		WithURLTemplate(fmt.Sprintf("%s/%%s", ts.URL)),
+		WithBlocklist(NewBlocklist("melobie")),
+	)
+
+	h := sha1.Sum([]byte("melobie"))
+	n, err := f.Find(h[:])
+	if n != 0 || err != ErrBlocklisted {
+		t.Errorf("expected [0, %v]: %d, %v\n", ErrBlocklisted, n, err)
+	}
+
+	h = sha1.Sum([]byte("lauragpe"))
+	n, err = f.Find(h[:])
+	if n != 229 || err != nil {
+		t.Errorf("expected [229, nil]: %d, %v\n", n, err)
+	}
+}
+
+func TestBlocklistNTLM(t *testing.T) {
+	// "password" as an NTLM hash, and "melobie" both ways.
+	b := NewBlocklist("8846F7EAEE8FB117AD06BDD830B7586C", "melobie", "melobie")
+	if b.Len() != 2 {
+		t.Errorf("expected 2 entries: %d\n", b.Len())
+	}
+
+	testCases := []struct {
+		pwd   string
+		exSHA bool
+		exNT  bool
+	}{
+		{"password", false, true},
+		{"melobie", true, true},
+		{"lauragpe", false, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.pwd, func(t *testing.T) {
+			h := sha1.Sum([]byte(tc.pwd))
+			if got := b.Contains(h[:]); got != tc.exSHA {
+				t.Errorf("expected SHA1 %t: %t\n", tc.exSHA, got)
+			}
+			nt := ntlmSum(tc.pwd)
+			if got := b.Contains(nt[:]); got != tc.exNT {
+				t.Errorf("expected NTLM %t: %t\n", tc.exNT, got)
+			}
+		})
+	}
+}
+
+func TestFindBlocklistedNTLM(t *testing.T) {
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer ts.Close()
+
+	f := NewFinder(
+		WithClient(ts.Client()),
+		WithURLTemplate(fmt.Sprintf("%s/%%s", ts.URL)),
+		WithHashMode(NTLM),
+		WithBlocklist(NewBlocklist("melobie")),
+	)
+
+	n, err := f.FindPassword("melobie")
+	if n != 0 || err != ErrBlocklisted {
+		t.Errorf("expected [0, %v]: %d, %v\n", ErrBlocklisted, n, err)
+	}
+	if requests != 0 {
+		t.Errorf("expected no requests: %d\n", requests)
+	}
+}
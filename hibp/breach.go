@@ -0,0 +1,377 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultAPIBaseURL is the root of version 3 of the Have I Been Pwned API.
+const DefaultAPIBaseURL = "https://haveibeenpwned.com/api/v3"
+
+// breachDateLayout is the layout of the BreachDate of a Breach.
+const breachDateLayout = "2006-01-02"
+
+// renewalLayout is the zone-less layout of a NextSubscriptionRenewal.
+const renewalLayout = "2006-01-02T15:04:05"
+
+// ErrNotFound is returned when the thing asked for doesn't exist, such as
+// by GetBreach for an unknown breach name. It is never returned for a
+// transport failure.
+var ErrNotFound = errors.New("hibp: not found")
+
+// NewBreachClient returns a new BreachClient, set up with the options
+// provided.
+//
+// See more here: https://haveibeenpwned.com/API/v3
+func NewBreachClient(options ...func(*BreachClient)) *BreachClient {
+	c := &BreachClient{
+		base: DefaultAPIBaseURL,
+		conn: defaultClient,
+	}
+	for _, opt := range options {
+		opt(c)
+	}
+	return c
+}
+
+// WithBreachBaseURL replaces DefaultAPIBaseURL as the root of the API.
+func WithBreachBaseURL(base string) func(c *BreachClient) {
+	return func(c *BreachClient) {
+		c.base = strings.TrimSuffix(base, "/")
+	}
+}
+
+// WithBreachHTTPClient replaces the default client, which has a timeout
+// of DefaultTimeout.
+func WithBreachHTTPClient(client *http.Client) func(c *BreachClient) {
+	return func(c *BreachClient) {
+		c.conn = client
+	}
+}
+
+// WithBreachAPIKey sends the given key with every request. Most account
+// level endpoints require one.
+func WithBreachAPIKey(key string) func(c *BreachClient) {
+	return WithBreachAPIKeyFunc(func(context.Context) (string, error) {
+		return key, nil
+	})
+}
+
+// WithBreachAPIKeyFunc has every request ask fn for the API key to send.
+// (See APIKeyFunc.)
+func WithBreachAPIKeyFunc(fn APIKeyFunc) func(c *BreachClient) {
+	return func(c *BreachClient) {
+		c.apiKey = fn
+	}
+}
+
+// WithBreachUserAgent replaces DefaultUserAgent as the User-Agent sent.
+// The API rejects requests without a descriptive one, so name the
+// calling application, such as "acme-audit/1.2".
+func WithBreachUserAgent(ua string) func(c *BreachClient) {
+	return func(c *BreachClient) {
+		c.userAgent = ua
+	}
+}
+
+// BreachClient looks up breaches of accounts, and the breaches themselves.
+type BreachClient struct {
+	base      string
+	conn      *http.Client
+	apiKey    APIKeyFunc
+	userAgent string
+}
+
+// Breach describes a single breach loaded into Have I Been Pwned.
+type Breach struct {
+	Name         string
+	Title        string
+	Domain       string
+	BreachDate   time.Time
+	AddedDate    time.Time
+	ModifiedDate time.Time
+	PwnCount     int64
+	Description  string
+	LogoPath     string
+	DataClasses  []string
+
+	IsVerified         bool
+	IsFabricated       bool
+	IsSensitive        bool
+	IsRetired          bool
+	IsSpamList         bool
+	IsMalware          bool
+	IsSubscriptionFree bool
+	IsStealerLog       bool
+}
+
+// UnmarshalJSON decodes a breach as returned by the API, where BreachDate
+// is a plain date rather than a timestamp.
+func (b *Breach) UnmarshalJSON(data []byte) error {
+	type plain Breach
+	var aux struct {
+		plain
+		BreachDate string
+	}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	*b = Breach(aux.plain)
+	if aux.BreachDate != "" {
+		t, err := time.Parse(breachDateLayout, aux.BreachDate)
+		if err != nil {
+			return fmt.Errorf("hibp: breach date: %v", err)
+		}
+		b.BreachDate = t
+	}
+	return nil
+}
+
+// Paste is a publicly posted dump, such as on Pastebin, that an account
+// appeared in.
+type Paste struct {
+	// Source is the site the paste was posted to.
+	Source string
+	// ID is the paste's identifier at the Source.
+	ID string `json:"Id"`
+	// Title is empty if the paste had none.
+	Title string
+	// Date is zero if the time of posting is unknown.
+	Date time.Time
+	// EmailCount is how many email addresses the paste contained.
+	EmailCount int
+}
+
+// SubscribedDomain is a domain verified with the API key's subscription.
+type SubscribedDomain struct {
+	DomainName string
+	// PwnCount is the number of breached addresses at the last search;
+	// zero if the domain hasn't been searched yet.
+	PwnCount int64
+	// PwnCountExcludingSpamLists is PwnCount, not counting spam lists.
+	PwnCountExcludingSpamLists int64
+	// PwnCountExcludingSpamListsAtLastSubscriptionRenewal is the same
+	// figure as of the last renewal, which determines the subscription
+	// level the domain needs.
+	PwnCountExcludingSpamListsAtLastSubscriptionRenewal int64
+	// NextSubscriptionRenewal is zero if the subscription doesn't renew.
+	NextSubscriptionRenewal time.Time
+}
+
+// UnmarshalJSON decodes a subscribed domain as returned by the API, where
+// NextSubscriptionRenewal carries no time zone and is in UTC.
+func (d *SubscribedDomain) UnmarshalJSON(data []byte) error {
+	type plain SubscribedDomain
+	var aux struct {
+		plain
+		NextSubscriptionRenewal string
+	}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	*d = SubscribedDomain(aux.plain)
+	if s := aux.NextSubscriptionRenewal; s != "" {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			t, err = time.Parse(renewalLayout, s)
+		}
+		if err != nil {
+			return fmt.Errorf("hibp: subscription renewal: %v", err)
+		}
+		d.NextSubscriptionRenewal = t
+	}
+	return nil
+}
+
+// AccountOptions refine GetAccountBreaches. The zero value asks for the
+// API's defaults.
+type AccountOptions struct {
+	// Full asks for every attribute of each Breach, rather than just the
+	// Name.
+	Full bool
+	// Domain limits results to breaches of this domain.
+	Domain string
+	// ExcludeUnverified leaves out breaches that are not verified.
+	ExcludeUnverified bool
+}
+
+func (o AccountOptions) query() url.Values {
+	q := url.Values{}
+	if o.Full {
+		q.Set("truncateResponse", "false")
+	}
+	if o.Domain != "" {
+		q.Set("domain", o.Domain)
+	}
+	if o.ExcludeUnverified {
+		q.Set("includeUnverified", "false")
+	}
+	return q
+}
+
+// GetAccountBreaches returns the breaches the given account (usually an
+// email address) appears in. An account that isn't in any breach returns no
+// breaches and no error.
+func (c *BreachClient) GetAccountBreaches(ctx context.Context, account string, opts AccountOptions) ([]Breach, error) {
+	var breaches []Breach
+	err := c.get(ctx, "/breachedaccount/"+url.PathEscape(account), opts.query(), &breaches)
+	if err == ErrNotFound {
+		return nil, nil
+	}
+	return breaches, err
+}
+
+// GetBreaches returns every breach in the system. If domain isn't empty,
+// only breaches of that domain are returned. No API key is needed.
+func (c *BreachClient) GetBreaches(ctx context.Context, domain string) ([]Breach, error) {
+	q := url.Values{}
+	if domain != "" {
+		q.Set("domain", domain)
+	}
+	var breaches []Breach
+	if err := c.get(ctx, "/breaches", q, &breaches); err != nil {
+		return nil, err
+	}
+	return breaches, nil
+}
+
+// GetBreach returns the breach with the given name, or ErrNotFound if there
+// is no such breach.
+func (c *BreachClient) GetBreach(ctx context.Context, name string) (*Breach, error) {
+	var b Breach
+	if err := c.get(ctx, "/breach/"+url.PathEscape(name), nil, &b); err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// DataClasses returns the names of all the kinds of data (such as "Email
+// addresses" or "Passwords") that breaches are classified by.
+func (c *BreachClient) DataClasses(ctx context.Context) ([]string, error) {
+	var classes []string
+	if err := c.get(ctx, "/dataclasses", nil, &classes); err != nil {
+		return nil, err
+	}
+	return classes, nil
+}
+
+// GetAccountPastes returns the pastes the given email address appears in.
+// An address that isn't in any paste returns no pastes and no error.
+func (c *BreachClient) GetAccountPastes(ctx context.Context, account string) ([]Paste, error) {
+	var pastes []Paste
+	err := c.get(ctx, "/pasteaccount/"+url.PathEscape(account), nil, &pastes)
+	if err == ErrNotFound {
+		return nil, nil
+	}
+	return pastes, err
+}
+
+// LatestBreach returns the breach most recently added to the system, which
+// makes it a cheap thing to poll for new breaches.
+func (c *BreachClient) LatestBreach(ctx context.Context) (*Breach, error) {
+	var b Breach
+	if err := c.get(ctx, "/latestbreach", nil, &b); err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// DomainSearch returns the breached email addresses of a domain verified
+// with the API key's subscription, as a map of each alias (the part before
+// the "@") to the names of the breaches it appears in. A domain without
+// breached addresses returns an empty map and no error.
+func (c *BreachClient) DomainSearch(ctx context.Context, domain string) (map[string][]string, error) {
+	aliases := map[string][]string{}
+	err := c.get(ctx, "/breacheddomain/"+url.PathEscape(domain), nil, &aliases)
+	if err == ErrNotFound {
+		return map[string][]string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return aliases, nil
+}
+
+// SubscribedDomains returns the domains verified with the API key's
+// subscription.
+func (c *BreachClient) SubscribedDomains(ctx context.Context) ([]SubscribedDomain, error) {
+	var domains []SubscribedDomain
+	if err := c.get(ctx, "/subscribeddomains", nil, &domains); err != nil {
+		return nil, err
+	}
+	return domains, nil
+}
+
+// StealerLogsByWebsiteDomain returns the email addresses that stealer logs
+// captured entering credentials on the website at domain. It needs a
+// subscription that includes stealer logs. A domain with no exposed
+// addresses returns nil and no error.
+func (c *BreachClient) StealerLogsByWebsiteDomain(ctx context.Context, domain string) ([]string, error) {
+	var emails []string
+	err := c.get(ctx, "/stealerlogsbywebsitedomain/"+url.PathEscape(domain), nil, &emails)
+	if err == ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return emails, nil
+}
+
+// get fetches the JSON at path below the base URL and decodes it into v.
+func (c *BreachClient) get(ctx context.Context, path string, query url.Values, v interface{}) error {
+	u := c.base + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	setUserAgent(ctx, req, c.userAgent)
+	if err := setAPIKey(ctx, req, c.apiKey); err != nil {
+		return err
+	}
+	resp, err := c.conn.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return &HTTPError{StatusCode: resp.StatusCode, Status: resp.Status, Attempts: 1}
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("hibp: decoding response: %v", err)
+	}
+	return nil
+}
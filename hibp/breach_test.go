@@ -0,0 +1,404 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const breachesJSON = `[
+  {
+    "Name": "Adobe",
+    "Title": "Adobe",
+    "Domain": "adobe.com",
+    "BreachDate": "2013-10-04",
+    "AddedDate": "2013-12-04T00:00:00Z",
+    "ModifiedDate": "2022-05-15T23:52:49Z",
+    "PwnCount": 152445165,
+    "Description": "In October 2013, 153 million Adobe accounts were breached.",
+    "LogoPath": "https://haveibeenpwned.com/Content/Images/PwnedLogos/Adobe.png",
+    "DataClasses": ["Email addresses", "Password hints", "Passwords", "Usernames"],
+    "IsVerified": true,
+    "IsFabricated": false,
+    "IsSensitive": false,
+    "IsRetired": false,
+    "IsSpamList": false,
+    "IsMalware": false,
+    "IsSubscriptionFree": false
+  }
+]`
+
+// newBreachServer serves body for path, and a 404 for everything else. It
+// also checks the headers every request should carry.
+func newBreachServer(t *testing.T, path, body string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ua := r.Header.Get("User-Agent"); ua != DefaultUserAgent {
+			t.Errorf("expected %q: %q\n", DefaultUserAgent, ua)
+		}
+		if r.URL.EscapedPath() != path {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}))
+}
+
+func TestGetAccountBreaches(t *testing.T) {
+	var query string
+	var key string
+	ts := newBreachServer(t, "/breachedaccount/test@example.com", breachesJSON)
+	ts.Config.Handler = func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			query = r.URL.RawQuery
+			key = r.Header.Get("hibp-api-key")
+			next.ServeHTTP(w, r)
+		})
+	}(ts.Config.Handler)
+	defer ts.Close()
+
+	c := NewBreachClient(
+		WithBreachHTTPClient(ts.Client()),
+		WithBreachBaseURL(ts.URL+"/"),
+		WithBreachAPIKey("s3cret"),
+	)
+
+	breaches, err := c.GetAccountBreaches(context.Background(), "test@example.com", AccountOptions{
+		Full:              true,
+		Domain:            "adobe.com",
+		ExcludeUnverified: true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected: %v\n", err)
+	}
+	if key != "s3cret" {
+		t.Errorf("expected key: %q\n", key)
+	}
+	if exp := "domain=adobe.com&includeUnverified=false&truncateResponse=false"; query != exp {
+		t.Errorf("expected %q: %q\n", exp, query)
+	}
+	if len(breaches) != 1 {
+		t.Fatalf("expected 1 breach: %d\n", len(breaches))
+	}
+	b := breaches[0]
+	if b.Name != "Adobe" || b.PwnCount != 152445165 || !b.IsVerified || len(b.DataClasses) != 4 {
+		t.Errorf("unexpected: %+v\n", b)
+	}
+	if exp := time.Date(2013, 10, 4, 0, 0, 0, 0, time.UTC); !b.BreachDate.Equal(exp) {
+		t.Errorf("expected %v: %v\n", exp, b.BreachDate)
+	}
+	if exp := time.Date(2013, 12, 4, 0, 0, 0, 0, time.UTC); !b.AddedDate.Equal(exp) {
+		t.Errorf("expected %v: %v\n", exp, b.AddedDate)
+	}
+
+	// Accounts without breaches are a 404.
+	breaches, err = c.GetAccountBreaches(context.Background(), "clean@example.com", AccountOptions{})
+	if breaches != nil || err != nil {
+		t.Errorf("expected [nil, nil]: %v, %v\n", breaches, err)
+	}
+	if query != "" {
+		t.Errorf("expected no query: %q\n", query)
+	}
+}
+
+func TestGetAccountBreachesErrors(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("domain") {
+		case "throttled":
+			w.WriteHeader(http.StatusTooManyRequests)
+		case "unauthorized":
+			w.WriteHeader(http.StatusUnauthorized)
+		default:
+			w.Write([]byte(`[{"Name": "Adobe", "BreachDate": "last week"}]`))
+		}
+	}))
+	defer ts.Close()
+
+	c := NewBreachClient(
+		WithBreachHTTPClient(ts.Client()),
+		WithBreachBaseURL(ts.URL),
+	)
+
+	testCases := []struct {
+		domain string
+		code   ErrorCode
+	}{
+		{"throttled", CodeThrottled},
+		{"unauthorized", CodeBackendDown},
+		{"bad-date", CodeUnknown},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.domain, func(t *testing.T) {
+			_, err := c.GetAccountBreaches(context.Background(), "test@example.com", AccountOptions{Domain: tc.domain})
+			if err == nil || Classify(err) != tc.code {
+				t.Errorf("expected %q: %v\n", tc.code, err)
+			}
+		})
+	}
+}
+
+func TestGetBreaches(t *testing.T) {
+	var query string
+	ts := newBreachServer(t, "/breaches", breachesJSON)
+	ts.Config.Handler = func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			query = r.URL.RawQuery
+			next.ServeHTTP(w, r)
+		})
+	}(ts.Config.Handler)
+	defer ts.Close()
+
+	c := NewBreachClient(
+		WithBreachHTTPClient(ts.Client()),
+		WithBreachBaseURL(ts.URL),
+	)
+
+	testCases := []struct {
+		domain string
+		query  string
+	}{
+		{"", ""},
+		{"adobe.com", "domain=adobe.com"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.domain, func(t *testing.T) {
+			breaches, err := c.GetBreaches(context.Background(), tc.domain)
+			if err != nil {
+				t.Fatalf("unexpected: %v\n", err)
+			}
+			if query != tc.query {
+				t.Errorf("expected %q: %q\n", tc.query, query)
+			}
+			if len(breaches) != 1 || breaches[0].Domain != "adobe.com" {
+				t.Errorf("unexpected: %+v\n", breaches)
+			}
+			if exp := time.Date(2022, 5, 15, 23, 52, 49, 0, time.UTC); !breaches[0].ModifiedDate.Equal(exp) {
+				t.Errorf("expected %v: %v\n", exp, breaches[0].ModifiedDate)
+			}
+		})
+	}
+}
+
+func TestGetBreach(t *testing.T) {
+	single := strings.TrimSuffix(strings.TrimPrefix(breachesJSON, "["), "]")
+	ts := newBreachServer(t, "/breach/Adobe", single)
+	defer ts.Close()
+
+	c := NewBreachClient(
+		WithBreachHTTPClient(ts.Client()),
+		WithBreachBaseURL(ts.URL),
+	)
+
+	b, err := c.GetBreach(context.Background(), "Adobe")
+	if err != nil {
+		t.Fatalf("unexpected: %v\n", err)
+	}
+	if b.Name != "Adobe" || b.PwnCount != 152445165 {
+		t.Errorf("unexpected: %+v\n", b)
+	}
+
+	b, err = c.GetBreach(context.Background(), "NoSuchBreach")
+	if b != nil || err != ErrNotFound {
+		t.Errorf("expected [nil, %v]: %v, %v\n", ErrNotFound, b, err)
+	}
+
+	ts.Close()
+	_, err = c.GetBreach(context.Background(), "Adobe")
+	if err == nil || err == ErrNotFound {
+		t.Errorf("expected a transport error: %v\n", err)
+	}
+}
+
+func TestDataClasses(t *testing.T) {
+	ts := newBreachServer(t, "/dataclasses", `["Account balances","Email addresses","Passwords"]`)
+	defer ts.Close()
+
+	c := NewBreachClient(
+		WithBreachHTTPClient(ts.Client()),
+		WithBreachBaseURL(ts.URL),
+	)
+	classes, err := c.DataClasses(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected: %v\n", err)
+	}
+	if exp := "[Account balances Email addresses Passwords]"; fmt.Sprint(classes) != exp {
+		t.Errorf("expected %s: %v\n", exp, classes)
+	}
+}
+
+const pastesJSON = `[
+  {"Source": "Pastebin", "Id": "8Q0BvKD8", "Title": "syslog", "Date": "2014-03-04T19:14:54Z", "EmailCount": 139},
+  {"Source": "Pastie", "Id": "7152479", "Title": null, "Date": null, "EmailCount": 30}
+]`
+
+func TestGetAccountPastes(t *testing.T) {
+	ts := newBreachServer(t, "/pasteaccount/test@example.com", pastesJSON)
+	defer ts.Close()
+
+	c := NewBreachClient(
+		WithBreachHTTPClient(ts.Client()),
+		WithBreachBaseURL(ts.URL),
+	)
+
+	pastes, err := c.GetAccountPastes(context.Background(), "test@example.com")
+	if err != nil {
+		t.Fatalf("unexpected: %v\n", err)
+	}
+	if len(pastes) != 2 {
+		t.Fatalf("expected 2 pastes: %d\n", len(pastes))
+	}
+	p := pastes[0]
+	if p.Source != "Pastebin" || p.ID != "8Q0BvKD8" || p.Title != "syslog" || p.EmailCount != 139 {
+		t.Errorf("unexpected: %+v\n", p)
+	}
+	if exp := time.Date(2014, 3, 4, 19, 14, 54, 0, time.UTC); !p.Date.Equal(exp) {
+		t.Errorf("expected %v: %v\n", exp, p.Date)
+	}
+	if p = pastes[1]; p.Title != "" || !p.Date.IsZero() {
+		t.Errorf("expected empty title and date: %+v\n", p)
+	}
+
+	pastes, err = c.GetAccountPastes(context.Background(), "clean@example.com")
+	if pastes != nil || err != nil {
+		t.Errorf("expected [nil, nil]: %v, %v\n", pastes, err)
+	}
+}
+
+func TestLatestBreach(t *testing.T) {
+	single := strings.TrimSuffix(strings.TrimPrefix(breachesJSON, "["), "]")
+	ts := newBreachServer(t, "/latestbreach", single)
+	defer ts.Close()
+
+	c := NewBreachClient(
+		WithBreachHTTPClient(ts.Client()),
+		WithBreachBaseURL(ts.URL),
+	)
+
+	b, err := c.LatestBreach(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected: %v\n", err)
+	}
+	if b.Name != "Adobe" {
+		t.Errorf("unexpected: %+v\n", b)
+	}
+}
+
+func TestDomainSearch(t *testing.T) {
+	ts := newBreachServer(t, "/breacheddomain/example.com", `{"alias1": ["Adobe"], "alias2": ["Adobe", "Gawker", "Stratfor"]}`)
+	defer ts.Close()
+
+	c := NewBreachClient(
+		WithBreachHTTPClient(ts.Client()),
+		WithBreachBaseURL(ts.URL),
+		WithBreachAPIKey("s3cret"),
+	)
+
+	aliases, err := c.DomainSearch(context.Background(), "example.com")
+	if err != nil {
+		t.Fatalf("unexpected: %v\n", err)
+	}
+	if exp := "map[alias1:[Adobe] alias2:[Adobe Gawker Stratfor]]"; fmt.Sprint(aliases) != exp {
+		t.Errorf("expected %s: %v\n", exp, aliases)
+	}
+
+	aliases, err = c.DomainSearch(context.Background(), "clean.example.com")
+	if err != nil || aliases == nil || len(aliases) != 0 {
+		t.Errorf("expected [map[], nil]: %v, %v\n", aliases, err)
+	}
+}
+
+const subscribedDomainsJSON = `[
+  {
+    "DomainName": "example.com",
+    "PwnCount": 107,
+    "PwnCountExcludingSpamLists": 95,
+    "PwnCountExcludingSpamListsAtLastSubscriptionRenewal": 90,
+    "NextSubscriptionRenewal": "2026-11-01T00:00:00"
+  },
+  {
+    "DomainName": "new.example.com",
+    "PwnCount": null,
+    "PwnCountExcludingSpamLists": null,
+    "PwnCountExcludingSpamListsAtLastSubscriptionRenewal": null,
+    "NextSubscriptionRenewal": null
+  }
+]`
+
+func TestSubscribedDomains(t *testing.T) {
+	ts := newBreachServer(t, "/subscribeddomains", subscribedDomainsJSON)
+	defer ts.Close()
+
+	c := NewBreachClient(
+		WithBreachHTTPClient(ts.Client()),
+		WithBreachBaseURL(ts.URL),
+		WithBreachAPIKey("s3cret"),
+	)
+
+	domains, err := c.SubscribedDomains(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected: %v\n", err)
+	}
+	if len(domains) != 2 {
+		t.Fatalf("expected 2 domains: %d\n", len(domains))
+	}
+	d := domains[0]
+	if d.DomainName != "example.com" || d.PwnCount != 107 || d.PwnCountExcludingSpamLists != 95 || d.PwnCountExcludingSpamListsAtLastSubscriptionRenewal != 90 {
+		t.Errorf("unexpected: %+v\n", d)
+	}
+	if exp := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC); !d.NextSubscriptionRenewal.Equal(exp) {
+		t.Errorf("expected %v: %v\n", exp, d.NextSubscriptionRenewal)
+	}
+	if d = domains[1]; d.PwnCount != 0 || !d.NextSubscriptionRenewal.IsZero() {
+		t.Errorf("expected zero values: %+v\n", d)
+	}
+}
+
+func TestStealerLogsByWebsiteDomain(t *testing.T) {
+	ts := newBreachServer(t, "/stealerlogsbywebsitedomain/example.com", `["andy@gmail.com", "jane@gmail.com"]`)
+	defer ts.Close()
+
+	c := NewBreachClient(
+		WithBreachHTTPClient(ts.Client()),
+		WithBreachBaseURL(ts.URL),
+		WithBreachAPIKey("s3cret"),
+	)
+
+	emails, err := c.StealerLogsByWebsiteDomain(context.Background(), "example.com")
+	if err != nil {
+		t.Fatalf("unexpected: %v\n", err)
+	}
+	if exp := "[andy@gmail.com jane@gmail.com]"; fmt.Sprint(emails) != exp {
+		t.Errorf("expected %s: %v\n", exp, emails)
+	}
+
+	emails, err = c.StealerLogsByWebsiteDomain(context.Background(), "clean.example.com")
+	if err != nil || emails != nil {
+		t.Errorf("expected [nil, nil]: %v, %v\n", emails, err)
+	}
+}
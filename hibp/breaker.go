@@ -0,0 +1,161 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned, without contacting the backend, while the
+// circuit breaker is open; see WithCircuitBreaker.
+var ErrCircuitOpen = errors.New("hibp: circuit breaker open")
+
+// Defaults for the zero fields of a BreakerPolicy.
+const (
+	DefaultBreakerWindow      = 20
+	DefaultBreakerFailureRate = 0.5
+	DefaultBreakerCooldown    = 30 * time.Second
+)
+
+// BreakerPolicy configures WithCircuitBreaker.
+type BreakerPolicy struct {
+	// Window is how many of the most recent range requests the failure
+	// rate is taken over; zero means DefaultBreakerWindow.
+	Window int
+	// FailureRate is the share of failures in a full Window that opens
+	// the breaker; zero means DefaultBreakerFailureRate.
+	FailureRate float64
+	// Cooldown is how long the breaker stays open before a single trial
+	// request is let through; zero means DefaultBreakerCooldown.
+	Cooldown time.Duration
+}
+
+// WithCircuitBreaker stops range requests from piling up behind a backend
+// that is down. Once the share of failed requests (those RetryableError
+// accepts: server errors, throttling and network failures) among the last
+// Window reaches FailureRate, the breaker opens, and lookups fail fast with
+// ErrCircuitOpen. After Cooldown, one request is let through: if it
+// succeeds the breaker closes again, otherwise it stays open for another
+// Cooldown.
+//
+// ErrCircuitOpen is a backend failure like any other, so it is subject to
+// the DegradedMode; with AllowOnError, lookups pass while the breaker is
+// open.
+func WithCircuitBreaker(policy BreakerPolicy) func(f *Finder) {
+	return func(f *Finder) {
+		if policy.Window <= 0 {
+			policy.Window = DefaultBreakerWindow
+		}
+		if policy.FailureRate <= 0 {
+			policy.FailureRate = DefaultBreakerFailureRate
+		}
+		if policy.Cooldown <= 0 {
+			policy.Cooldown = DefaultBreakerCooldown
+		}
+		f.breaker = &breaker{policy: policy, outcomes: make([]bool, policy.Window)}
+	}
+}
+
+// breaker is a circuit breaker over a sliding window of outcomes.
+type breaker struct {
+	policy BreakerPolicy
+	clock  Clock
+
+	mu       sync.Mutex // guards the fields below
+	outcomes []bool     // ring of the last requests; true for a failure
+	next     int
+	count    int
+	failures int
+	open     bool
+	openedAt time.Time
+	trial    bool   // a trial request is in flight
+	gen      uint64 // advanced whenever the breaker opens, tries or closes
+}
+
+// allow returns ErrCircuitOpen if no request should be made now. Otherwise
+// it returns the token to pass record the request's outcome with.
+func (b *breaker) allow() (uint64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.open {
+		return b.gen, nil
+	}
+	if b.trial || b.clock.Now().Sub(b.openedAt) < b.policy.Cooldown {
+		return 0, ErrCircuitOpen
+	}
+	b.trial = true
+	b.gen++
+	return b.gen, nil
+}
+
+// record notes the outcome of a request that allow let through with token.
+// Only the outcomes of requests let through since the breaker last changed
+// count: one that started before the breaker opened says nothing about
+// whether the backend has recovered since, so only the trial can close it.
+func (b *breaker) record(token uint64, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if token != b.gen {
+		return
+	}
+	// A request abandoned by its caller says nothing about the backend.
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		b.trial = false
+		return
+	}
+	failed := err != nil && RetryableError(err)
+
+	if b.open {
+		if !b.trial {
+			return
+		}
+		b.trial = false
+		b.gen++
+		if failed {
+			b.openedAt = b.clock.Now()
+			return
+		}
+		b.open = false
+		b.next, b.count, b.failures = 0, 0, 0
+	}
+
+	if b.count == len(b.outcomes) {
+		if b.outcomes[b.next] {
+			b.failures--
+		}
+	} else {
+		b.count++
+	}
+	b.outcomes[b.next] = failed
+	if failed {
+		b.failures++
+	}
+	b.next = (b.next + 1) % len(b.outcomes)
+
+	if b.count == len(b.outcomes) && float64(b.failures) >= b.policy.FailureRate*float64(b.count) {
+		b.open = true
+		b.openedAt = b.clock.Now()
+		b.gen++
+	}
+}
@@ -0,0 +1,170 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"context"
+	"crypto/sha1"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBreaker(t *testing.T) {
+	clock := newFakeClock()
+	f := NewFinder(
+		WithCircuitBreaker(BreakerPolicy{Window: 4, Cooldown: time.Minute}),
+		WithClock(clock),
+	)
+	b := f.breaker
+	down := &HTTPError{StatusCode: http.StatusBadGateway}
+
+	// Not found, or a bad line, isn't the backend being down.
+	for _, err := range []error{nil, &HTTPError{StatusCode: http.StatusNotFound}, ErrMalformedLine, down} {
+		token, aerr := b.allow()
+		if aerr != nil {
+			t.Fatalf("unexpected: %v\n", aerr)
+		}
+		b.record(token, err)
+	}
+	// Canceled requests don't count either way.
+	token, err := b.allow()
+	if err != nil {
+		t.Fatalf("expected closed at 1 in 4: %v\n", err)
+	}
+	b.record(token, context.Canceled)
+	if token, err = b.allow(); err != nil {
+		t.Fatalf("expected closed at 1 in 4: %v\n", err)
+	}
+	// A request let through before the breaker opens...
+	early, _ := b.allow()
+	b.record(token, down)
+	if _, err = b.allow(); err != ErrCircuitOpen {
+		t.Fatalf("expected open at 2 in 4: %v\n", err)
+	}
+	// ...doesn't close it by succeeding after.
+	b.record(early, nil)
+	if _, err = b.allow(); err != ErrCircuitOpen {
+		t.Fatalf("expected still open: %v\n", err)
+	}
+
+	clock.Advance(time.Minute)
+	trial, err := b.allow()
+	if err != nil {
+		t.Fatalf("expected a trial: %v\n", err)
+	}
+	if _, err = b.allow(); err != ErrCircuitOpen {
+		t.Errorf("expected a single trial: %v\n", err)
+	}
+	b.record(early, nil)
+	if _, err = b.allow(); err != ErrCircuitOpen {
+		t.Errorf("expected only the trial to close it: %v\n", err)
+	}
+	b.record(trial, down)
+	if _, err = b.allow(); err != ErrCircuitOpen {
+		t.Errorf("expected open after failed trial: %v\n", err)
+	}
+
+	clock.Advance(time.Minute)
+	if trial, err = b.allow(); err != nil {
+		t.Fatalf("expected a trial: %v\n", err)
+	}
+	b.record(trial, nil)
+	for i := 0; i < 3; i++ {
+		token, err := b.allow()
+		if err != nil {
+			t.Fatalf("expected closed until the window fills: %v\n", err)
+		}
+		b.record(token, down)
+	}
+	if _, err = b.allow(); err != ErrCircuitOpen {
+		t.Errorf("expected open at 3 in 4: %v\n", err)
+	}
+}
+
+func TestWithCircuitBreaker(t *testing.T) {
+	var up int32 = 1
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if atomic.LoadInt32(&up) == 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(data))
+	}))
+	defer ts.Close()
+
+	testCases := []struct {
+		name   string
+		mode   DegradedMode
+		exOpen bool
+	}{
+		{
+			"deny",
+			DenyOnError,
+			true,
+		},
+		{
+			"allow",
+			AllowOnError,
+			false,
+		},
+	}
+
+	h := sha1.Sum([]byte("melobie"))
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			atomic.StoreInt32(&up, 0)
+			atomic.StoreInt32(&calls, 0)
+			clock := newFakeClock()
+			f := NewFinder(
+				WithClient(ts.Client()),
+				WithURLTemplate(fmt.Sprintf("%s/%%s", ts.URL)),
+				WithCircuitBreaker(BreakerPolicy{Window: 2, Cooldown: time.Minute}),
+				WithDegradedMode(tc.mode),
+				WithClock(clock),
+			)
+			f.Find(h[:])
+			f.Find(h[:])
+
+			n, err := f.Find(h[:])
+			if n != 0 || (err == ErrCircuitOpen) != tc.exOpen {
+				t.Errorf("expected [0, open %t]: %d, %v\n", tc.exOpen, n, err)
+			}
+			if got := atomic.LoadInt32(&calls); got != 2 {
+				t.Errorf("expected 2 calls: %d\n", got)
+			}
+			if Classify(ErrCircuitOpen) != CodeBackendDown {
+				t.Errorf("expected %v: %v\n", CodeBackendDown, Classify(ErrCircuitOpen))
+			}
+
+			atomic.StoreInt32(&up, 1)
+			clock.Advance(time.Minute)
+			if n, err = f.Find(h[:]); n != 401 || err != nil {
+				t.Errorf("expected [401, nil]: %d, %v\n", n, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import "sync/atomic"
+
+// A MemoryBudget bounds the memory held by the in-memory caches given it,
+// together, so a service embedding the package can cap what its caches
+// take however they are used: see WithMemoryCacheBudget and
+// WithResultCacheBudget. It is safe for concurrent use.
+//
+// Memory is accounted in bytes, for what an entry holds plus an estimate
+// of its bookkeeping. A cache that needs room for an entry makes it by
+// evicting its own least recently used entries, and doesn't store the
+// entry if that isn't enough.
+type MemoryBudget struct {
+	max  int64
+	used int64 // atomic
+}
+
+// NewMemoryBudget returns a MemoryBudget of max bytes.
+func NewMemoryBudget(max int64) *MemoryBudget {
+	return &MemoryBudget{max: max}
+}
+
+// Used returns the number of bytes taken by the caches drawing on the
+// budget.
+func (b *MemoryBudget) Used() int64 {
+	return atomic.LoadInt64(&b.used)
+}
+
+// Max returns the size of the budget, in bytes.
+func (b *MemoryBudget) Max() int64 {
+	return b.max
+}
+
+// reserve takes n bytes from the budget, and reports whether it could.
+func (b *MemoryBudget) reserve(n int64) bool {
+	for {
+		used := atomic.LoadInt64(&b.used)
+		if used+n > b.max {
+			return false
+		}
+		if atomic.CompareAndSwapInt64(&b.used, used, used+n) {
+			return true
+		}
+	}
+}
+
+// release returns n bytes to the budget.
+func (b *MemoryBudget) release(n int64) {
+	atomic.AddInt64(&b.used, -n)
+}
@@ -0,0 +1,60 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestMemoryBudget(t *testing.T) {
+	b := NewMemoryBudget(100)
+	if !b.reserve(60) {
+		t.Errorf("expected 60 to fit\n")
+	}
+	if b.reserve(50) {
+		t.Errorf("expected 50 not to fit\n")
+	}
+	if !b.reserve(40) {
+		t.Errorf("expected 40 to fit\n")
+	}
+	b.release(60)
+	if exp, got := int64(40), b.Used(); got != exp {
+		t.Errorf("expected %d: %d\n", exp, got)
+	}
+
+	// Concurrent reservations never overshoot.
+	b = NewMemoryBudget(1000)
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				b.reserve(7)
+			}
+		}()
+	}
+	wg.Wait()
+	if got := b.Used(); got > b.Max() || got < b.Max()-7 {
+		t.Errorf("expected within 7 of %d: %d\n", b.Max(), got)
+	}
+}
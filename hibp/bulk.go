@@ -0,0 +1,163 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// ErrResumeMismatch is returned by BulkChecker.Run when the input doesn't
+// match the ResumeToken it was asked to continue from.
+var ErrResumeMismatch = errors.New("hibp: input does not match resume token")
+
+// ResumeToken marks how far a bulk job got. The zero value starts from the
+// beginning. It holds no secrets beyond a hash already in the input, and can
+// be persisted (for instance as JSON) between runs.
+type ResumeToken struct {
+	// Offset is the number of input lines fully processed.
+	Offset int64
+	// Hash is the normalized hex digest on the last processed line, used
+	// to check that a resumed run is reading the same input.
+	Hash string
+}
+
+// BulkChecker checks a large list of hex encoded SHA1s, such as an export
+// from a user database, with a Finder. Jobs can be stopped at any point and
+// later resumed where they left off.
+type BulkChecker struct {
+	finder *Finder
+	dedup  int
+	grace  time.Duration
+}
+
+// NewBulkChecker returns a BulkChecker that uses f for lookups.
+func NewBulkChecker(f *Finder, options ...func(*BulkChecker)) *BulkChecker {
+	b := &BulkChecker{finder: f}
+	for _, opt := range options {
+		opt(b)
+	}
+	return b
+}
+
+// Run reads one hex encoded hash per line from in (see NormalizeSHA1 for
+// what is accepted; blank lines are skipped), and passes a Result for each
+// to fn, in order.
+//
+// Problems with a single input (CodeInvalidInput and CodeBlocklisted) are
+// reported in its Result and the job carries on. Any other failure is
+// taken to be the backend's, or the network's, and would only repeat for
+// the inputs that follow: the backend failing (CodeThrottled,
+// CodeBackendDown), answering with something that isn't range data
+// (CodeParseError), the kill switch (CodeDisabled), or an error that can't
+// be told apart (CodeUnknown). So the job stops on any of those, as it
+// does when ctx is done, reading in fails, or fn returns an error; that
+// error is returned together with a ResumeToken for the first input that
+// wasn't processed. Passing the token and the same input to a later Run
+// continues from there. A nil error means all of in was processed.
+func (b *BulkChecker) Run(ctx context.Context, in io.Reader, from ResumeToken, fn func(Result) error) (ResumeToken, error) {
+	sum, err := b.RunSummary(ctx, in, from, fn)
+	return sum.Token, err
+}
+
+// RunSummary is Run, but reports a Summary of the job rather than just its
+// ResumeToken. The Summary only covers what this call processed, not what
+// earlier runs of a resumed job did.
+func (b *BulkChecker) RunSummary(ctx context.Context, in io.Reader, from ResumeToken, fn func(Result) error) (Summary, error) {
+	start, requests := b.finder.clock.Now(), b.finder.requestCount()
+	sum := Summary{}
+	err := b.run(ctx, in, from, fn, &sum)
+	sum.Duration = b.finder.clock.Now().Sub(start)
+	sum.Requests = int64(b.finder.requestCount() - requests)
+	return sum, err
+}
+
+func (b *BulkChecker) run(ctx context.Context, in io.Reader, from ResumeToken, fn func(Result) error, sum *Summary) error {
+	token := &sum.Token
+	scanner := bufio.NewScanner(in)
+
+	for token.Offset < from.Offset && scanner.Scan() {
+		token.advance(scanner.Text(), b.finder.normalize)
+	}
+	if token.Offset < from.Offset {
+		if err := scanner.Err(); err != nil {
+			return err
+		}
+		return fmt.Errorf("%w: input ends before offset %d", ErrResumeMismatch, from.Offset)
+	}
+	if token.Hash != from.Hash {
+		*sum = Summary{}
+		return fmt.Errorf("%w: expected %s at offset %d: %s", ErrResumeMismatch, from.Hash, from.Offset, token.Hash)
+	}
+
+	work, cancel := ctx, context.CancelFunc(func() {})
+	if b.grace > 0 {
+		work, cancel = drainContext(ctx, b.grace, b.finder.clock)
+	}
+	defer cancel()
+
+	seen := newDedupSet(b.dedup)
+	for scanner.Scan() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			token.advance(line, b.finder.normalize)
+			continue
+		}
+		res, dup := seen.result(line, b.finder.normalize)
+		if !dup {
+			res = b.finder.result(work, line)
+		}
+		switch res.Code {
+		case "", CodeInvalidInput, CodeBlocklisted:
+		default:
+			return res.Err
+		}
+		if err := fn(res); err != nil {
+			return err
+		}
+		if !dup {
+			seen.add(res)
+		}
+		sum.add(res, dup, b.finder.threshold)
+		token.advance(line, b.finder.normalize)
+	}
+	return scanner.Err()
+}
+
+func (t *ResumeToken) advance(line string, normalize func(string) ([]byte, error)) {
+	t.Offset++
+	if strings.TrimSpace(line) == "" {
+		return
+	}
+	if sum, err := normalize(line); err == nil {
+		t.Hash = fmt.Sprintf("%X", sum)
+	} else {
+		t.Hash = ""
+	}
+}
@@ -0,0 +1,246 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"context"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func hexSum(pwd string) string {
+	return fmt.Sprintf("%x", sha1.Sum([]byte(pwd)))
+}
+
+func TestBulkCheckerResume(t *testing.T) {
+	var budget int32 = 2
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&budget, -1) < 0 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte(data))
+	}))
+	defer ts.Close()
+
+	f := NewFinder(
+		WithClient(ts.Client()),
+		WithURLTemplate(fmt.Sprintf("%s/%%s", ts.URL)),
+	)
+	b := NewBulkChecker(f)
+
+	input := strings.Join([]string{
+		hexSum("melobie"),
+		"",
+		"not a hash",
+		hexSum("gonna-miss"),
+		hexSum("lauragpe"),
+	}, "\n")
+
+	var counts []int64
+	var codes []ErrorCode
+	collect := func(res Result) error {
+		counts = append(counts, res.Count)
+		codes = append(codes, res.Code)
+		return nil
+	}
+
+	// The backend starts throttling on the third lookup.
+	token, err := b.Run(context.Background(), strings.NewReader(input), ResumeToken{}, collect)
+	if Classify(err) != CodeThrottled {
+		t.Fatalf("expected throttled: %v\n", err)
+	}
+	if token.Offset != 4 || token.Hash != strings.ToUpper(hexSum("gonna-miss")) {
+		t.Errorf("unexpected token: %+v\n", token)
+	}
+
+	atomic.StoreInt32(&budget, 10)
+	token, err = b.Run(context.Background(), strings.NewReader(input), token, collect)
+	if err != nil {
+		t.Fatalf("unexpected: %v\n", err)
+	}
+	if token.Offset != 5 {
+		t.Errorf("expected offset 5: %d\n", token.Offset)
+	}
+
+	xCounts := []int64{401, 0, 0, 229}
+	xCodes := []ErrorCode{"", CodeInvalidInput, "", ""}
+	if fmt.Sprint(counts) != fmt.Sprint(xCounts) {
+		t.Errorf("expected %v: %v\n", xCounts, counts)
+	}
+	if fmt.Sprint(codes) != fmt.Sprint(xCodes) {
+		t.Errorf("expected %q: %q\n", xCodes, codes)
+	}
+}
+
+func TestBulkCheckerResumeMismatch(t *testing.T) {
+	b := NewBulkChecker(NewFinder())
+	input := hexSum("alpha") + "\n" + hexSum("bravo") + "\n"
+	fail := func(res Result) error {
+		t.Errorf("unexpected result: %+v\n", res)
+		return nil
+	}
+
+	testCases := []struct {
+		name  string
+		token ResumeToken
+	}{
+		{
+			"wrong hash",
+			ResumeToken{Offset: 1, Hash: strings.ToUpper(hexSum("bravo"))},
+		},
+		{
+			"past the end",
+			ResumeToken{Offset: 3},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := b.Run(context.Background(), strings.NewReader(input), tc.token, fail)
+			if !errors.Is(err, ErrResumeMismatch) {
+				t.Errorf("expected %v: %v\n", ErrResumeMismatch, err)
+			}
+		})
+	}
+
+	// Resuming at the very end is not an error, there's just nothing left.
+	end := ResumeToken{Offset: 2, Hash: strings.ToUpper(hexSum("bravo"))}
+	token, err := b.Run(context.Background(), strings.NewReader(input), end, fail)
+	if err != nil || token != end {
+		t.Errorf("expected [%+v, nil]: %+v, %v\n", end, token, err)
+	}
+}
+
+func TestBulkCheckerStop(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(data))
+	}))
+	defer ts.Close()
+
+	b := NewBulkChecker(NewFinder(
+		WithClient(ts.Client()),
+		WithURLTemplate(fmt.Sprintf("%s/%%s", ts.URL)),
+	))
+	input := strings.Repeat(hexSum("melobie")+"\n", 5)
+
+	stop := errors.New("stop")
+	seen := 0
+	token, err := b.Run(context.Background(), strings.NewReader(input), ResumeToken{}, func(Result) error {
+		seen++
+		if seen == 3 {
+			return stop
+		}
+		return nil
+	})
+	if err != stop || token.Offset != 2 {
+		t.Errorf("expected [2, %v]: %d, %v\n", stop, token.Offset, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	token, err = b.Run(ctx, strings.NewReader(input), token, func(Result) error {
+		t.Errorf("unexpected result")
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) || token.Offset != 2 {
+		t.Errorf("expected [2, %v]: %d, %v\n", context.Canceled, token.Offset, err)
+	}
+}
+
+type failingSource struct{ err error }
+
+func (s failingSource) Range(ctx context.Context, mode HashMode, prefix string) ([]byte, error) {
+	return nil, s.err
+}
+
+func TestBulkCheckerBackendFailures(t *testing.T) {
+	html := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html><body>Sign in to continue</body></html>"))
+	}))
+	defer html.Close()
+	large := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(data))
+	}))
+	defer large.Close()
+	unreadable := errors.New("disk on fire")
+
+	testCases := []struct {
+		name   string
+		finder *Finder
+		exErr  error
+		exCode ErrorCode
+	}{
+		{
+			"captive portal",
+			NewFinder(
+				WithClient(html.Client()),
+				WithURLTemplate(fmt.Sprintf("%s/%%s", html.URL)),
+			),
+			ErrUnexpectedContent,
+			CodeParseError,
+		},
+		{
+			"body too large",
+			NewFinder(
+				WithClient(large.Client()),
+				WithURLTemplate(fmt.Sprintf("%s/%%s", large.URL)),
+				WithMaxBodySize(16),
+			),
+			ErrBodyTooLarge,
+			CodeParseError,
+		},
+		{
+			"source error",
+			NewFinder(WithSource(failingSource{unreadable})),
+			unreadable,
+			CodeUnknown,
+		},
+	}
+
+	// Bad input is reported, and the job carries on to the first lookup.
+	input := "zzz\n" + strings.Repeat(hexSum("melobie")+"\n", 4)
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var codes []ErrorCode
+			sum, err := NewBulkChecker(tc.finder).RunSummary(context.Background(), strings.NewReader(input), ResumeToken{}, func(res Result) error {
+				codes = append(codes, res.Code)
+				return nil
+			})
+			if !errors.Is(err, tc.exErr) || Classify(err) != tc.exCode {
+				t.Errorf("expected %v (%s): %v\n", tc.exErr, tc.exCode, err)
+			}
+			if sum.Token.Offset != 1 {
+				t.Errorf("expected to stop at offset 1: %d\n", sum.Token.Offset)
+			}
+			if len(codes) != 1 || codes[0] != CodeInvalidInput {
+				t.Errorf("expected [%s]: %v\n", CodeInvalidInput, codes)
+			}
+		})
+	}
+}
@@ -0,0 +1,209 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// cacheKeySize is the size of the secret generated when a Finder with a
+// cache isn't given one by WithCacheSecret.
+const cacheKeySize = 32
+
+// A Cache keeps range bodies between lookups, so that lookups of hashes
+// in a range already retrieved don't need a round trip to the backend.
+// Ranges change rarely, so even a short-lived cache saves a lot in bulk
+// work. Bodies handed to and returned from a Cache must not be modified.
+//
+// A Cache is shared by all lookups through a Finder, so implementations
+// must be safe for concurrent use. Keys are HMACs of the range they are
+// for and the backend it came from (see WithCacheSecret), so a cache's
+// contents don't say which ranges were looked up, and Finders pointed at
+// different backends can share a cache without mixing up their ranges.
+// Besides MemoryCache and DiskCache, the rediscache and memcache packages
+// provide caches shared by a fleet of instances.
+type Cache interface {
+	// Get returns the body stored for key, if it is there and fresh.
+	Get(key string) ([]byte, bool)
+	// Set stores body for key, fresh for ttl; zero means the cache's own
+	// default.
+	Set(key string, body []byte, ttl time.Duration)
+	// Delete removes what is stored for key, if anything.
+	Delete(key string)
+}
+
+// A StaleCache is a Cache that can also return entries that are no longer
+// fresh, which StaleCacheOnError answers from when the backend fails.
+type StaleCache interface {
+	Cache
+	// GetStale returns the body stored for key, however old.
+	GetStale(key string) ([]byte, bool)
+}
+
+// WithCache has range bodies kept in c and reused for later lookups. Each
+// body is kept for as long as the Cache-Control header of its response
+// allows: the max-age, less the Age, or not at all for no-store, no-cache
+// or a max-age of zero. Without a max-age, the cache's default applies.
+//
+// Only bodies that are well formed throughout are stored: every line a
+// suffix of the right length and a count. One that isn't, such as one
+// cut short by a flaky mirror, still answers the lookup it was fetched
+// for, but isn't kept to answer later ones.
+func WithCache(c Cache) func(f *Finder) {
+	return func(f *Finder) {
+		f.cache = c
+	}
+}
+
+// WithCacheSecret sets the secret that keys the HMAC cache keys are derived
+// with. Without one, a Finder with a cache generates a random secret, so
+// its keys are only good for its own lifetime; Finders sharing a cache
+// across a fleet, or a DiskCache across restarts, must be given the same
+// secret to find each other's entries. The secret should be kept as
+// private as any other credential: with it, the keys can be reversed.
+func WithCacheSecret(secret []byte) func(f *Finder) {
+	return func(f *Finder) {
+		f.cacheSecret = append([]byte(nil), secret...)
+	}
+}
+
+// cacheKey identifies the range for prefix, of the given mode, as fetched
+// by the Finder from its backend. Given a secret, it is a hex encoded HMAC
+// of that name rather than the name itself.
+func (f *Finder) cacheKey(mode HashMode, prefix []byte) string {
+	kind := "sha1"
+	if mode == NTLM {
+		kind = "ntlm"
+	}
+	if f.padding {
+		kind += "+padding"
+	}
+	name := fmt.Sprintf("%s:%s@%s", kind, prefix, f.cacheBackend())
+	if len(f.cacheSecret) == 0 {
+		return name
+	}
+	mac := hmac.New(sha256.New, f.cacheSecret)
+	mac.Write([]byte(name))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// cacheBackend names where the Finder gets its ranges, so that Finders pointed
+// at different backends don't share cache entries: the URL template or
+// base URL, or for a Source, its String if it is a fmt.Stringer, and
+// otherwise its type.
+func (f *Finder) cacheBackend() string {
+	if f.source != nil {
+		if s, ok := f.source.(fmt.Stringer); ok {
+			return fmt.Sprintf("%T(%s)", f.source, s)
+		}
+		return fmt.Sprintf("%T", f.source)
+	}
+	if f.base != nil {
+		return f.base.String()
+	}
+	return f.tmpl
+}
+
+// verifyRange checks that body is fit to be cached for ranges of the given
+// mode: that it has at least one line, and that every line is a suffix of
+// the length mode calls for and a count.
+func (f *Finder) verifyRange(mode HashMode, body []byte) error {
+	return f.scanRange(mode, body, func([]byte, int64) {})
+}
+
+// scanRange is verifyRange, also calling fn with the suffix and count of
+// each line. The suffix is only valid during the call.
+func (f *Finder) scanRange(mode HashMode, body []byte, fn func(suffix []byte, n int64)) error {
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 0, 4096), f.maxLineLength()+2)
+	size := 2*mode.size() - prefixSize
+	lines := 0
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if i := bytes.Index(line, delim); i != size || !isHex(line[:i]) {
+			return fmt.Errorf("%w: line %d: %q", ErrMalformedLine, lineNo, line)
+		}
+		n, err := parseCount(line)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		fn(line[:size], n)
+		lines++
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if lines == 0 {
+		return fmt.Errorf("%w: empty range", ErrMalformedLine)
+	}
+	return nil
+}
+
+// cacheSecretDefault gives a Finder with a cache, but no secret to key its
+// cache keys with, a random one.
+func (f *Finder) cacheSecretDefault() {
+	if f.cache == nil || len(f.cacheSecret) != 0 {
+		return
+	}
+	f.cacheSecret = make([]byte, cacheKeySize)
+	if _, err := rand.Read(f.cacheSecret); err != nil {
+		panic("hibp: generating cache secret: " + err.Error())
+	}
+}
+
+// rangeBody returns the range for prefix from the cache if it can, and
+// from the backend otherwise. It also reports how long the body may be
+// kept for, as fetchRange does, and whether it was a cache hit. A stale
+// body, answering for a failed request, may not be kept.
+func (f *Finder) rangeBody(ctx context.Context, mode HashMode, prefix []byte) ([]byte, time.Duration, bool, error) {
+	key := f.cacheKey(mode, prefix)
+	if f.cache == nil {
+		body, ttl, err := f.fetchShared(ctx, key, mode, prefix)
+		return body, ttl, false, err
+	}
+	if body, ok := f.cache.Get(key); ok {
+		return body, 0, true, nil
+	}
+	body, ttl, err := f.fetchShared(ctx, key, mode, prefix)
+	if err != nil {
+		if sc, ok := f.cache.(StaleCache); ok && f.degraded == StaleCacheOnError {
+			if body, ok := sc.GetStale(key); ok {
+				return body, -1, true, nil
+			}
+		}
+		return nil, 0, false, err
+	}
+	if ttl >= 0 && f.verifyRange(mode, body) == nil {
+		f.cache.Set(key, body, ttl)
+	}
+	return body, ttl, false, nil
+}
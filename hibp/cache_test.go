@@ -0,0 +1,258 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWithCache(t *testing.T) {
+	requests := 0
+	fail := false
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if fail {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(data))
+	}))
+	defer ts.Close()
+
+	testCases := []struct {
+		name    string
+		mode    DegradedMode
+		exStale bool
+	}{
+		{
+			"deny",
+			DenyOnError,
+			false,
+		},
+		{
+			"stale",
+			StaleCacheOnError,
+			true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			requests, fail = 0, false
+			clock := newFakeClock()
+			cache := NewMemoryCache(10, time.Minute, WithMemoryCacheClock(clock))
+			f := NewFinder(
+				WithClient(ts.Client()),
+				WithURLTemplate(fmt.Sprintf("%s/%%s", ts.URL)),
+				WithCache(cache),
+				WithDegradedMode(tc.mode),
+			)
+
+			check := func(input string, exp int64, exHit bool, xRequests int) {
+				t.Helper()
+				res := f.result(context.Background(), input)
+				if res.Count != exp || res.Err != nil || res.CacheHit != exHit {
+					t.Errorf("expected [%d, nil, hit %t]: %d, %v, %t\n", exp, exHit, res.Count, res.Err, res.CacheHit)
+				}
+				if requests != xRequests {
+					t.Errorf("expected %d requests: %d\n", xRequests, requests)
+				}
+			}
+
+			// melobie and lauragpe share a range.
+			check("21BD1012A7CA357541F0AC487871FEEC1891C49C", 401, false, 1)
+			check("21BD10018A45C4D1DEF81644B54AB7F969B88D65", 229, true, 1)
+			check("5D284D04B6A031675E0E060A97986C30E8A67B61", 0, false, 2)
+
+			clock.Advance(time.Minute)
+			fail = true
+			res := f.result(context.Background(), "21BD1012A7CA357541F0AC487871FEEC1891C49C")
+			if tc.exStale != (res.Err == nil) {
+				t.Errorf("expected stale answer %t: %v\n", tc.exStale, res.Err)
+			}
+			if tc.exStale && (res.Count != 401 || !res.CacheHit) {
+				t.Errorf("expected [401, hit]: %d, %t\n", res.Count, res.CacheHit)
+			}
+			if requests != 3 {
+				t.Errorf("expected 3 requests: %d\n", requests)
+			}
+
+			if got := cache.Stats(); got.Hits != 1 || got.Misses != 3 {
+				t.Errorf("expected [1, 3]: %d, %d\n", got.Hits, got.Misses)
+			}
+		})
+	}
+}
+
+func TestCacheKey(t *testing.T) {
+	prefix := []byte("21BD1")
+	secret := WithCacheSecret([]byte("s3cret"))
+	keys := map[string]bool{
+		NewFinder(secret).cacheKey(SHA1, prefix):                                                     true,
+		NewFinder(secret).cacheKey(NTLM, prefix):                                                     true,
+		NewFinder(secret, WithPadding(true)).cacheKey(SHA1, prefix):                                  true,
+		NewFinder(secret).cacheKey(SHA1, []byte("21BD2")):                                            true,
+		NewFinder(secret, WithURLTemplate("https://mirror.example/range/%s")).cacheKey(SHA1, prefix): true,
+		NewFinder(secret, WithSource(DirSource("/srv/a"))).cacheKey(SHA1, prefix):                    true,
+		NewFinder(secret, WithSource(DirSource("/srv/b"))).cacheKey(SHA1, prefix):                    true,
+	}
+	if len(keys) != 7 {
+		t.Errorf("expected distinct keys: %v\n", keys)
+	}
+	for k := range keys {
+		if strings.Contains(strings.ToUpper(k), "21BD") {
+			t.Errorf("expected the prefix hidden: %s\n", k)
+		}
+	}
+
+	// The same secret gives the same keys, and another secret others.
+	if a, b := NewFinder(secret).cacheKey(SHA1, prefix), NewFinder(secret).cacheKey(SHA1, prefix); a != b {
+		t.Errorf("expected %s: %s\n", a, b)
+	}
+	other := NewFinder(WithCacheSecret([]byte("other")))
+	if k := other.cacheKey(SHA1, prefix); keys[k] {
+		t.Errorf("expected a different key: %s\n", k)
+	}
+
+	// Without a secret, a Finder with a cache makes up its own.
+	c := NewMemoryCache(1, time.Minute)
+	a, b := NewFinder(WithCache(c)), NewFinder(WithCache(c))
+	if ka, kb := a.cacheKey(SHA1, prefix), b.cacheKey(SHA1, prefix); ka == kb || strings.Contains(ka, "21BD1") {
+		t.Errorf("expected random keys: %s, %s\n", ka, kb)
+	}
+}
+
+func TestCacheVerifiesBodies(t *testing.T) {
+	testCases := []struct {
+		name     string
+		body     string
+		exp      int64
+		exStored bool
+	}{
+		{
+			"complete",
+			data,
+			401,
+			true,
+		},
+		{
+			"cut after a colon",
+			data[:len(data)-3],
+			401,
+			false,
+		},
+		{
+			"cut within a suffix",
+			data[:len(data)-20],
+			401,
+			false,
+		},
+		{
+			"plain text",
+			"Service temporarily unavailable\n",
+			0,
+			false,
+		},
+		{
+			"empty",
+			"",
+			0,
+			false,
+		},
+		{
+			"ntlm range",
+			"7EAEE8FB117AD06BDD830B7586C:52\n",
+			0,
+			false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			requests := 0
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				w.Write([]byte(tc.body))
+			}))
+			defer ts.Close()
+
+			f := NewFinder(
+				WithClient(ts.Client()),
+				WithURLTemplate(fmt.Sprintf("%s/%%s", ts.URL)),
+				WithCache(NewMemoryCache(10, time.Minute)),
+			)
+			for i := 0; i < 2; i++ {
+				if n, err := f.FindPassword("melobie"); n != tc.exp || err != nil {
+					t.Errorf("expected [%d, nil]: %d, %v\n", tc.exp, n, err)
+				}
+			}
+			exRequests := 2
+			if tc.exStored {
+				exRequests = 1
+			}
+			if requests != exRequests {
+				t.Errorf("expected %d requests: %d\n", exRequests, requests)
+			}
+		})
+	}
+}
+
+func TestCacheBackends(t *testing.T) {
+	var requests [2]int
+	servers := make([]*httptest.Server, 2)
+	for i, body := range []string{data, "012A7CA357541F0AC487871FEEC1891C49C:7\n"} {
+		i, body := i, body
+		servers[i] = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests[i]++
+			w.Write([]byte(body))
+		}))
+		defer servers[i].Close()
+	}
+
+	// Finders on different mirrors share one cache, and a secret.
+	cache := NewMemoryCache(10, time.Minute)
+	finders := make([]*Finder, 2)
+	for i, ts := range servers {
+		finders[i] = NewFinder(
+			WithClient(ts.Client()),
+			WithURLTemplate(fmt.Sprintf("%s/%%s", ts.URL)),
+			WithCache(cache),
+			WithCacheSecret([]byte("s3cret")),
+		)
+	}
+
+	for round := 0; round < 2; round++ {
+		for i, exp := range []int64{401, 7} {
+			if n, err := finders[i].FindPassword("melobie"); n != exp || err != nil {
+				t.Errorf("expected [%d, nil]: %d, %v\n", exp, n, err)
+			}
+		}
+	}
+	if requests != [2]int{1, 1} {
+		t.Errorf("expected 1 request each: %v\n", requests)
+	}
+}
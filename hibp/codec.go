@@ -0,0 +1,145 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"time"
+)
+
+// A Codec encodes range bodies for storage in a Cache, and decodes them
+// back. Caches shared over the network can use one to store bodies more
+// compactly than as plain text. Codecs for formats the standard library
+// doesn't cover, such as zstd, can be provided by the caller.
+type Codec interface {
+	Encode(body []byte) ([]byte, error)
+	Decode(data []byte) ([]byte, error)
+}
+
+// RawCodec stores bodies as they are.
+var RawCodec Codec = rawCodec{}
+
+type rawCodec struct{}
+
+func (rawCodec) Encode(body []byte) ([]byte, error) { return body, nil }
+func (rawCodec) Decode(data []byte) ([]byte, error) { return data, nil }
+
+// GzipCodec compresses bodies with gzip at the given level (see
+// compress/gzip), typically to around half their size. Decode returns
+// ErrBodyTooLarge rather than decompress more than DefaultMaxBodySize.
+func GzipCodec(level int) Codec {
+	return gzipCodec{level}
+}
+
+type gzipCodec struct {
+	level int
+}
+
+func (c gzipCodec) Encode(body []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw, err := gzip.NewWriterLevel(&buf, c.level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := zw.Write(body); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (c gzipCodec) Decode(data []byte) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	body, err := ioutil.ReadAll(io.LimitReader(zr, DefaultMaxBodySize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > DefaultMaxBodySize {
+		return nil, ErrBodyTooLarge
+	}
+	return body, nil
+}
+
+// CodecCache is a Cache that stores bodies in another Cache, encoded with a
+// Codec. Entries that fail to decode are treated as missing.
+type CodecCache struct {
+	cache Cache
+	codec Codec
+}
+
+// NewCodecCache returns a Cache storing bodies in c, encoded with codec.
+func NewCodecCache(c Cache, codec Codec) *CodecCache {
+	return &CodecCache{cache: c, codec: codec}
+}
+
+// Get implements Cache.
+func (c *CodecCache) Get(key string) ([]byte, bool) {
+	data, ok := c.cache.Get(key)
+	if !ok {
+		return nil, false
+	}
+	return c.decode(data)
+}
+
+// GetStale implements StaleCache, if the underlying Cache does; otherwise
+// it has nothing to return.
+func (c *CodecCache) GetStale(key string) ([]byte, bool) {
+	sc, ok := c.cache.(StaleCache)
+	if !ok {
+		return nil, false
+	}
+	data, ok := sc.GetStale(key)
+	if !ok {
+		return nil, false
+	}
+	return c.decode(data)
+}
+
+// Set implements Cache. A body that fails to encode isn't stored.
+func (c *CodecCache) Set(key string, body []byte, ttl time.Duration) {
+	data, err := c.codec.Encode(body)
+	if err != nil {
+		return
+	}
+	c.cache.Set(key, data, ttl)
+}
+
+// Delete implements Cache.
+func (c *CodecCache) Delete(key string) {
+	c.cache.Delete(key)
+}
+
+func (c *CodecCache) decode(data []byte) ([]byte, bool) {
+	body, err := c.codec.Decode(data)
+	if err != nil {
+		return nil, false
+	}
+	return body, true
+}
@@ -0,0 +1,139 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"compress/gzip"
+	"strings"
+	"testing"
+	"time"
+)
+
+// mapCache is a Cache with no expiry, which doesn't implement StaleCache.
+type mapCache map[string][]byte
+
+func (c mapCache) Get(key string) ([]byte, bool) {
+	body, ok := c[key]
+	return body, ok
+}
+
+func (c mapCache) Set(key string, body []byte, ttl time.Duration) {
+	c[key] = body
+}
+
+func (c mapCache) Delete(key string) {
+	delete(c, key)
+}
+
+func TestCodecs(t *testing.T) {
+	body := []byte(strings.Repeat(data, 100))
+
+	testCases := []struct {
+		name    string
+		codec   Codec
+		smaller bool
+	}{
+		{
+			"raw",
+			RawCodec,
+			false,
+		},
+		{
+			"gzip",
+			GzipCodec(gzip.BestCompression),
+			true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			enc, err := tc.codec.Encode(body)
+			if err != nil {
+				t.Fatalf("unexpected: %v\n", err)
+			}
+			if tc.smaller != (len(enc) < len(body)) {
+				t.Errorf("expected smaller %t: %d of %d\n", tc.smaller, len(enc), len(body))
+			}
+			dec, err := tc.codec.Decode(enc)
+			if err != nil || string(dec) != string(body) {
+				t.Errorf("expected round trip: %v\n", err)
+			}
+		})
+	}
+
+	if _, err := GzipCodec(42).Encode(body); err == nil {
+		t.Errorf("expected error for bad level\n")
+	}
+}
+
+func TestGzipCodecTooLarge(t *testing.T) {
+	body := []byte(strings.Repeat("0", DefaultMaxBodySize+1))
+
+	codec := GzipCodec(gzip.DefaultCompression)
+	enc, err := codec.Encode(body)
+	if err != nil {
+		t.Fatalf("unexpected: %v\n", err)
+	}
+	if _, err := codec.Decode(enc); err != ErrBodyTooLarge {
+		t.Errorf("expected %v: %v\n", ErrBodyTooLarge, err)
+	}
+	if _, err := codec.Decode(enc[:len(enc)/2]); err == nil {
+		t.Errorf("expected error for truncated data\n")
+	}
+}
+
+func TestCodecCache(t *testing.T) {
+	store := mapCache{}
+	c := NewCodecCache(store, GzipCodec(gzip.DefaultCompression))
+
+	c.Set("sha1:21BD1", []byte(data), 0)
+	if string(store["sha1:21BD1"]) == data {
+		t.Errorf("expected body stored encoded\n")
+	}
+	if body, ok := c.Get("sha1:21BD1"); !ok || string(body) != data {
+		t.Errorf("expected [data, true]: %q, %t\n", body, ok)
+	}
+
+	store["sha1:FFFFF"] = []byte("not gzip")
+	if _, ok := c.Get("sha1:FFFFF"); ok {
+		t.Errorf("expected undecodable entry to miss\n")
+	}
+	if _, ok := c.GetStale("sha1:21BD1"); ok {
+		t.Errorf("expected no stale entries without a StaleCache\n")
+	}
+	c.Delete("sha1:21BD1")
+	if _, ok := store["sha1:21BD1"]; ok {
+		t.Errorf("expected entry deleted\n")
+	}
+
+	clock := newFakeClock()
+	mem := NewMemoryCache(10, time.Minute)
+	mem.clock = clock
+	c = NewCodecCache(mem, GzipCodec(gzip.DefaultCompression))
+	c.Set("sha1:21BD1", []byte(data), 0)
+	clock.Advance(time.Minute)
+	if _, ok := c.Get("sha1:21BD1"); ok {
+		t.Errorf("expected expired entry to miss\n")
+	}
+	if body, ok := c.GetStale("sha1:21BD1"); !ok || string(body) != data {
+		t.Errorf("expected [data, true]: %q, %t\n", body, ok)
+	}
+}
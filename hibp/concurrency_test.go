@@ -0,0 +1,137 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestFinderConcurrency hammers a Finder with every stateful option turned
+// on from many goroutines, with and without a cache, as lookups take a
+// different path through the flight group with one. It is most useful run
+// with -race.
+func TestFinderConcurrency(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Slow enough for lookups of the same range to overlap.
+		time.Sleep(time.Millisecond)
+		w.Write([]byte(data))
+	}))
+	defer ts.Close()
+	u, _ := url.Parse(ts.URL)
+
+	testCases := []struct {
+		name string
+		opts []func(*Finder)
+	}{
+		{
+			"streamed",
+			nil,
+		},
+		{
+			"cached",
+			[]func(*Finder){WithCache(NewMemoryCache(2, time.Millisecond))},
+		},
+	}
+
+	pwds := []struct {
+		pwd string
+		exp int64
+	}{
+		{"melobie", 401},
+		{"lauragpe", 229},
+		{"gonna-miss", 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			f := NewFinder(append([]func(*Finder){
+				WithClient(ts.Client()),
+				WithURLTemplate(fmt.Sprintf("http://hibp.test:%s/%%s", u.Port())),
+				WithDNSCache(time.Millisecond),
+				WithResultCache(NewResultCache(nil, 2, time.Millisecond)),
+				WithBlocklist(NewBlocklist("blocked")),
+				WithShortlist(&Shortlist{counts: map[string]int64{}}),
+				WithRandom(bytes.NewReader(make([]byte, 1<<16))),
+				WithAPIKey("s3cret"),
+				WithRateLimit(1e6, 4),
+				WithCircuitBreaker(BreakerPolicy{}),
+				func(f *Finder) {
+					f.dial.lookup = func(ctx context.Context, host string) ([]string, error) {
+						return []string{u.Hostname()}, nil
+					}
+				},
+			}, tc.opts...)...)
+
+			const workers = 16
+			const rounds = 25
+			var wg sync.WaitGroup
+			for w := 0; w < workers; w++ {
+				wg.Add(1)
+				go func(w int) {
+					defer wg.Done()
+					for i := 0; i < rounds; i++ {
+						pc := pwds[(w+i)%len(pwds)]
+						n, err := f.FindPassword(pc.pwd)
+						if n != pc.exp || err != nil {
+							t.Errorf("expected [%d, nil]: %d, %v\n", pc.exp, n, err)
+						}
+						if _, err := f.FindPassword("blocked"); err != ErrBlocklisted {
+							t.Errorf("expected %v: %v\n", ErrBlocklisted, err)
+						}
+						f.jitter(time.Second)
+					}
+				}(w)
+			}
+
+			// Streams share the Finder too.
+			for w := 0; w < 4; w++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					in := make(chan string)
+					go func() {
+						defer close(in)
+						for i := 0; i < rounds; i++ {
+							in <- fmt.Sprintf("%x", sha1.Sum([]byte(pwds[i%len(pwds)].pwd)))
+						}
+					}()
+					i := 0
+					for res := range f.FindStream(context.Background(), in) {
+						if exp := pwds[i%len(pwds)].exp; res.Count != exp || res.Err != nil {
+							t.Errorf("expected [%d, nil]: %d, %v\n", exp, res.Count, res.Err)
+						}
+						i++
+					}
+				}()
+			}
+			wg.Wait()
+		})
+	}
+}
@@ -0,0 +1,55 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"mime"
+)
+
+// ErrUnexpectedContent is wrapped by the error returned when a successful
+// response doesn't look like range data at all, for instance an HTML or
+// JSON error page served with a 200 by a middlebox.
+var ErrUnexpectedContent = errors.New("hibp: unexpected content")
+
+// checkContent sniffs a response for the common ways an error page can be
+// told apart from range data: its declared type, or its first byte.
+func checkContent(contentType string, body []byte) error {
+	if contentType != "" {
+		mt, _, err := mime.ParseMediaType(contentType)
+		if err == nil {
+			switch mt {
+			case "text/html", "application/xhtml+xml", "application/json", "application/problem+json":
+				return fmt.Errorf("%w: content type %s", ErrUnexpectedContent, mt)
+			}
+		}
+	}
+	trimmed := bytes.TrimLeft(body, " \t\r\n\ufeff")
+	if len(trimmed) > 0 {
+		switch trimmed[0] {
+		case '<', '{', '[':
+			return fmt.Errorf("%w: body starts with %q", ErrUnexpectedContent, trimmed[0])
+		}
+	}
+	return nil
+}
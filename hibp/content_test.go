@@ -0,0 +1,112 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCheckContent(t *testing.T) {
+	testCases := []struct {
+		name  string
+		ctype string
+		body  string
+		exErr bool
+	}{
+		{
+			"range data",
+			"text/plain",
+			data,
+			false,
+		},
+		{
+			"no content type",
+			"",
+			data,
+			false,
+		},
+		{
+			"empty",
+			"text/plain",
+			"",
+			false,
+		},
+		{
+			"html type",
+			"text/html; charset=utf-8",
+			data,
+			true,
+		},
+		{
+			"json type",
+			"application/json",
+			data,
+			true,
+		},
+		{
+			"html body",
+			"text/plain",
+			"\r\n  <!DOCTYPE html><html></html>",
+			true,
+		},
+		{
+			"json body",
+			"application/octet-stream",
+			`{"statusCode": 429}`,
+			true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := checkContent(tc.ctype, []byte(tc.body))
+			if tc.exErr && !errors.Is(err, ErrUnexpectedContent) {
+				t.Errorf("expected %v: %v\n", ErrUnexpectedContent, err)
+			}
+			if !tc.exErr && err != nil {
+				t.Errorf("unexpected: %v\n", err)
+			}
+		})
+	}
+}
+
+func TestFindUnexpectedContent(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html><body>Access denied by policy</body></html>"))
+	}))
+	defer ts.Close()
+
+	f := NewFinder(
+		WithClient(ts.Client()),
+		WithURLTemplate(fmt.Sprintf("%s/%%s", ts.URL)),
+	)
+	h := sha1.Sum([]byte("melobie"))
+	n, err := f.Find(h[:])
+	if n != 0 || !errors.Is(err, ErrUnexpectedContent) {
+		t.Errorf("expected [0, %v]: %d, %v\n", ErrUnexpectedContent, n, err)
+	}
+}
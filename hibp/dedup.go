@@ -0,0 +1,71 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+// WithDedup has a BulkChecker look up each distinct hash only once per
+// job, answering later lines with the same hash from the first one's
+// Result, as dumps tend to repeat common passwords many times. Up to max
+// distinct hashes are remembered, about 100 bytes each; once that many
+// have been seen, new hashes are looked up every time. A max of zero, the
+// default, turns deduplication off.
+func WithDedup(max int) func(b *BulkChecker) {
+	return func(b *BulkChecker) {
+		b.dedup = max
+	}
+}
+
+// dedupSet holds the Results of the distinct hashes seen so far in a job.
+type dedupSet struct {
+	max     int
+	results map[string]Result
+}
+
+func newDedupSet(max int) *dedupSet {
+	return &dedupSet{max: max, results: map[string]Result{}}
+}
+
+// result returns the Result of an earlier line with the same hash as line,
+// with Input replaced, if there was one.
+func (d *dedupSet) result(line string, normalize func(string) ([]byte, error)) (Result, bool) {
+	if len(d.results) == 0 {
+		return Result{}, false
+	}
+	sum, err := normalize(line)
+	if err != nil {
+		return Result{}, false
+	}
+	res, ok := d.results[string(sum)]
+	if !ok {
+		return Result{}, false
+	}
+	res.Input = line
+	res.Duration = 0
+	res.CacheHit = true
+	return res, true
+}
+
+// add remembers res, if it is for a well-formed hash and there is room.
+func (d *dedupSet) add(res Result) {
+	if res.Hash == nil || len(d.results) >= d.max {
+		return
+	}
+	d.results[string(res.Hash)] = res
+}
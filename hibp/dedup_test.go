@@ -0,0 +1,118 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestWithDedup(t *testing.T) {
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Write([]byte(data))
+	}))
+	defer ts.Close()
+
+	input := strings.Join([]string{
+		hexSum("melobie"),
+		hexSum("gonna-miss"),
+		strings.ToUpper(hexSum("melobie")),
+		"not a hash",
+		"not a hash",
+		hexSum("gonna-miss"),
+		hexSum("lauragpe"),
+		hexSum("lauragpe"),
+	}, "\n")
+
+	testCases := []struct {
+		name  string
+		max   int
+		calls int32
+		sum   Summary
+	}{
+		{
+			"off",
+			0,
+			6,
+			Summary{Inputs: 8, Unique: 8},
+		},
+		{
+			"on",
+			100,
+			3,
+			Summary{Inputs: 8, Unique: 5, Duplicates: 3},
+		},
+		{
+			"full",
+			1,
+			5,
+			Summary{Inputs: 8, Unique: 7, Duplicates: 1},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			atomic.StoreInt32(&calls, 0)
+			b := NewBulkChecker(NewFinder(
+				WithClient(ts.Client()),
+				WithURLTemplate(fmt.Sprintf("%s/%%s", ts.URL)),
+			), WithDedup(tc.max))
+
+			var inputs []string
+			var counts []int64
+			sum, err := b.RunSummary(context.Background(), strings.NewReader(input), ResumeToken{}, func(res Result) error {
+				inputs = append(inputs, res.Input)
+				counts = append(counts, res.Count)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("unexpected: %v\n", err)
+			}
+			if got := atomic.LoadInt32(&calls); got != tc.calls {
+				t.Errorf("expected %d calls: %d\n", tc.calls, got)
+			}
+			tc.sum.Token = ResumeToken{Offset: 8, Hash: strings.ToUpper(hexSum("lauragpe"))}
+			if sum.Token != tc.sum.Token || sum.Inputs != tc.sum.Inputs ||
+				sum.Unique != tc.sum.Unique || sum.Duplicates != tc.sum.Duplicates {
+				t.Errorf("expected %+v: %+v\n", tc.sum, sum)
+			}
+			if sum.Requests != int64(tc.calls) {
+				t.Errorf("expected %d requests: %d\n", tc.calls, sum.Requests)
+			}
+
+			// Every line gets its own Result, duplicates included.
+			if exp := strings.Split(input, "\n"); fmt.Sprint(inputs) != fmt.Sprint(exp) {
+				t.Errorf("expected %q: %q\n", exp, inputs)
+			}
+			exp := []int64{401, 0, 401, 0, 0, 0, 229, 229}
+			if fmt.Sprint(counts) != fmt.Sprint(exp) {
+				t.Errorf("expected %v: %v\n", exp, counts)
+			}
+		})
+	}
+}
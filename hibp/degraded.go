@@ -0,0 +1,48 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+// DegradedMode decides what Find reports when the range can't be retrieved
+// from the backend (network failures, non-200 responses and the like).
+// Problems with the input itself are always returned as errors.
+type DegradedMode int
+
+const (
+	// DenyOnError returns the backend error, leaving it to the caller to
+	// treat the check as failed. This is the default.
+	DenyOnError DegradedMode = iota
+	// AllowOnError swallows the backend error and reports a zero count, as
+	// if the password had not been seen in any breach.
+	AllowOnError
+	// StaleCacheOnError answers from previously retrieved data, however
+	// old, when the Cache still has it (see StaleCache, and for a
+	// DiskCache, WithDiskCacheRetention); otherwise it behaves like
+	// DenyOnError.
+	StaleCacheOnError
+)
+
+// WithDegradedMode sets what Find returns when the backend fails, so the
+// trade-off between availability and strictness is made explicitly.
+func WithDegradedMode(mode DegradedMode) func(f *Finder) {
+	return func(f *Finder) {
+		f.degraded = mode
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDegradedMode(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	testCases := []struct {
+		name  string
+		mode  DegradedMode
+		exErr bool
+	}{
+		{
+			"deny",
+			DenyOnError,
+			true,
+		},
+		{
+			"allow",
+			AllowOnError,
+			false,
+		},
+		{
+			"stale without cache",
+			StaleCacheOnError,
+			true,
+		},
+	}
+
+	h := sha1.Sum([]byte("melobie"))
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			f := NewFinder(
+				WithClient(ts.Client()),
+				WithURLTemplate(fmt.Sprintf("%s/%%s", ts.URL)),
+				WithDegradedMode(tc.mode),
+			)
+			n, err := f.Find(h[:])
+			if n != 0 {
+				t.Errorf("expected 0: %d\n", n)
+			}
+			if tc.exErr != (err != nil) {
+				t.Errorf("expected error %t: %v\n", tc.exErr, err)
+			}
+
+			// Bad input is never papered over.
+			_, err = f.Find(h[:10])
+			if !errors.Is(err, io.ErrShortBuffer) {
+				t.Errorf("expected %v: %v\n", io.ErrShortBuffer, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"context"
+	"errors"
+)
+
+// DeltaRule decides which changes in the count of a hash are significant
+// enough to act on, such as by forcing a password reset after a new batch
+// of breaches has been added to the corpus.
+type DeltaRule struct {
+	// Factor flags a count that has grown to at least Factor times what it
+	// was (2 for doubled). Counts that were zero never trigger it; zero
+	// turns it off.
+	Factor float64
+	// Threshold flags a count that has gone above Threshold from at or
+	// below it; the zero value flags hashes seen for the first time. A
+	// negative Threshold turns it off.
+	Threshold int64
+}
+
+// CountChange describes a significant change in the count of a hash.
+type CountChange struct {
+	Hash     []byte
+	Previous int64
+	Current  int64
+	// Grew is set when the change met the rule's Factor.
+	Grew bool
+	// Crossed is set when the change crossed the rule's Threshold.
+	Crossed bool
+}
+
+// Significant reports how the change from previous to current meets the
+// rule, if at all.
+func (r DeltaRule) Significant(previous, current int64) (grew, crossed bool) {
+	grew = r.Factor > 0 && previous > 0 && float64(current) >= r.Factor*float64(previous)
+	crossed = r.Threshold >= 0 && previous <= r.Threshold && current > r.Threshold
+	return grew, crossed
+}
+
+// CheckDelta looks sum up again, given the count recorded for it before,
+// and calls fn if the change is significant according to rule. It returns
+// the current count, to be recorded for next time. A lookup that fails is
+// an error even under AllowOnError, here or in WithKillSwitch: the zero
+// Find would answer with isn't a count to record, and would read as a
+// change when the hash is next checked.
+func (f *Finder) CheckDelta(ctx context.Context, sum []byte, previous int64, rule DeltaRule, fn func(CountChange)) (int64, error) {
+	n, _, err := f.lookup(ctx, f.mode, sum)
+	var ue *unverifiedError
+	if errors.As(err, &ue) {
+		return 0, ue.err
+	}
+	if err != nil {
+		return 0, err
+	}
+	if grew, crossed := rule.Significant(previous, n); grew || crossed {
+		fn(CountChange{
+			Hash:     sum,
+			Previous: previous,
+			Current:  n,
+			Grew:     grew,
+			Crossed:  crossed,
+		})
+	}
+	return n, nil
+}
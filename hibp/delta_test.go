@@ -0,0 +1,145 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"context"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestDeltaRuleSignificant(t *testing.T) {
+	testCases := []struct {
+		name      string
+		rule      DeltaRule
+		previous  int64
+		current   int64
+		exGrew    bool
+		exCrossed bool
+	}{
+		{"first seen", DeltaRule{}, 0, 3, false, true},
+		{"unchanged", DeltaRule{Factor: 2}, 10, 10, false, false},
+		{"doubled", DeltaRule{Factor: 2, Threshold: -1}, 10, 20, true, false},
+		{"not quite doubled", DeltaRule{Factor: 2, Threshold: -1}, 10, 19, false, false},
+		{"from zero", DeltaRule{Factor: 2, Threshold: -1}, 0, 50, false, false},
+		{"crossed", DeltaRule{Threshold: 100}, 100, 101, false, true},
+		{"already over", DeltaRule{Threshold: 100}, 101, 150, false, false},
+		{"both", DeltaRule{Factor: 1.5, Threshold: 100}, 80, 120, true, true},
+		{"shrunk", DeltaRule{Factor: 2, Threshold: 5}, 10, 3, false, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			grew, crossed := tc.rule.Significant(tc.previous, tc.current)
+			if grew != tc.exGrew || crossed != tc.exCrossed {
+				t.Errorf("expected [%t, %t]: %t, %t\n", tc.exGrew, tc.exCrossed, grew, crossed)
+			}
+		})
+	}
+}
+
+func TestCheckDelta(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(data))
+	}))
+	defer ts.Close()
+
+	f := NewFinder(
+		WithClient(ts.Client()),
+		WithURLTemplate(fmt.Sprintf("%s/%%s", ts.URL)),
+	)
+	h := sha1.Sum([]byte("melobie"))
+	rule := DeltaRule{Factor: 2, Threshold: -1}
+
+	var changes []CountChange
+	record := func(c CountChange) { changes = append(changes, c) }
+
+	n, err := f.CheckDelta(context.Background(), h[:], 300, rule, record)
+	if n != 401 || err != nil {
+		t.Errorf("expected [401, nil]: %d, %v\n", n, err)
+	}
+	if len(changes) != 0 {
+		t.Errorf("expected no change: %v\n", changes)
+	}
+
+	n, err = f.CheckDelta(context.Background(), h[:], 200, rule, record)
+	if n != 401 || err != nil {
+		t.Errorf("expected [401, nil]: %d, %v\n", n, err)
+	}
+	if len(changes) != 1 {
+		t.Fatalf("expected 1 change: %v\n", changes)
+	}
+	if c := changes[0]; c.Previous != 200 || c.Current != 401 || !c.Grew || c.Crossed {
+		t.Errorf("unexpected: %+v\n", c)
+	}
+
+	if _, err = f.CheckDelta(context.Background(), h[:10], 0, rule, record); err == nil {
+		t.Errorf("expected error for short input\n")
+	}
+}
+
+func TestCheckDeltaUnverified(t *testing.T) {
+	var fail atomic.Bool
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(data))
+	}))
+	defer ts.Close()
+
+	var off atomic.Bool
+	f := NewFinder(
+		WithClient(ts.Client()),
+		WithURLTemplate(fmt.Sprintf("%s/%%s", ts.URL)),
+		WithDegradedMode(AllowOnError),
+		WithKillSwitch(off.Load, AllowOnError),
+	)
+	h := sha1.Sum([]byte("melobie"))
+
+	var changes []CountChange
+	record := func(c CountChange) { changes = append(changes, c) }
+
+	// A failed lookup isn't a count of zero, which would be recorded and
+	// then read as the hash being seen for the first time.
+	fail.Store(true)
+	if n, err := f.CheckDelta(context.Background(), h[:], 401, DeltaRule{}, record); n != 0 || !errors.Is(err, ErrUpstream) {
+		t.Errorf("expected [0, %v]: %d, %v\n", ErrUpstream, n, err)
+	}
+	fail.Store(false)
+	off.Store(true)
+	if n, err := f.CheckDelta(context.Background(), h[:], 401, DeltaRule{}, record); n != 0 || err != ErrDisabled {
+		t.Errorf("expected [0, %v]: %d, %v\n", ErrDisabled, n, err)
+	}
+	off.Store(false)
+	if n, err := f.CheckDelta(context.Background(), h[:], 401, DeltaRule{}, record); n != 401 || err != nil {
+		t.Errorf("expected [401, nil]: %d, %v\n", n, err)
+	}
+	if len(changes) != 0 {
+		t.Errorf("expected no change: %v\n", changes)
+	}
+}
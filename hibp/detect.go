@@ -0,0 +1,78 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+)
+
+// WithHashDetection turns the inference done by FindDigest on or off. It is
+// on by default; with it off, FindDigest is the same as FindContext.
+func WithHashDetection(enabled bool) func(f *Finder) {
+	return func(f *Finder) {
+		f.noDetect = !enabled
+	}
+}
+
+// FindDigest is like FindContext, but works out from the length of input
+// what kind of hash it was given, regardless of the Finder's HashMode:
+//
+//	16 bytes  an NTLM hash
+//	20 bytes  a sha1.Sum()
+//	32 bytes  a hex encoded NTLM hash
+//	40 bytes  a hex encoded SHA1
+//
+// That spares callers juggling several hash formats from tracking which is
+// which. Inputs of any other length are rejected as by FindContext.
+func (f *Finder) FindDigest(ctx context.Context, input []byte) (int64, error) {
+	if f.noDetect {
+		return f.FindContext(ctx, input)
+	}
+	mode, sum, err := detectHash(input)
+	if err != nil {
+		return 0, err
+	}
+	if sum == nil {
+		return f.FindContext(ctx, input)
+	}
+	n, _, err := f.find(ctx, mode, sum)
+	return n, err
+}
+
+// detectHash infers the mode of input from its length, decoding it if it
+// is hex encoded. A nil sum means the length matches no known form.
+func detectHash(input []byte) (HashMode, []byte, error) {
+	switch len(input) {
+	case ntlmSize:
+		return NTLM, input, nil
+	case sha1.Size:
+		return SHA1, input, nil
+	case hex.EncodedLen(ntlmSize):
+		sum, err := normalizeHex(string(input), ntlmSize)
+		return NTLM, sum, err
+	case hex.EncodedLen(sha1.Size):
+		sum, err := normalizeHex(string(input), sha1.Size)
+		return SHA1, sum, err
+	}
+	return 0, nil, nil
+}
@@ -0,0 +1,132 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"context"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFindDigest(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("mode") != "ntlm" {
+			w.Write([]byte(data))
+			return
+		}
+		w.Write([]byte(ntlmData))
+	}))
+	defer ts.Close()
+
+	sha := sha1.Sum([]byte("melobie"))
+	ntlm := ntlmSum("password")
+	long := append(sha[:], 0)
+
+	testCases := []struct {
+		name    string
+		input   []byte
+		exCount int64
+		exErr   error
+	}{
+		{
+			"ntlm",
+			ntlm[:],
+			2597813,
+			nil,
+		},
+		{
+			"sha1",
+			sha[:],
+			401,
+			nil,
+		},
+		{
+			"hex ntlm",
+			[]byte("8846f7eaee8fb117ad06bdd830b7586c"),
+			2597813,
+			nil,
+		},
+		{
+			"hex sha1",
+			[]byte(fmt.Sprintf("%x", sha)),
+			401,
+			nil,
+		},
+		{
+			"bad hex",
+			[]byte(strings.Repeat("Z", 40)),
+			0,
+			ErrInvalidHex,
+		},
+		{
+			"short",
+			sha[:10],
+			0,
+			io.ErrShortBuffer,
+		},
+		{
+			"long",
+			long,
+			0,
+			io.ErrShortWrite,
+		},
+	}
+
+	// The Finder's own mode doesn't matter.
+	for _, mode := range []HashMode{SHA1, NTLM} {
+		f := NewFinder(
+			WithClient(ts.Client()),
+			WithURLTemplate(fmt.Sprintf("%s/%%s", ts.URL)),
+			WithHashMode(mode),
+		)
+		for _, tc := range testCases {
+			t.Run(fmt.Sprintf("%d/%s", mode, tc.name), func(t *testing.T) {
+				n, err := f.FindDigest(context.Background(), tc.input)
+				if n != tc.exCount {
+					t.Errorf("expected %d: %d\n", tc.exCount, n)
+				}
+				if !errors.Is(err, tc.exErr) {
+					t.Errorf("expected %v: %v\n", tc.exErr, err)
+				}
+			})
+		}
+	}
+}
+
+func TestFindDigestDisabled(t *testing.T) {
+	f := NewFinder(WithHashDetection(false))
+
+	ntlm := ntlmSum("password")
+	_, err := f.FindDigest(context.Background(), ntlm[:])
+	if !errors.Is(err, io.ErrShortBuffer) {
+		t.Errorf("expected %v: %v\n", io.ErrShortBuffer, err)
+	}
+	_, err = f.FindDigest(context.Background(), []byte(strings.Repeat("0", 40)))
+	if !errors.Is(err, io.ErrShortWrite) {
+		t.Errorf("expected %v: %v\n", io.ErrShortWrite, err)
+	}
+}
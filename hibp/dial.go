@@ -0,0 +1,331 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// preResolveTimeout bounds the lookup done by WithPreResolve, so a slow
+// resolver can't hold up NewFinder for long.
+const preResolveTimeout = 5 * time.Second
+
+// fallbackDelay is how long the first address family dialed is given
+// before the other is dialed alongside it; it is net.Dialer's default.
+const fallbackDelay = 300 * time.Millisecond
+
+// WithDNSCache keeps the addresses resolved for a host for up to ttl, so
+// resolver latency or outages don't slow down or fail every password
+// check.
+//
+// It takes effect by wrapping the dialer of the Finder's http.Client,
+// which is only possible when its Transport is nil or an *http.Transport.
+// A dial function the transport already has is still used to connect.
+func WithDNSCache(ttl time.Duration) func(f *Finder) {
+	return func(f *Finder) {
+		f.dialer().ttl = ttl
+	}
+}
+
+// WithPreResolve resolves the backend's host while the Finder is being
+// created, so the first lookup doesn't pay for it. It is best effort: a
+// failure here is retried on first use. It only has an effect together
+// with WithDNSCache.
+func WithPreResolve() func(f *Finder) {
+	return func(f *Finder) {
+		f.dialer().preResolve = true
+	}
+}
+
+// IPPreference selects which address family is tried first when the
+// backend's host resolves to both IPv4 and IPv6 addresses.
+type IPPreference int
+
+const (
+	// DualStack races both families as the standard library does ("Happy
+	// Eyeballs"), giving the family of the first address resolved a head
+	// start. This is the default.
+	DualStack IPPreference = iota
+	// PreferIPv4 gives IPv4 addresses the head start instead.
+	PreferIPv4
+	// PreferIPv6 gives IPv6 addresses the head start instead.
+	PreferIPv6
+)
+
+// WithIPPreference picks the order in which address families are dialed.
+// Preferring IPv4 helps on networks with broken IPv6 routes, where
+// connection attempts hang rather than failing fast. Either way, the other
+// family is dialed too if the preferred one hasn't connected within a
+// short delay.
+//
+// Like WithDNSCache, it needs the Finder's http.Client to use an
+// *http.Transport (or the default one).
+func WithIPPreference(pref IPPreference) func(f *Finder) {
+	return func(f *Finder) {
+		f.dialer().pref = pref
+	}
+}
+
+func (f *Finder) dialer() *dialer {
+	if f.dial == nil {
+		f.dial = newDialer()
+	}
+	return f.dial
+}
+
+type dnsEntry struct {
+	addrs   []string
+	expires time.Time
+}
+
+// dialer connects to backends on behalf of an http.Transport, applying the
+// connection related options.
+type dialer struct {
+	ttl        time.Duration
+	preResolve bool
+	pref       IPPreference
+	clock      Clock
+
+	lookup func(ctx context.Context, host string) ([]string, error)
+	dial   func(ctx context.Context, network, addr string) (net.Conn, error)
+
+	mu    sync.Mutex
+	hosts map[string]dnsEntry
+}
+
+func newDialer() *dialer {
+	d := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+	return &dialer{
+		lookup: net.DefaultResolver.LookupHost,
+		dial:   d.DialContext,
+		clock:  systemClock{},
+		hosts:  map[string]dnsEntry{},
+	}
+}
+
+// wrap returns a copy of client that dials through d. The client is
+// returned unchanged if its transport can't be adjusted. Connections are
+// still made by the transport's own dial function, if it has one; d only
+// picks the addresses.
+func (d *dialer) wrap(client *http.Client) *http.Client {
+	var base *http.Transport
+	switch t := client.Transport.(type) {
+	case nil:
+		base, _ = http.DefaultTransport.(*http.Transport)
+	case *http.Transport:
+		base = t
+	}
+	if base == nil {
+		return client
+	}
+	switch {
+	case base.DialContext != nil:
+		d.dial = base.DialContext
+	case base.Dial != nil:
+		dial := base.Dial
+		d.dial = func(_ context.Context, network, addr string) (net.Conn, error) {
+			return dial(network, addr)
+		}
+	}
+	tr := base.Clone()
+	tr.DialContext = d.DialContext
+	c := *client
+	c.Transport = tr
+	return &c
+}
+
+// warm resolves the host of rawURL ahead of time, if asked to.
+func (d *dialer) warm(rawURL string) {
+	if !d.preResolve || d.ttl <= 0 {
+		return
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || net.ParseIP(u.Hostname()) != nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), preResolveTimeout)
+	defer cancel()
+	d.resolve(ctx, u.Hostname())
+}
+
+// DialContext matches the signature of http.Transport.DialContext.
+func (d *dialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil || net.ParseIP(host) != nil || (d.ttl <= 0 && d.pref == DualStack) {
+		return d.dial(ctx, network, addr)
+	}
+	var addrs []string
+	if d.ttl > 0 {
+		addrs, err = d.resolve(ctx, host)
+	} else {
+		addrs, err = d.lookup(ctx, host)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(addrs) == 0 {
+		return nil, &net.DNSError{Err: "no addresses", Name: host, IsNotFound: true}
+	}
+	primary, fallback := d.families(addrs)
+	if len(fallback) == 0 {
+		return d.dialSerial(ctx, network, port, primary)
+	}
+	return d.dialParallel(ctx, network, port, primary, fallback)
+}
+
+// dialSerial dials addrs in turn, returning the first connection made, or
+// the last error.
+func (d *dialer) dialSerial(ctx context.Context, network, port string, addrs []string) (net.Conn, error) {
+	var err error
+	for _, a := range addrs {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		var conn net.Conn
+		conn, err = d.dial(ctx, network, net.JoinHostPort(a, port))
+		if err == nil {
+			return conn, nil
+		}
+	}
+	return nil, err
+}
+
+// dialParallel races the two address families, as net.Dialer does ("Happy
+// Eyeballs", RFC 8305): primary is dialed first, and fallback alongside it
+// once primary has failed or fallbackDelay has passed. The first
+// connection made wins, and the other dial is canceled. If both fail, the
+// error from primary is returned.
+func (d *dialer) dialParallel(ctx context.Context, network, port string, primary, fallback []string) (net.Conn, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type dialResult struct {
+		conn    net.Conn
+		err     error
+		primary bool
+	}
+	// Buffered, so the loser doesn't block once the winner is returned.
+	results := make(chan dialResult, 2)
+	race := func(addrs []string, primary bool) {
+		conn, err := d.dialSerial(ctx, network, port, addrs)
+		results <- dialResult{conn, err, primary}
+	}
+
+	go race(primary, true)
+	pending := 1
+	delay := d.clock.After(fallbackDelay)
+	var firstErr error
+	for {
+		select {
+		case <-delay:
+			delay = nil
+			go race(fallback, false)
+			pending++
+		case res := <-results:
+			pending--
+			if res.err == nil {
+				// A loser that connects anyway is closed.
+				go func(n int) {
+					for ; n > 0; n-- {
+						if r := <-results; r.conn != nil {
+							r.conn.Close()
+						}
+					}
+				}(pending)
+				return res.conn, nil
+			}
+			if firstErr == nil || res.primary {
+				firstErr = res.err
+			}
+			if delay != nil {
+				// No need to wait: primary has failed.
+				delay = nil
+				go race(fallback, false)
+				pending++
+			}
+			if pending == 0 {
+				return nil, firstErr
+			}
+		}
+	}
+}
+
+func (d *dialer) resolve(ctx context.Context, host string) ([]string, error) {
+	now := d.clock.Now()
+	d.mu.Lock()
+	e, ok := d.hosts[host]
+	d.mu.Unlock()
+	if ok && now.Before(e.expires) {
+		return e.addrs, nil
+	}
+
+	addrs, err := d.lookup(ctx, host)
+	if err != nil {
+		if ok {
+			// Riding out a resolver outage on the last known addresses
+			// beats failing the check.
+			return e.addrs, nil
+		}
+		return nil, err
+	}
+	d.mu.Lock()
+	d.hosts[host] = dnsEntry{addrs: addrs, expires: now.Add(d.ttl)}
+	d.mu.Unlock()
+	return addrs, nil
+}
+
+// families splits addrs into those of the preferred address family, or
+// for DualStack, of the family of the first address, and the rest, keeping
+// the resolver's order within each. If none are of the preferred family,
+// they are all primary.
+func (d *dialer) families(addrs []string) (primary, fallback []string) {
+	v4 := isIPv4(addrs[0])
+	switch d.pref {
+	case PreferIPv4:
+		v4 = true
+	case PreferIPv6:
+		v4 = false
+	}
+	for _, a := range addrs {
+		if isIPv4(a) == v4 {
+			primary = append(primary, a)
+		} else {
+			fallback = append(fallback, a)
+		}
+	}
+	if len(primary) == 0 {
+		return fallback, nil
+	}
+	return primary, fallback
+}
+
+func isIPv4(addr string) bool {
+	ip := net.ParseIP(addr)
+	return ip != nil && ip.To4() != nil
+}
@@ -0,0 +1,358 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"context"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDNSCache(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(data))
+	}))
+	defer ts.Close()
+	u, _ := url.Parse(ts.URL)
+
+	clock := newFakeClock()
+	lookups := 0
+	failLookup := false
+	f := NewFinder(
+		WithClock(clock),
+		WithClient(ts.Client()),
+		WithURLTemplate(fmt.Sprintf("http://hibp.test:%s/%%s", u.Port())),
+		WithDNSCache(time.Minute),
+		func(f *Finder) {
+			f.dial.lookup = func(ctx context.Context, host string) ([]string, error) {
+				lookups++
+				if failLookup {
+					return nil, errors.New("resolver down")
+				}
+				if host != "hibp.test" {
+					t.Errorf("unexpected host: %s\n", host)
+				}
+				return []string{u.Hostname()}, nil
+			}
+		},
+	)
+	// Make every lookup dial a fresh connection.
+	f.conn.Transport.(*http.Transport).DisableKeepAlives = true
+
+	h := sha1.Sum([]byte("melobie"))
+	for i := 0; i < 3; i++ {
+		n, err := f.Find(h[:])
+		if n != 401 || err != nil {
+			t.Fatalf("expected [401, nil]: %d, %v\n", n, err)
+		}
+	}
+	if lookups != 1 {
+		t.Errorf("expected 1 lookup: %d\n", lookups)
+	}
+
+	// Let the entry expire and take the resolver down; the stale addresses
+	// are still used.
+	clock.Advance(time.Minute)
+	failLookup = true
+
+	n, err := f.Find(h[:])
+	if n != 401 || err != nil {
+		t.Errorf("expected [401, nil]: %d, %v\n", n, err)
+	}
+	if lookups != 2 {
+		t.Errorf("expected 2 lookups: %d\n", lookups)
+	}
+}
+
+func TestDNSCachePreResolve(t *testing.T) {
+	d := newDialer()
+	d.ttl = time.Minute
+	d.preResolve = true
+	lookups := 0
+	d.lookup = func(ctx context.Context, host string) ([]string, error) {
+		lookups++
+		return []string{"127.0.0.1"}, nil
+	}
+
+	d.warm("https://api.pwnedpasswords.com/range/")
+	d.warm("https://127.0.0.1/range/")
+	if lookups != 1 {
+		t.Errorf("expected 1 lookup: %d\n", lookups)
+	}
+	if _, ok := d.hosts["api.pwnedpasswords.com"]; !ok {
+		t.Errorf("expected host to be cached")
+	}
+}
+
+func TestDialerWrap(t *testing.T) {
+	d := newDialer()
+
+	c := d.wrap(http.DefaultClient)
+	if c == http.DefaultClient {
+		t.Errorf("expected a copy of the default client")
+	}
+	if http.DefaultClient.Transport != nil {
+		t.Errorf("expected default client to be untouched")
+	}
+	if _, ok := c.Transport.(*http.Transport); !ok {
+		t.Errorf("expected *http.Transport: %T\n", c.Transport)
+	}
+
+	custom := &http.Client{Transport: roundTripper(func(*http.Request) (*http.Response, error) {
+		return nil, net.ErrClosed
+	})}
+	if d.wrap(custom) != custom {
+		t.Errorf("expected custom transport to be left alone")
+	}
+}
+
+func TestDialerWrapTransport(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	var nd net.Dialer
+	testCases := []struct {
+		name string
+		tr   func(dial func(ctx context.Context, network, addr string) (net.Conn, error)) *http.Transport
+	}{
+		{
+			"DialContext",
+			func(dial func(ctx context.Context, network, addr string) (net.Conn, error)) *http.Transport {
+				return &http.Transport{DialContext: dial}
+			},
+		},
+		{
+			"Dial",
+			func(dial func(ctx context.Context, network, addr string) (net.Conn, error)) *http.Transport {
+				return &http.Transport{Dial: func(network, addr string) (net.Conn, error) {
+					return dial(context.Background(), network, addr)
+				}}
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dials := 0
+			tr := tc.tr(func(ctx context.Context, network, addr string) (net.Conn, error) {
+				dials++
+				return nd.DialContext(ctx, network, addr)
+			})
+			d := newDialer()
+			d.pref = PreferIPv4
+			c := d.wrap(&http.Client{Transport: tr})
+			if c.Transport == tr {
+				t.Fatalf("expected a copy of the transport")
+			}
+
+			resp, err := c.Get(ts.URL)
+			if err != nil {
+				t.Fatalf("expected nil: %v\n", err)
+			}
+			resp.Body.Close()
+			if dials != 1 {
+				t.Errorf("expected 1 dial through the transport's dialer: %d\n", dials)
+			}
+		})
+	}
+}
+
+func TestIPPreference(t *testing.T) {
+	addrs := []string{"2001:db8::1", "192.0.2.1", "2001:db8::2", "192.0.2.2"}
+
+	testCases := []struct {
+		name string
+		pref IPPreference
+		exp  []string
+	}{
+		{
+			"dual stack",
+			DualStack,
+			[]string{"2001:db8::1", "192.0.2.1", "2001:db8::2", "192.0.2.2"},
+		},
+		{
+			"ipv4",
+			PreferIPv4,
+			[]string{"192.0.2.1", "192.0.2.2", "2001:db8::1", "2001:db8::2"},
+		},
+		{
+			"ipv6",
+			PreferIPv6,
+			[]string{"2001:db8::1", "2001:db8::2", "192.0.2.1", "192.0.2.2"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := newDialer()
+			d.pref = tc.pref
+			d.lookup = func(ctx context.Context, host string) ([]string, error) {
+				return addrs, nil
+			}
+			var dialed []string
+			d.dial = func(ctx context.Context, network, addr string) (net.Conn, error) {
+				dialed = append(dialed, addr)
+				return nil, errors.New("unreachable")
+			}
+
+			_, err := d.DialContext(context.Background(), "tcp", "hibp.test:443")
+			if err == nil {
+				t.Errorf("expected error")
+			}
+			if tc.pref == DualStack {
+				// Left to the standard dialer.
+				tc.exp = []string{"hibp.test:443"}
+			} else {
+				for i, a := range tc.exp {
+					tc.exp[i] = net.JoinHostPort(a, "443")
+				}
+			}
+			if fmt.Sprint(dialed) != fmt.Sprint(tc.exp) {
+				t.Errorf("expected %v: %v\n", tc.exp, dialed)
+			}
+		})
+	}
+}
+
+type roundTripper func(*http.Request) (*http.Response, error)
+
+func (rt roundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+	return rt(r)
+}
+
+func TestDialParallel(t *testing.T) {
+	errV6 := errors.New("no route to host")
+
+	testCases := []struct {
+		name    string
+		v6      string // hang, fail or connect
+		v4      string
+		advance bool
+		exErr   error
+		exAddr  string
+	}{
+		{
+			"hung primary",
+			"hang",
+			"connect",
+			true,
+			nil,
+			"192.0.2.1:443",
+		},
+		{
+			"failed primary",
+			"fail",
+			"connect",
+			false,
+			nil,
+			"192.0.2.1:443",
+		},
+		{
+			"primary first",
+			"connect",
+			"hang",
+			false,
+			nil,
+			"[2001:db8::1]:443",
+		},
+		{
+			"both fail",
+			"fail",
+			"fail",
+			false,
+			errV6,
+			"",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			clock := newFakeClock()
+			d := newDialer()
+			d.ttl = time.Minute
+			d.clock = clock
+			d.lookup = func(ctx context.Context, host string) ([]string, error) {
+				return []string{"2001:db8::1", "192.0.2.1"}, nil
+			}
+			canceled := make(chan string, 2)
+			d.dial = func(ctx context.Context, network, addr string) (net.Conn, error) {
+				behavior := tc.v4
+				if strings.HasPrefix(addr, "[") {
+					behavior = tc.v6
+				}
+				switch behavior {
+				case "hang":
+					<-ctx.Done()
+					canceled <- addr
+					return nil, ctx.Err()
+				case "fail":
+					if strings.HasPrefix(addr, "[") {
+						return nil, errV6
+					}
+					return nil, errors.New("connection refused")
+				}
+				c, _ := net.Pipe()
+				return &addrConn{c, addr}, nil
+			}
+
+			if tc.advance {
+				go func() {
+					for clock.pending() == 0 {
+						time.Sleep(time.Millisecond)
+					}
+					clock.Advance(fallbackDelay)
+				}()
+			}
+			conn, err := d.DialContext(context.Background(), "tcp", "hibp.test:443")
+			if err != tc.exErr {
+				t.Fatalf("expected %v: %v\n", tc.exErr, err)
+			}
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			if got := conn.(*addrConn).addr; got != tc.exAddr {
+				t.Errorf("expected %s: %s\n", tc.exAddr, got)
+			}
+			if tc.v6 == "hang" {
+				select {
+				case <-canceled:
+				case <-time.After(time.Second):
+					t.Errorf("expected the losing dial to be canceled")
+				}
+			}
+		})
+	}
+}
+
+// addrConn is a net.Conn that remembers the address it was dialed at.
+type addrConn struct {
+	net.Conn
+	addr string
+}
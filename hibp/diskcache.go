@@ -0,0 +1,348 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// diskCacheExt marks the files of a DiskCache; anything else in its
+// directory is left alone.
+const diskCacheExt = ".range"
+
+// diskCacheTmp marks the temporary files a DiskCache writes bodies to
+// before renaming them into place. Ones older than diskCacheTmpAge were
+// left behind by a process that died, and are removed.
+const (
+	diskCacheTmp    = ".tmp"
+	diskCacheTmpAge = time.Hour
+)
+
+// diskCacheRescan is how often a DiskCache rereads its directory, to see
+// files written by other processes and remove expired ones.
+const diskCacheRescan = time.Minute
+
+// DiskCache is a Cache of range bodies stored as files in a directory, so
+// they survive restarts and can be reused by later runs of a program (given
+// the same WithCacheSecret). Files are fresh for the TTL after they were
+// written. Once expired, they are kept for the cache's retention period
+// (see WithDiskCacheRetention; none by default), and then removed when
+// they are next read, or the directory next reread, or by Purge. Files are
+// also removed, soonest expiring first, when the cache grows over its
+// maximum size. A file's modification time is set to when it expires. It
+// is safe for concurrent use.
+//
+// Several processes on a host may share a directory without any locking.
+// Bodies are renamed into place whole, and each file carries its own
+// expiry, so a reader always sees a complete body along with the expiry
+// it was written with, whichever process wrote it. Each process keeps the
+// directory under maxSize, rereading it every minute or so, so the
+// directory can briefly outgrow maxSize by what the others have written
+// meanwhile.
+type DiskCache struct {
+	dir     string
+	ttl     time.Duration
+	maxSize int64
+	retain  time.Duration
+	clock   Clock
+
+	hits   uint64
+	misses uint64
+
+	mu      sync.Mutex // guards the fields below
+	files   map[string]diskFile
+	total   int64
+	scanned time.Time
+}
+
+type diskFile struct {
+	size    int64
+	expires time.Time
+}
+
+// NewDiskCache returns a DiskCache in dir, which is created if needed.
+// Bodies are fresh for ttl unless stored with a TTL of their own, and the
+// files take up to maxSize bytes in all; zero means no limit. Files
+// already in dir from an earlier run are used, or removed if they are past
+// retention.
+func NewDiskCache(dir string, ttl time.Duration, maxSize int64, options ...func(*DiskCache)) (*DiskCache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	c := &DiskCache{
+		dir:     dir,
+		ttl:     ttl,
+		maxSize: maxSize,
+		clock:   systemClock{},
+	}
+	for _, opt := range options {
+		opt(c)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.load(infos)
+	c.evict()
+	return c, nil
+}
+
+// WithDiskCacheClock replaces the system clock the DiskCache expires files
+// by. (A cache may be shared by Finders, so it doesn't take the clock of a
+// Finder it is given to; see WithClock.)
+func WithDiskCacheClock(clock Clock) func(c *DiskCache) {
+	return func(c *DiskCache) {
+		c.clock = clock
+	}
+}
+
+// WithDiskCacheRetention keeps files for d after they expire, so that
+// StaleCacheOnError can still answer from them while the backend is down.
+// By default, a file is removed once it has expired, which keeps what the
+// cache holds on disk to what it can use.
+func WithDiskCacheRetention(d time.Duration) func(c *DiskCache) {
+	return func(c *DiskCache) {
+		c.retain = d
+	}
+}
+
+// retained reports whether a file that expires at expires is still to be
+// kept at now.
+func (c *DiskCache) retained(expires, now time.Time) bool {
+	return now.Before(expires.Add(c.retain))
+}
+
+// load replaces what the cache knows of its directory with infos, the
+// directory's contents, and removes files past retention and abandoned
+// temporary files. It must be called with mu held.
+func (c *DiskCache) load(infos []os.FileInfo) {
+	c.files, c.total = map[string]diskFile{}, 0
+	now := c.clock.Now()
+	c.scanned = now
+	for _, fi := range infos {
+		name := fi.Name()
+		switch {
+		case !fi.Mode().IsRegular():
+		case strings.HasSuffix(name, diskCacheExt) && !c.retained(fi.ModTime(), now):
+			removeFile(filepath.Join(c.dir, name))
+		case strings.HasSuffix(name, diskCacheExt):
+			c.files[name] = diskFile{fi.Size(), fi.ModTime()}
+			c.total += fi.Size()
+		case strings.Contains(name, diskCacheExt+diskCacheTmp) && now.Sub(fi.ModTime()) > diskCacheTmpAge:
+			removeFile(filepath.Join(c.dir, name))
+		}
+	}
+}
+
+// name returns the file name for key: a hex encoded SHA-256 of it, in lower
+// case, so that any key makes a valid file name everywhere, keys that
+// differ only in case don't collide on case-insensitive file systems, and
+// the key can't be read back from the name. (A Finder's keys are HMACs of
+// the range already, see WithCacheSecret; this keeps other keys out of
+// directory listings too.)
+func (c *DiskCache) name(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:]) + diskCacheExt
+}
+
+// Get implements Cache.
+func (c *DiskCache) Get(key string) ([]byte, bool) {
+	body, expires, err := c.read(c.name(key))
+	if err != nil || !c.clock.Now().Before(expires) {
+		atomic.AddUint64(&c.misses, 1)
+		return nil, false
+	}
+	atomic.AddUint64(&c.hits, 1)
+	return body, true
+}
+
+// GetStale implements StaleCache. It doesn't count as a hit or a miss.
+func (c *DiskCache) GetStale(key string) ([]byte, bool) {
+	body, _, err := c.read(c.name(key))
+	if err != nil {
+		return nil, false
+	}
+	return body, true
+}
+
+// read returns the body in the named file and when it expires. A file
+// past retention is removed instead. A file written by another process is
+// noted, for eviction.
+func (c *DiskCache) read(name string) ([]byte, time.Time, error) {
+	body, fi, err := readFile(filepath.Join(c.dir, name))
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.retained(fi.ModTime(), c.clock.Now()) {
+		c.remove(name)
+		return nil, time.Time{}, os.ErrNotExist
+	}
+	if _, ok := c.files[name]; !ok {
+		c.files[name] = diskFile{fi.Size(), fi.ModTime()}
+		c.total += fi.Size()
+	}
+	return body, fi.ModTime(), nil
+}
+
+// readFile returns the contents of the file at path, and its FileInfo.
+// Both come from the same open file, so a concurrent rename of a newer
+// body into place, by this process or another, can't mix the two up.
+func readFile(path string) ([]byte, os.FileInfo, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+	fi, err := file.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+	body, err := ioutil.ReadAll(file)
+	if err != nil {
+		return nil, nil, err
+	}
+	return body, fi, nil
+}
+
+// Set implements Cache. The body is written to a temporary file that is
+// then renamed into place, so readers never see part of one. On Windows,
+// where a file can't be replaced while it is being read, the rename is
+// retried briefly. Failures to write are ignored; the body just isn't
+// cached.
+func (c *DiskCache) Set(key string, body []byte, ttl time.Duration) {
+	if ttl <= 0 {
+		ttl = c.ttl
+	}
+	if ttl <= 0 || (c.maxSize > 0 && int64(len(body)) > c.maxSize) {
+		return
+	}
+	now := c.clock.Now()
+	expires := now.Add(ttl)
+	name := c.name(key)
+	path := filepath.Join(c.dir, name)
+	tmp, err := ioutil.TempFile(c.dir, name+diskCacheTmp+"*")
+	if err != nil {
+		return
+	}
+	_, err = tmp.Write(body)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chtimes(tmp.Name(), now, expires)
+	}
+	if err == nil {
+		err = replaceFile(tmp.Name(), path)
+	}
+	if err != nil {
+		removeFile(tmp.Name())
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.total -= c.files[name].size
+	c.files[name] = diskFile{int64(len(body)), expires}
+	c.total += int64(len(body))
+	c.evict()
+}
+
+// Delete implements Cache.
+func (c *DiskCache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.remove(c.name(key))
+}
+
+// Purge removes the files that are past retention now, rather than
+// waiting for them to be read or for the directory to be reread.
+func (c *DiskCache) Purge() error {
+	infos, err := ioutil.ReadDir(c.dir)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.load(infos)
+	return nil
+}
+
+// remove removes the named file. It must be called with mu held.
+func (c *DiskCache) remove(name string) {
+	removeFile(filepath.Join(c.dir, name))
+	c.total -= c.files[name].size
+	delete(c.files, name)
+}
+
+// evict removes the soonest expiring files until the cache fits in
+// maxSize. Other processes sharing the directory may have added or
+// removed files, so it is reread first if it is over; it is also reread,
+// removing files past retention, if it hasn't been for diskCacheRescan.
+// It must be called with mu held.
+func (c *DiskCache) evict() {
+	now := c.clock.Now()
+	if (c.maxSize > 0 && c.total > c.maxSize) || now.Sub(c.scanned) >= diskCacheRescan || now.Before(c.scanned) {
+		if infos, err := ioutil.ReadDir(c.dir); err == nil {
+			c.load(infos)
+		}
+	}
+	for c.maxSize > 0 && c.total > c.maxSize && len(c.files) > 0 {
+		var oldest string
+		for name, f := range c.files {
+			if oldest == "" || f.expires.Before(c.files[oldest].expires) {
+				oldest = name
+			}
+		}
+		c.remove(oldest)
+	}
+}
+
+// Size returns the number of bytes the cache's files take up.
+func (c *DiskCache) Size() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.total
+}
+
+// Stats returns the number of hits and misses so far, and the number and
+// size of the cache's files.
+func (c *DiskCache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return CacheStats{
+		Hits:    atomic.LoadUint64(&c.hits),
+		Misses:  atomic.LoadUint64(&c.misses),
+		Entries: len(c.files),
+		Bytes:   c.total,
+	}
+}
@@ -0,0 +1,319 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDiskCache(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "ranges")
+	clock := newFakeClock()
+	c, err := NewDiskCache(dir, time.Minute, 0, WithDiskCacheClock(clock), WithDiskCacheRetention(time.Hour))
+	if err != nil {
+		t.Fatalf("unexpected: %v\n", err)
+	}
+
+	if _, ok := c.Get("sha1:21BD1"); ok {
+		t.Errorf("expected a miss\n")
+	}
+	c.Set("sha1:21BD1", []byte(data), 0)
+	if body, ok := c.Get("sha1:21BD1"); !ok || string(body) != data {
+		t.Errorf("expected [data, true]: %q, %t\n", body, ok)
+	}
+
+	clock.Advance(time.Minute)
+	if _, ok := c.Get("sha1:21BD1"); ok {
+		t.Errorf("expected expired entry to miss\n")
+	}
+	if body, ok := c.GetStale("sha1:21BD1"); !ok || string(body) != data {
+		t.Errorf("expected [data, true]: %q, %t\n", body, ok)
+	}
+	if exp, got := (CacheStats{Hits: 1, Misses: 2, Entries: 1, Bytes: int64(len(data))}), c.Stats(); got != exp {
+		t.Errorf("expected %+v: %+v\n", exp, got)
+	}
+
+	// An entry's own TTL overrides the default, and survives reopening.
+	c.Set("sha1:FFFFF", []byte(data), time.Hour)
+	clock.Advance(30 * time.Minute)
+	if _, ok := c.Get("sha1:FFFFF"); !ok {
+		t.Errorf("expected entry fresh for its own TTL\n")
+	}
+	reopened, err := NewDiskCache(dir, time.Minute, 0, WithDiskCacheClock(clock), WithDiskCacheRetention(time.Hour))
+	if err != nil {
+		t.Fatalf("unexpected: %v\n", err)
+	}
+	if _, ok := reopened.Get("sha1:FFFFF"); !ok {
+		t.Errorf("expected entry fresh after reopening\n")
+	}
+	c.Delete("sha1:FFFFF")
+	if _, ok := c.GetStale("sha1:FFFFF"); ok || c.Size() != int64(len(data)) {
+		t.Errorf("expected entry deleted: %t, %d\n", ok, c.Size())
+	}
+
+	// Nothing but the cache file is left behind.
+	names, _ := ioutil.ReadDir(dir)
+	if len(names) != 1 || !strings.HasSuffix(names[0].Name(), diskCacheExt) {
+		t.Errorf("expected a single cache file: %v\n", names)
+	}
+	if name := names[0].Name(); strings.Contains(name, hex.EncodeToString([]byte("sha1:21BD1"))) {
+		t.Errorf("expected the key hidden: %s\n", name)
+	}
+}
+
+func TestDiskCacheFinders(t *testing.T) {
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Write([]byte(data))
+	}))
+	defer ts.Close()
+
+	dir := t.TempDir()
+	run := func(secret string) {
+		c, err := NewDiskCache(dir, time.Hour, 0)
+		if err != nil {
+			t.Fatalf("unexpected: %v\n", err)
+		}
+		f := NewFinder(
+			WithClient(ts.Client()),
+			WithURLTemplate(fmt.Sprintf("%s/%%s", ts.URL)),
+			WithCache(c),
+			WithCacheSecret([]byte(secret)),
+		)
+		if n, err := f.FindPassword("melobie"); n != 401 || err != nil {
+			t.Errorf("expected [401, nil]: %d, %v\n", n, err)
+		}
+	}
+
+	// A later run with the same secret finds the body, one with another
+	// secret doesn't.
+	run("s3cret")
+	run("s3cret")
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Errorf("expected 1 call: %d\n", got)
+	}
+	run("other")
+	if got := atomic.LoadInt32(&calls); got != 2 {
+		t.Errorf("expected 2 calls: %d\n", got)
+	}
+}
+
+func TestDiskCacheReopen(t *testing.T) {
+	dir := t.TempDir()
+	c, err := NewDiskCache(dir, time.Hour, 0)
+	if err != nil {
+		t.Fatalf("unexpected: %v\n", err)
+	}
+	c.Set("ntlm+padding:21BD1", []byte(ntlmData), 0)
+	ioutil.WriteFile(filepath.Join(dir, "README"), []byte("not a range"), 0o644)
+
+	c, err = NewDiskCache(dir, time.Hour, 0)
+	if err != nil {
+		t.Fatalf("unexpected: %v\n", err)
+	}
+	if body, ok := c.Get("ntlm+padding:21BD1"); !ok || string(body) != ntlmData {
+		t.Errorf("expected [ntlmData, true]: %q, %t\n", body, ok)
+	}
+	if exp := int64(len(ntlmData)); c.Size() != exp {
+		t.Errorf("expected %d bytes: %d\n", exp, c.Size())
+	}
+}
+
+func TestDiskCacheEviction(t *testing.T) {
+	dir := t.TempDir()
+	size := int64(len(data))
+	c, err := NewDiskCache(dir, time.Hour, 2*size)
+	if err != nil {
+		t.Fatalf("unexpected: %v\n", err)
+	}
+	clock := newFakeClock()
+	c.clock = clock
+
+	for _, key := range []string{"a", "b", "c"} {
+		c.Set(key, []byte(data), 0)
+		clock.Advance(time.Second)
+	}
+	if _, ok := c.GetStale("a"); ok {
+		t.Errorf("expected the oldest to be evicted\n")
+	}
+	for _, key := range []string{"b", "c"} {
+		if _, ok := c.Get(key); !ok {
+			t.Errorf("expected %s to be kept\n", key)
+		}
+	}
+	if c.Size() != 2*size {
+		t.Errorf("expected %d bytes: %d\n", 2*size, c.Size())
+	}
+
+	// Overwriting doesn't count twice.
+	c.Set("c", []byte(data), 0)
+	if c.Size() != 2*size {
+		t.Errorf("expected %d bytes: %d\n", 2*size, c.Size())
+	}
+
+	// Too big to ever fit.
+	c.Set("d", []byte(strings.Repeat(data, 3)), 0)
+	if _, err := os.Stat(filepath.Join(dir, c.name("d"))); !os.IsNotExist(err) {
+		t.Errorf("expected oversized body not to be written: %v\n", err)
+	}
+}
+
+func TestDiskCacheShared(t *testing.T) {
+	dir := t.TempDir()
+	size := int64(len(data))
+	clock := newFakeClock()
+	a, err := NewDiskCache(dir, time.Hour, 2*size)
+	if err != nil {
+		t.Fatalf("unexpected: %v\n", err)
+	}
+	b, err := NewDiskCache(dir, time.Hour, 2*size)
+	if err != nil {
+		t.Fatalf("unexpected: %v\n", err)
+	}
+	a.clock, b.clock = clock, clock
+
+	// What one process writes, another sharing the directory reads, with
+	// the expiry it was written with.
+	a.Set("a", []byte(data), time.Minute)
+	if body, ok := b.Get("a"); !ok || string(body) != data {
+		t.Errorf("expected [data, true]: %q, %t\n", body, ok)
+	}
+	clock.Advance(time.Minute)
+	if _, ok := b.Get("a"); ok {
+		t.Errorf("expected the writer's expiry to apply\n")
+	}
+
+	// Eviction takes the other process's files into account, once the
+	// directory has been reread.
+	clock.Advance(time.Second)
+	a.Set("b", []byte(data), 0)
+	clock.Advance(diskCacheRescan)
+	b.Set("c", []byte(data), 0)
+	if _, ok := a.GetStale("a"); ok {
+		t.Errorf("expected the soonest expiring to be evicted\n")
+	}
+	for _, key := range []string{"b", "c"} {
+		if _, ok := a.Get(key); !ok {
+			t.Errorf("expected %s to be kept\n", key)
+		}
+	}
+	if b.Size() != 2*size {
+		t.Errorf("expected %d bytes: %d\n", 2*size, b.Size())
+	}
+}
+
+func TestDiskCacheAbandonedTemp(t *testing.T) {
+	dir := t.TempDir()
+	c, err := NewDiskCache(dir, time.Hour, 0)
+	if err != nil {
+		t.Fatalf("unexpected: %v\n", err)
+	}
+	old := filepath.Join(dir, c.name("a")+diskCacheTmp+"123")
+	recent := filepath.Join(dir, c.name("b")+diskCacheTmp+"456")
+	for _, path := range []string{old, recent} {
+		if err := ioutil.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatalf("unexpected: %v\n", err)
+		}
+	}
+	then := time.Now().Add(-2 * diskCacheTmpAge)
+	os.Chtimes(old, then, then)
+
+	if _, err := NewDiskCache(dir, time.Hour, 0); err != nil {
+		t.Fatalf("unexpected: %v\n", err)
+	}
+	if _, err := os.Stat(old); !os.IsNotExist(err) {
+		t.Errorf("expected abandoned temporary file removed: %v\n", err)
+	}
+	if _, err := os.Stat(recent); err != nil {
+		t.Errorf("expected recent temporary file kept: %v\n", err)
+	}
+}
+
+func TestDiskCacheRetention(t *testing.T) {
+	dir := t.TempDir()
+	files := func() int {
+		t.Helper()
+		infos, err := ioutil.ReadDir(dir)
+		if err != nil {
+			t.Fatalf("unexpected: %v\n", err)
+		}
+		return len(infos)
+	}
+	clock := newFakeClock()
+	c, err := NewDiskCache(dir, time.Minute, 0, WithDiskCacheClock(clock))
+	if err != nil {
+		t.Fatalf("unexpected: %v\n", err)
+	}
+
+	// Without retention, expired files go when read, or when the
+	// directory is reread, even with no size limit to enforce.
+	c.Set("a", []byte(data), 0)
+	c.Set("b", []byte(data), 0)
+	clock.Advance(time.Minute)
+	if _, ok := c.GetStale("a"); ok {
+		t.Errorf("expected expired entry gone\n")
+	}
+	if n := files(); n != 1 {
+		t.Errorf("expected 1 file: %d\n", n)
+	}
+	c.Set("c", []byte(data), 0)
+	if n, size := files(), c.Size(); n != 1 || size != int64(len(data)) {
+		t.Errorf("expected 1 file of %d bytes: %d, %d\n", len(data), n, size)
+	}
+
+	// With retention, they stay as long as it says.
+	c, err = NewDiskCache(dir, time.Minute, 0, WithDiskCacheClock(clock), WithDiskCacheRetention(time.Hour))
+	if err != nil {
+		t.Fatalf("unexpected: %v\n", err)
+	}
+	clock.Advance(30 * time.Minute)
+	if _, ok := c.GetStale("c"); !ok {
+		t.Errorf("expected retained entry\n")
+	}
+	clock.Advance(time.Hour)
+	if err := c.Purge(); err != nil {
+		t.Fatalf("unexpected: %v\n", err)
+	}
+	if n, size := files(), c.Size(); n != 0 || size != 0 {
+		t.Errorf("expected no files: %d, %d\n", n, size)
+	}
+
+	// Reopening removes what an earlier run left past retention.
+	c.Set("d", []byte(data), 0)
+	clock.Advance(time.Minute)
+	if _, err := NewDiskCache(dir, time.Minute, 0, WithDiskCacheClock(clock)); err != nil {
+		t.Fatalf("unexpected: %v\n", err)
+	}
+	if n := files(); n != 0 {
+		t.Errorf("expected no files: %d\n", n)
+	}
+}
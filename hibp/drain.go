@@ -0,0 +1,80 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// WithDrain has a BulkChecker finish the lookup under way when its ctx is
+// done, taking up to grace to do so, rather than abandoning it. The Result
+// is passed on and counted in the ResumeToken as usual, and only then does
+// the job stop, with ctx's error. Nothing more is read from the input.
+func WithDrain(grace time.Duration) func(b *BulkChecker) {
+	return func(b *BulkChecker) {
+		b.grace = grace
+	}
+}
+
+// WithQueueDrain has a Queue stop taking additions when the ctx given to
+// Run is done, and then spend up to grace looking up what was already
+// added, rather than failing it. Only what is left after that completes
+// with ctx's error.
+func WithQueueDrain(grace time.Duration) func(q *Queue) {
+	return func(q *Queue) {
+		q.grace = grace
+	}
+}
+
+// drain looks up what's left in the Queue after intake has stopped, until
+// it's empty or ctx is done.
+func (q *Queue) drain(ctx context.Context) {
+	q.stopIntake()
+	for ctx.Err() == nil {
+		select {
+		case t := <-q.jobs:
+			t.finish(q.finder.result(ctx, fmt.Sprintf("%X", t.sum)))
+		default:
+			return
+		}
+	}
+}
+
+// drainContext returns a context for work that should outlive ctx by up
+// to grace. It carries ctx's values but not its deadline, and is canceled
+// grace after ctx is done, or by the returned function.
+func drainContext(ctx context.Context, grace time.Duration, clock Clock) (context.Context, context.CancelFunc) {
+	dctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	go func() {
+		select {
+		case <-ctx.Done():
+			select {
+			case <-clock.After(grace):
+			case <-dctx.Done():
+			}
+			cancel()
+		case <-dctx.Done():
+		}
+	}()
+	return dctx, cancel
+}
@@ -0,0 +1,209 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"context"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// gatedServer answers range requests once they are let through on the
+// returned channel, counting those that have arrived.
+func gatedServer(arrived *int32) (*httptest.Server, chan struct{}) {
+	gate := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(arrived, 1)
+		select {
+		case <-gate:
+			w.Write([]byte(data))
+		case <-r.Context().Done():
+		}
+	}))
+	return ts, gate
+}
+
+func TestWithDrain(t *testing.T) {
+	testCases := []struct {
+		name    string
+		grace   time.Duration
+		expire  bool
+		results int
+		offset  int64
+	}{
+		{
+			"off",
+			0,
+			false,
+			0,
+			0,
+		},
+		{
+			"finished",
+			time.Minute,
+			false,
+			1,
+			1,
+		},
+		{
+			"grace expired",
+			time.Minute,
+			true,
+			0,
+			0,
+		},
+	}
+
+	input := strings.Repeat(hexSum("melobie")+"\n", 3)
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var arrived int32
+			ts, gate := gatedServer(&arrived)
+			defer ts.Close()
+			defer close(gate)
+
+			clock := newFakeClock()
+			b := NewBulkChecker(NewFinder(
+				WithClient(ts.Client()),
+				WithURLTemplate(fmt.Sprintf("%s/%%s", ts.URL)),
+				WithClock(clock),
+			), WithDrain(tc.grace))
+
+			ctx, cancel := context.WithCancel(context.Background())
+			type outcome struct {
+				token ResumeToken
+				err   error
+			}
+			done := make(chan outcome)
+			results := 0
+			go func() {
+				token, err := b.Run(ctx, strings.NewReader(input), ResumeToken{}, func(res Result) error {
+					if res.Count != 401 {
+						t.Errorf("expected 401: %+v\n", res)
+					}
+					results++
+					return nil
+				})
+				done <- outcome{token, err}
+			}()
+
+			for atomic.LoadInt32(&arrived) == 0 {
+				time.Sleep(time.Millisecond)
+			}
+			cancel()
+			if tc.grace > 0 {
+				for clock.pending() == 0 {
+					time.Sleep(time.Millisecond)
+				}
+				if tc.expire {
+					clock.Advance(tc.grace)
+				} else {
+					gate <- struct{}{}
+				}
+			}
+
+			out := <-done
+			if !errors.Is(out.err, context.Canceled) {
+				t.Errorf("expected %v: %v\n", context.Canceled, out.err)
+			}
+			if results != tc.results || out.token.Offset != tc.offset {
+				t.Errorf("expected [%d, %d]: %d, %d\n", tc.results, tc.offset, results, out.token.Offset)
+			}
+			if got := atomic.LoadInt32(&arrived); got != 1 {
+				t.Errorf("expected 1 request: %d\n", got)
+			}
+		})
+	}
+}
+
+func TestWithQueueDrain(t *testing.T) {
+	var arrived int32
+	ts, gate := gatedServer(&arrived)
+	defer ts.Close()
+
+	clock := newFakeClock()
+	f := NewFinder(
+		WithClient(ts.Client()),
+		WithURLTemplate(fmt.Sprintf("%s/%%s", ts.URL)),
+		WithClock(clock),
+	)
+	q := NewQueue(f, 1, 3, WithQueueDrain(time.Minute))
+
+	h := sha1.Sum([]byte("melobie"))
+	var tickets []*Ticket
+	enqueue := func() {
+		tk, err := q.Enqueue(context.Background(), h[:])
+		if err != nil {
+			t.Fatalf("unexpected: %v\n", err)
+		}
+		tickets = append(tickets, tk)
+	}
+	for i := 0; i < 3; i++ {
+		enqueue()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan error)
+	go func() {
+		stopped <- q.Run(ctx)
+	}()
+	for atomic.LoadInt32(&arrived) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	// The worker took one, which makes room for another.
+	enqueue()
+	cancel()
+
+	// Intake stops right away...
+	if _, err := q.Enqueue(context.Background(), h[:]); err != ErrQueueClosed {
+		t.Errorf("expected %v: %v\n", ErrQueueClosed, err)
+	}
+
+	// ...while what was added is still looked up, until the grace is up.
+	for i := 0; i < 3; i++ {
+		gate <- struct{}{}
+	}
+	for atomic.LoadInt32(&arrived) < 4 || clock.pending() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	clock.Advance(time.Minute)
+
+	if err := <-stopped; err != nil {
+		t.Errorf("unexpected: %v\n", err)
+	}
+	var codes []ErrorCode
+	for _, tk := range tickets {
+		res, _ := q.Await(context.Background(), tk)
+		codes = append(codes, res.Code)
+	}
+	exp := []ErrorCode{"", "", "", CodeCanceled}
+	if fmt.Sprint(codes) != fmt.Sprint(exp) {
+		t.Errorf("expected %q: %q\n", exp, codes)
+	}
+	close(gate)
+}
@@ -0,0 +1,125 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Sentinels for the kinds of failure callers commonly branch on. The
+// errors returned are more specific and carry detail; use errors.Is to
+// test for these.
+var (
+	// ErrInvalidDigestLength is for a digest that isn't the size of a
+	// hash of the Finder's HashMode.
+	ErrInvalidDigestLength = errors.New("hibp: invalid digest length")
+	// ErrThrottled is for the backend asking for requests to slow down.
+	ErrThrottled = errors.New("hibp: throttled")
+	// ErrUpstream is for any other unsuccessful response from the backend.
+	ErrUpstream = errors.New("hibp: upstream error")
+)
+
+// DigestLengthError is returned for a digest that isn't the size of a hash
+// of the Finder's HashMode. It matches ErrInvalidDigestLength.
+//
+// Earlier versions returned io.ErrShortBuffer for a digest that was too
+// short and io.ErrShortWrite for one too long. It keeps their messages,
+// and matches them too, as appropriate; but it isn't equal to them, so
+// code that compares with == must either use errors.Is instead, or have
+// the Finder return the io errors themselves with WithLegacyDigestErrors.
+// New code should test for ErrInvalidDigestLength.
+type DigestLengthError struct {
+	// Got is the length of the digest given, and Want the one expected.
+	Got, Want int
+}
+
+// Error returns the message of the io error returned for e's length
+// before there was a DigestLengthError.
+func (e *DigestLengthError) Error() string {
+	return e.legacy().Error()
+}
+
+// Is reports whether target is ErrInvalidDigestLength, or the io error
+// returned for e's length before there was a DigestLengthError.
+func (e *DigestLengthError) Is(target error) bool {
+	return target == ErrInvalidDigestLength || target == e.legacy()
+}
+
+// legacy returns the io error returned for e's length before there was a
+// DigestLengthError.
+func (e *DigestLengthError) legacy() error {
+	if e.Got < e.Want {
+		return io.ErrShortBuffer
+	}
+	return io.ErrShortWrite
+}
+
+// WithLegacyDigestErrors has the Finder return io.ErrShortBuffer and
+// io.ErrShortWrite for digests of the wrong length, as earlier versions
+// did, rather than a DigestLengthError. It is for code that compares
+// errors with ==, until it moves to ErrInvalidDigestLength.
+func WithLegacyDigestErrors() func(f *Finder) {
+	return func(f *Finder) {
+		f.legacyErrs = true
+	}
+}
+
+// HTTPError is returned for an unsuccessful response from the backend. It
+// matches ErrThrottled for a 429 response, and ErrUpstream otherwise.
+type HTTPError struct {
+	// StatusCode and Status are those of the response, such as 503 and
+	// "503 Service Unavailable".
+	StatusCode int
+	Status     string
+	// Prefix is the range that was asked for, if it was a range request.
+	Prefix string
+	// Attempts is how many times the request was made, retries included.
+	Attempts int
+
+	// retryAfter is the Retry-After header, if any.
+	retryAfter string
+}
+
+func (e *HTTPError) Error() string {
+	status := e.Status
+	if status == "" {
+		status = fmt.Sprintf("%d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	msg := "hibp: " + status
+	if e.Prefix != "" {
+		msg = fmt.Sprintf("hibp: range %s: %s", e.Prefix, status)
+	}
+	if e.Attempts > 1 {
+		msg += fmt.Sprintf(" (after %d attempts)", e.Attempts)
+	}
+	return msg
+}
+
+// Is matches ErrThrottled for a 429 response, and ErrUpstream otherwise.
+func (e *HTTPError) Is(target error) bool {
+	if e.StatusCode == http.StatusTooManyRequests {
+		return target == ErrThrottled
+	}
+	return target == ErrUpstream
+}
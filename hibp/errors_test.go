@@ -0,0 +1,190 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDigestLengthError(t *testing.T) {
+	f := NewFinder()
+
+	testCases := []struct {
+		name  string
+		size  int
+		short bool
+	}{
+		{
+			"short",
+			19,
+			true,
+		},
+		{
+			"long",
+			21,
+			false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := f.Find(make([]byte, tc.size))
+			var le *DigestLengthError
+			if !errors.As(err, &le) || le.Got != tc.size || le.Want != 20 {
+				t.Fatalf("expected a DigestLengthError: %v\n", err)
+			}
+			if !errors.Is(err, ErrInvalidDigestLength) {
+				t.Errorf("expected %v: %v\n", ErrInvalidDigestLength, err)
+			}
+			if errors.Is(err, io.ErrShortBuffer) != tc.short {
+				t.Errorf("expected %v %t: %v\n", io.ErrShortBuffer, tc.short, err)
+			}
+			if errors.Is(err, io.ErrShortWrite) == tc.short {
+				t.Errorf("expected %v %t: %v\n", io.ErrShortWrite, !tc.short, err)
+			}
+			legacy := io.ErrShortWrite
+			if tc.short {
+				legacy = io.ErrShortBuffer
+			}
+			if err.Error() != legacy.Error() {
+				t.Errorf("expected %q: %q\n", legacy.Error(), err.Error())
+			}
+		})
+	}
+}
+
+func TestLegacyDigestErrors(t *testing.T) {
+	f := NewFinder(WithLegacyDigestErrors())
+	if _, err := f.Find(make([]byte, 19)); err != io.ErrShortBuffer {
+		t.Errorf("expected %v: %v\n", io.ErrShortBuffer, err)
+	}
+	if _, err := f.Find(make([]byte, 21)); err != io.ErrShortWrite {
+		t.Errorf("expected %v: %v\n", io.ErrShortWrite, err)
+	}
+	f = NewFinder(WithLegacyDigestErrors(), WithHashMode(NTLM))
+	if _, err := f.Find(make([]byte, 20)); err != io.ErrShortWrite {
+		t.Errorf("expected %v: %v\n", io.ErrShortWrite, err)
+	}
+}
+
+func TestUpstreamErrors(t *testing.T) {
+	testCases := []struct {
+		code int
+		exp  error
+		not  error
+	}{
+		{
+			http.StatusTooManyRequests,
+			ErrThrottled,
+			ErrUpstream,
+		},
+		{
+			http.StatusBadGateway,
+			ErrUpstream,
+			ErrThrottled,
+		},
+		{
+			http.StatusFound,
+			ErrUpstream,
+			ErrThrottled,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(http.StatusText(tc.code), func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Location", "/elsewhere")
+				w.WriteHeader(tc.code)
+			}))
+			defer ts.Close()
+
+			f := NewFinder(
+				WithClient(ts.Client()),
+				WithURLTemplate(fmt.Sprintf("%s/%%s", ts.URL)),
+				WithNoRedirects(),
+				WithMaxAttempts(1),
+			)
+			_, err := f.FindContext(context.Background(), make([]byte, 20))
+			if !errors.Is(err, tc.exp) || errors.Is(err, tc.not) {
+				t.Errorf("expected %v, not %v: %v\n", tc.exp, tc.not, err)
+			}
+		})
+	}
+}
+
+func TestHTTPError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	testCases := []struct {
+		name     string
+		attempts int
+		msg      string
+	}{
+		{
+			"once",
+			1,
+			"hibp: range 00000: 503 Service Unavailable",
+		},
+		{
+			"retried",
+			3,
+			"hibp: range 00000: 503 Service Unavailable (after 3 attempts)",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			f := NewFinder(
+				WithClient(ts.Client()),
+				WithURLTemplate(fmt.Sprintf("%s/%%s", ts.URL)),
+				WithRetry(RetryPolicy{MaxAttempts: tc.attempts, Base: time.Millisecond}),
+			)
+			_, err := f.FindContext(context.Background(), make([]byte, 20))
+			var he *HTTPError
+			if !errors.As(err, &he) {
+				t.Fatalf("expected an HTTPError: %v\n", err)
+			}
+			if he.StatusCode != http.StatusServiceUnavailable || he.Prefix != "00000" || he.Attempts != tc.attempts {
+				t.Errorf("expected 503 for 00000 after %d: %+v\n", tc.attempts, he)
+			}
+			if err.Error() != tc.msg {
+				t.Errorf("expected %q: %q\n", tc.msg, err)
+			}
+		})
+	}
+}
+
+func TestHTTPErrorStatusText(t *testing.T) {
+	err := &HTTPError{StatusCode: http.StatusBadGateway}
+	if exp := "hibp: 502 Bad Gateway"; err.Error() != exp {
+		t.Errorf("expected %q: %q\n", exp, err)
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Format is an output format of ExportBuckets or a ResultWriter.
+type Format int
+
+const (
+	// FormatCSV writes a header, then one row per entry; for
+	// ExportBuckets, the header is "prefix,hash,count".
+	FormatCSV Format = iota
+	// FormatJSONL writes one JSON object per line; for ExportBuckets,
+	// with "prefix", "hash" and "count" fields.
+	FormatJSONL
+)
+
+// exportRow is an entry as written by ExportBuckets.
+type exportRow struct {
+	Prefix string `json:"prefix"`
+	Hash   string `json:"hash"`
+	Count  int64  `json:"count"`
+}
+
+// ExportBuckets fetches the range for each of prefixes (5 hex digits, in
+// either case) and writes every entry in them to w, in the given format,
+// for analysis elsewhere. Hashes are written in full and in upper case.
+// Padding entries (see WithPadding) are left out.
+//
+// Prefixes and format are checked before anything is fetched. An error
+// fetching or parsing a range stops the export; what was written for the
+// ranges before it is complete.
+func (f *Finder) ExportBuckets(ctx context.Context, prefixes []string, w io.Writer, format Format) error {
+	if format != FormatCSV && format != FormatJSONL {
+		return fmt.Errorf("hibp: unknown export format %d", format)
+	}
+	clean := make([]string, len(prefixes))
+	for i, p := range prefixes {
+		if len(p) != prefixSize || !isHex([]byte(p)) {
+			return fmt.Errorf("%w: prefix %q", ErrInvalidHex, p)
+		}
+		clean[i] = strings.ToUpper(p)
+	}
+
+	var write func(exportRow) error
+	var flush func() error
+	switch format {
+	case FormatCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write([]string{"prefix", "hash", "count"}); err != nil {
+			return err
+		}
+		write = func(r exportRow) error {
+			return cw.Write([]string{r.Prefix, r.Hash, strconv.FormatInt(r.Count, 10)})
+		}
+		flush = func() error {
+			cw.Flush()
+			return cw.Error()
+		}
+	case FormatJSONL:
+		enc := json.NewEncoder(w)
+		write = func(r exportRow) error { return enc.Encode(r) }
+		flush = func() error { return nil }
+	}
+
+	for _, p := range clean {
+		body, err := f.fetchPrefix(ctx, f.mode, []byte(p))
+		if err != nil {
+			return fmt.Errorf("hibp: export %s: %w", p, err)
+		}
+		err = f.eachEntry(body, func(suffix string, n int64) error {
+			return write(exportRow{p, p + suffix, n})
+		})
+		if err != nil {
+			return fmt.Errorf("hibp: export %s: %w", p, err)
+		}
+		if err := flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// eachEntry calls fn with the upper case suffix and count of every entry in
+// a range body, skipping padding when it was asked for.
+func (f *Finder) eachEntry(body []byte, fn func(suffix string, n int64) error) error {
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 0, 4096), f.maxLineLength()+2)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 || (f.padding && isPadding(line)) {
+			continue
+		}
+		n, err := parseCount(line)
+		if err != nil {
+			return err
+		}
+		suffix := line[:bytes.Index(line, delim)]
+		if err := fn(strings.ToUpper(string(suffix)), n); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		if err == bufio.ErrTooLong {
+			return fmt.Errorf("%w: over %d bytes", ErrLineTooLong, f.maxLineLength())
+		}
+		return err
+	}
+	return nil
+}
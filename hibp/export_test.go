@@ -0,0 +1,125 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestExportBuckets(t *testing.T) {
+	var paths []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		if r.URL.Path == "/FFFFF" {
+			w.Write([]byte(paddedData))
+			return
+		}
+		w.Write([]byte("0018A45C4D1DEF81644B54AB7F969B88D65:229\r\n012a7ca357541f0ac487871feec1891c49c:401\r\n"))
+	}))
+	defer ts.Close()
+
+	testCases := []struct {
+		name   string
+		format Format
+		exOut  string
+	}{
+		{
+			"csv",
+			FormatCSV,
+			`prefix,hash,count
+21BD1,21BD10018A45C4D1DEF81644B54AB7F969B88D65,229
+21BD1,21BD1012A7CA357541F0AC487871FEEC1891C49C,401
+FFFFF,FFFFF0018A45C4D1DEF81644B54AB7F969B88D65,229
+`,
+		},
+		{
+			"jsonl",
+			FormatJSONL,
+			`{"prefix":"21BD1","hash":"21BD10018A45C4D1DEF81644B54AB7F969B88D65","count":229}
+{"prefix":"21BD1","hash":"21BD1012A7CA357541F0AC487871FEEC1891C49C","count":401}
+{"prefix":"FFFFF","hash":"FFFFF0018A45C4D1DEF81644B54AB7F969B88D65","count":229}
+`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			paths = nil
+			f := NewFinder(
+				WithClient(ts.Client()),
+				WithURLTemplate(fmt.Sprintf("%s/%%s", ts.URL)),
+				WithPadding(true),
+			)
+			var buf bytes.Buffer
+			err := f.ExportBuckets(context.Background(), []string{"21bd1", "FFFFF"}, &buf, tc.format)
+			if err != nil {
+				t.Fatalf("unexpected: %v\n", err)
+			}
+			if got := buf.String(); got != tc.exOut {
+				t.Errorf("expected %q: %q\n", tc.exOut, got)
+			}
+			if exp := "/21BD1 /FFFFF"; strings.Join(paths, " ") != exp {
+				t.Errorf("expected %s: %v\n", exp, paths)
+			}
+		})
+	}
+}
+
+func TestExportBucketsInvalid(t *testing.T) {
+	f := NewFinder(WithURLTemplate("http://127.0.0.1:0/%s"))
+
+	var buf bytes.Buffer
+	err := f.ExportBuckets(context.Background(), []string{"21BD1", "21BD"}, &buf, FormatCSV)
+	if !errors.Is(err, ErrInvalidHex) {
+		t.Errorf("expected %v: %v\n", ErrInvalidHex, err)
+	}
+	err = f.ExportBuckets(context.Background(), []string{"21BD1"}, &buf, Format(99))
+	if err == nil {
+		t.Errorf("expected error for unknown format\n")
+	}
+	if buf.Len() != 0 {
+		t.Errorf("expected nothing written: %q\n", buf.String())
+	}
+}
+
+func TestExportBucketsMalformed(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("0018A45C4D1DEF81644B54AB7F969B88D65:lots\n"))
+	}))
+	defer ts.Close()
+
+	f := NewFinder(
+		WithClient(ts.Client()),
+		WithURLTemplate(fmt.Sprintf("%s/%%s", ts.URL)),
+	)
+	var buf bytes.Buffer
+	err := f.ExportBuckets(context.Background(), []string{"21BD1"}, &buf, FormatJSONL)
+	if !errors.Is(err, ErrInvalidCount) {
+		t.Errorf("expected %v: %v\n", ErrInvalidCount, err)
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReplaceFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hibp-file")
+	if err != nil {
+		t.Fatalf("unexpected: %v\n", err)
+	}
+	defer os.RemoveAll(dir)
+	from, to := filepath.Join(dir, "new"), filepath.Join(dir, "old")
+	for path, body := range map[string]string{from: "new", to: "old"} {
+		if err := ioutil.WriteFile(path, []byte(body), 0644); err != nil {
+			t.Fatalf("unexpected: %v\n", err)
+		}
+	}
+
+	// A reader holding the file open for a moment mustn't stop it being
+	// replaced, on any platform.
+	r, err := os.Open(to)
+	if err != nil {
+		t.Fatalf("unexpected: %v\n", err)
+	}
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		r.Close()
+	}()
+	if err := replaceFile(from, to); err != nil {
+		t.Fatalf("unexpected: %v\n", err)
+	}
+	if b, err := ioutil.ReadFile(to); err != nil || string(b) != "new" {
+		t.Errorf("expected %q: %q %v\n", "new", b, err)
+	}
+	if _, err := os.Stat(from); !os.IsNotExist(err) {
+		t.Errorf("expected %s gone: %v\n", from, err)
+	}
+
+	if err := removeFile(to); err != nil {
+		t.Errorf("unexpected: %v\n", err)
+	}
+	if err := removeFile(to); !os.IsNotExist(err) {
+		t.Errorf("expected not exist: %v\n", err)
+	}
+}
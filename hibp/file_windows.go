@@ -0,0 +1,73 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"time"
+)
+
+// replaceTimeout bounds how long replaceFile and removeFile keep retrying.
+const replaceTimeout = 500 * time.Millisecond
+
+// errSharingViolation is ERROR_SHARING_VIOLATION, which the syscall
+// package doesn't define.
+const errSharingViolation syscall.Errno = 32
+
+// replaceFile renames from over to. Windows refuses to replace or remove a
+// file while another handle has it open, as a concurrent Get might, so
+// this retries for a short while before giving up.
+func replaceFile(from, to string) error {
+	return retryBusy(func() error { return os.Rename(from, to) })
+}
+
+// removeFile removes path, retrying as replaceFile does.
+func removeFile(path string) error {
+	return retryBusy(func() error { return os.Remove(path) })
+}
+
+// retryBusy calls fn until it succeeds, fails for a reason other than the
+// file being in use, or replaceTimeout has passed.
+func retryBusy(fn func() error) error {
+	deadline := time.Now().Add(replaceTimeout)
+	wait := time.Millisecond
+	for {
+		err := fn()
+		if err == nil || !isBusy(err) || time.Now().Add(wait).After(deadline) {
+			return err
+		}
+		time.Sleep(wait)
+		if wait < 50*time.Millisecond {
+			wait *= 2
+		}
+	}
+}
+
+// isBusy reports whether err is Windows saying a file is in use.
+func isBusy(err error) bool {
+	var errno syscall.Errno
+	if !errors.As(err, &errno) {
+		return false
+	}
+	return errno == syscall.ERROR_ACCESS_DENIED || errno == errSharingViolation
+}
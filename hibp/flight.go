@@ -0,0 +1,141 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// flight coalesces concurrent fetches of the same range, so that only one
+// request per range is in flight at a time, and the lookups that asked for
+// it while it was share its outcome; see fetchShared and streamCount. The
+// zero value is ready to use.
+type flight struct {
+	mu    sync.Mutex // guards calls and the waiters of each
+	calls map[string]*flightCall
+}
+
+type flightCall struct {
+	done    chan struct{}
+	waiters int
+	body    []byte
+	ttl     time.Duration
+	err     error
+}
+
+// do calls fn, unless a call for key is already in flight, in which case
+// it waits for that one, or for ctx to be done, and returns its outcome
+// instead.
+func (g *flight) do(ctx context.Context, key string, fn func() ([]byte, time.Duration, error)) ([]byte, time.Duration, error) {
+	c, leader := g.join(key)
+	if !leader {
+		if err := g.wait(ctx, c); err != nil {
+			return nil, 0, err
+		}
+		return c.body, c.ttl, c.err
+	}
+	body, ttl, err := fn()
+	g.finish(key, c, body, ttl, err)
+	return body, ttl, err
+}
+
+// join returns the call in flight for key, or starts one, reporting
+// whether it did. The caller that starts a call must finish it; the
+// others wait for it to be done.
+func (g *flight) join(key string) (*flightCall, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if c, ok := g.calls[key]; ok {
+		c.waiters++
+		return c, false
+	}
+	c := &flightCall{done: make(chan struct{})}
+	if g.calls == nil {
+		g.calls = map[string]*flightCall{}
+	}
+	g.calls[key] = c
+	return c, true
+}
+
+// wait waits for c, joined by the caller, to be done. If ctx is done
+// first, the caller stops waiting for c, and ctx's error is returned.
+func (g *flight) wait(ctx context.Context, c *flightCall) error {
+	select {
+	case <-c.done:
+		return nil
+	case <-ctx.Done():
+		g.mu.Lock()
+		c.waiters--
+		g.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// leave takes c, started by the caller, out of the flight for key if no
+// one is waiting for it, so that the caller needn't keep the body for
+// anyone else. It reports whether it did; if it didn't, c must still be
+// finished with the body.
+func (g *flight) leave(key string, c *flightCall) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if c.waiters > 0 {
+		return false
+	}
+	if g.calls[key] == c {
+		delete(g.calls, key)
+	}
+	return true
+}
+
+// finish records the outcome of c, started by the caller, and releases
+// those waiting for it.
+func (g *flight) finish(key string, c *flightCall, body []byte, ttl time.Duration, err error) {
+	g.mu.Lock()
+	if g.calls[key] == c {
+		delete(g.calls, key)
+	}
+	g.mu.Unlock()
+	c.body, c.ttl, c.err = body, ttl, err
+	close(c.done)
+}
+
+// abandoned reports whether err is from the context of a lookup that gave
+// up on a fetch, which those sharing it should make again.
+func abandoned(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// fetchShared is fetchRange, coalesced with any concurrent fetch of the
+// same range. A lookup that joined a fetch abandoned by the context of the
+// lookup that started it makes its own, unless its context is done too.
+func (f *Finder) fetchShared(ctx context.Context, key string, mode HashMode, prefix []byte) ([]byte, time.Duration, error) {
+	fetch := func() ([]byte, time.Duration, error) {
+		return f.fetchRange(ctx, mode, prefix)
+	}
+	body, ttl, err := f.flights.do(ctx, key, fetch)
+	if abandoned(err) && ctx.Err() == nil {
+		return f.flights.do(ctx, key, fetch)
+	}
+	return body, ttl, err
+}
@@ -0,0 +1,193 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"context"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFlight(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		w.Write([]byte(data))
+	}))
+	defer ts.Close()
+
+	f := NewFinder(
+		WithClient(ts.Client()),
+		WithURLTemplate(fmt.Sprintf("%s/%%s", ts.URL)),
+	)
+
+	// melobie and lauragpe share a range.
+	var wg sync.WaitGroup
+	counts := make([]int64, 10)
+	for i := range counts {
+		pwd := []string{"melobie", "lauragpe"}[i%2]
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			counts[i], _ = f.FindPassword(pwd)
+		}(i)
+	}
+	for atomic.LoadInt32(&calls) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Errorf("expected 1 call: %d\n", got)
+	}
+	for i, n := range counts {
+		if exp := []int64{401, 229}[i%2]; n != exp {
+			t.Errorf("expected %d: %d\n", exp, n)
+		}
+	}
+}
+
+func TestFlightCanceledLeader(t *testing.T) {
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			<-r.Context().Done()
+			return
+		}
+		w.Write([]byte(data))
+	}))
+	defer ts.Close()
+
+	f := NewFinder(
+		WithClient(ts.Client()),
+		WithURLTemplate(fmt.Sprintf("%s/%%s", ts.URL)),
+	)
+	h := sha1.Sum([]byte("melobie"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	leader := make(chan error)
+	go func() {
+		_, err := f.FindContext(ctx, h[:])
+		leader <- err
+	}()
+	for atomic.LoadInt32(&calls) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	follower := make(chan int64)
+	go func() {
+		n, _ := f.Find(h[:])
+		follower <- n
+	}()
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+
+	if err := <-leader; Classify(err) != CodeCanceled {
+		t.Errorf("expected %v: %v\n", CodeCanceled, err)
+	}
+	if n := <-follower; n != 401 {
+		t.Errorf("expected 401: %d\n", n)
+	}
+}
+
+func TestFlightWaiterContext(t *testing.T) {
+	testCases := []struct {
+		name  string
+		cache Cache
+	}{
+		{
+			"streamed",
+			nil,
+		},
+		{
+			"cached",
+			NewMemoryCache(10, time.Minute),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var calls int32
+			release := make(chan struct{})
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&calls, 1)
+				<-release
+				w.Write([]byte(data))
+			}))
+			defer ts.Close()
+
+			options := []func(*Finder){
+				WithClient(ts.Client()),
+				WithURLTemplate(fmt.Sprintf("%s/%%s", ts.URL)),
+			}
+			if tc.cache != nil {
+				options = append(options, WithCache(tc.cache))
+			}
+			f := NewFinder(options...)
+			h := sha1.Sum([]byte("melobie"))
+
+			leader := make(chan int64)
+			go func() {
+				n, _ := f.Find(h[:])
+				leader <- n
+			}()
+			for atomic.LoadInt32(&calls) == 0 {
+				time.Sleep(time.Millisecond)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			start := time.Now()
+			n, err := f.FindContext(ctx, h[:])
+			if n != 0 || !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("expected [0, %v]: %d, %v\n", context.DeadlineExceeded, n, err)
+			}
+			if d := time.Since(start); d > time.Second {
+				t.Errorf("expected to give up at the deadline: %v\n", d)
+			}
+
+			// The follower no longer counts as waiting for the range.
+			key := f.cacheKey(SHA1, []byte("21BD1"))
+			f.flights.mu.Lock()
+			waiters := f.flights.calls[key].waiters
+			f.flights.mu.Unlock()
+			if waiters != 0 {
+				t.Errorf("expected 0 waiters: %d\n", waiters)
+			}
+
+			close(release)
+			if n := <-leader; n != 401 {
+				t.Errorf("expected 401: %d\n", n)
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"crypto/sha1"
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+	"strings"
+	"unicode/utf16"
+)
+
+// ntlmSize is the length of an NTLM hash in bytes.
+const ntlmSize = 16
+
+// HashMode selects which kind of password hash the range API is queried
+// with.
+type HashMode int
+
+const (
+	// SHA1 hashes are the API's default.
+	SHA1 HashMode = iota
+	// NTLM hashes, as stored by Active Directory, are the MD4 of the
+	// UTF-16LE encoded password.
+	NTLM
+)
+
+// WithHashMode sets which kind of hash the Finder expects and asks the API
+// for. In NTLM mode, Find takes 16 byte hashes, FindHex takes 32 hex digits,
+// and the "mode=ntlm" query parameter is added to range requests.
+func WithHashMode(mode HashMode) func(f *Finder) {
+	return func(f *Finder) {
+		f.mode = mode
+	}
+}
+
+// size is the length in bytes of hashes of the mode.
+func (m HashMode) size() int {
+	if m == NTLM {
+		return ntlmSize
+	}
+	return sha1.Size
+}
+
+// digestSize is the length in bytes of the hashes the Finder works with.
+func (f *Finder) digestSize() int {
+	return f.mode.size()
+}
+
+// normalize decodes a hex encoded hash of the Finder's mode.
+func (f *Finder) normalize(input string) ([]byte, error) {
+	return normalizeHex(input, f.digestSize())
+}
+
+// hashPassword returns the hash of pwd in the Finder's mode.
+func (f *Finder) hashPassword(pwd string) []byte {
+	if f.mode == NTLM {
+		sum := ntlmSum(pwd)
+		return sum[:]
+	}
+	sum := sha1.Sum([]byte(pwd))
+	return sum[:]
+}
+
+// rangeURL builds the URL to fetch the range of hashes of the given mode
+// for prefix from. It fails if WithBaseURL was given an invalid URL.
+func (f *Finder) rangeURL(mode HashMode, prefix []byte) (string, error) {
+	if f.baseErr != nil {
+		return "", f.baseErr
+	}
+	var u string
+	if f.base != nil {
+		u = f.base.JoinPath("range", string(prefix)).String()
+	} else {
+		u = fmt.Sprintf(f.tmpl, prefix)
+	}
+	if mode != NTLM {
+		return u, nil
+	}
+	if strings.Contains(u, "?") {
+		return u + "&mode=ntlm", nil
+	}
+	return u + "?mode=ntlm", nil
+}
+
+func ntlmSum(pwd string) [ntlmSize]byte {
+	units := utf16.Encode([]rune(pwd))
+	b := make([]byte, 2*len(units))
+	for i, u := range units {
+		binary.LittleEndian.PutUint16(b[2*i:], u)
+	}
+	return md4Sum(b)
+}
+
+var (
+	md4Order = [3][16]int{
+		{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15},
+		{0, 4, 8, 12, 1, 5, 9, 13, 2, 6, 10, 14, 3, 7, 11, 15},
+		{0, 8, 4, 12, 2, 10, 6, 14, 1, 9, 5, 13, 3, 11, 7, 15},
+	}
+	md4Shift = [3][4]int{
+		{3, 7, 11, 19},
+		{3, 5, 9, 13},
+		{3, 9, 11, 15},
+	}
+)
+
+// md4Sum implements RFC 1320, which NTLM is built on and the standard
+// library doesn't provide. It is not used for anything but computing NTLM
+// hashes to look up.
+func md4Sum(msg []byte) [ntlmSize]byte {
+	h := [4]uint32{0x67452301, 0xefcdab89, 0x98badcfe, 0x10325476}
+
+	padded := make([]byte, len(msg), len(msg)+72)
+	copy(padded, msg)
+	padded = append(padded, 0x80)
+	for len(padded)%64 != 56 {
+		padded = append(padded, 0)
+	}
+	padded = binary.LittleEndian.AppendUint64(padded, uint64(len(msg))*8)
+
+	var x [16]uint32
+	for block := padded; len(block) > 0; block = block[64:] {
+		for i := range x {
+			x[i] = binary.LittleEndian.Uint32(block[4*i:])
+		}
+		a, b, c, d := h[0], h[1], h[2], h[3]
+		for round := 0; round < 3; round++ {
+			for i, k := range md4Order[round] {
+				var fn, add uint32
+				switch round {
+				case 0:
+					fn = (b & c) | (^b & d)
+				case 1:
+					fn, add = (b&c)|(b&d)|(c&d), 0x5a827999
+				case 2:
+					fn, add = b^c^d, 0x6ed9eba1
+				}
+				t := bits.RotateLeft32(a+fn+x[k]+add, md4Shift[round][i%4])
+				a, b, c, d = d, t, b, c
+			}
+		}
+		h[0] += a
+		h[1] += b
+		h[2] += c
+		h[3] += d
+	}
+
+	var out [ntlmSize]byte
+	for i, v := range h {
+		binary.LittleEndian.PutUint32(out[4*i:], v)
+	}
+	return out
+}
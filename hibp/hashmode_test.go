@@ -0,0 +1,122 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMD4(t *testing.T) {
+	// From RFC 1320, appendix A.5.
+	testCases := []struct {
+		in  string
+		exp string
+	}{
+		{"", "31d6cfe0d16ae931b73c59d7e0c089c0"},
+		{"a", "bde52cb31de33e46245e05fbdbd6fb24"},
+		{"abc", "a448017aaf21d8525fc10ae87aa6729d"},
+		{"message digest", "d9130a8164549fe818874806e1c7014b"},
+		{"abcdefghijklmnopqrstuvwxyz", "d79e1c308aa5bbcdeea8ed63df412da9"},
+		{
+			"12345678901234567890123456789012345678901234567890123456789012345678901234567890",
+			"e33b4ddc9c38f2199c3e7b164fcc0536",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.in, func(t *testing.T) {
+			if got := fmt.Sprintf("%x", md4Sum([]byte(tc.in))); got != tc.exp {
+				t.Errorf("expected %s: %s\n", tc.exp, got)
+			}
+		})
+	}
+}
+
+func TestNTLMSum(t *testing.T) {
+	if got := fmt.Sprintf("%X", ntlmSum("password")); got != "8846F7EAEE8FB117AD06BDD830B7586C" {
+		t.Errorf("expected 8846F7EAEE8FB117AD06BDD830B7586C: %s\n", got)
+	}
+}
+
+const ntlmData = `
+7EAEE8FB117AD06BDD830B7586C:2597813
+7EB60F7B1A10D4B26D5E38CE4B7:12
+`
+
+func TestFindNTLM(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("mode") != "ntlm" {
+			w.Write([]byte(data))
+			return
+		}
+		w.Write([]byte(ntlmData))
+	}))
+	defer ts.Close()
+
+	testCases := []struct {
+		name string
+		tmpl string
+	}{
+		{
+			"plain template",
+			fmt.Sprintf("%s/%%s", ts.URL),
+		},
+		{
+			"template with query",
+			fmt.Sprintf("%s/range?prefix=%%s", ts.URL),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			f := NewFinder(
+				WithClient(ts.Client()),
+				WithURLTemplate(tc.tmpl),
+				WithHashMode(NTLM),
+			)
+
+			n, err := f.FindPassword("password")
+			if n != 2597813 || err != nil {
+				t.Errorf("expected [2597813, nil]: %d, %v\n", n, err)
+			}
+			n, err = f.FindHex("8846f7eaee8fb117ad06bdd830b7586c")
+			if n != 2597813 || err != nil {
+				t.Errorf("expected [2597813, nil]: %d, %v\n", n, err)
+			}
+
+			// A SHA1 is too long for NTLM.
+			_, err = f.Find(make([]byte, 20))
+			if !errors.Is(err, io.ErrShortWrite) {
+				t.Errorf("expected %v: %v\n", io.ErrShortWrite, err)
+			}
+			_, err = f.FindHex(strings.Repeat("0", 40))
+			if !errors.Is(err, ErrInvalidHex) {
+				t.Errorf("expected %v: %v\n", ErrInvalidHex, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// ErrInvalidHex is wrapped by the errors returned for hex digests that
+// can't be decoded into a sum of the expected size.
+var ErrInvalidHex = errors.New("hibp: invalid hex digest")
+
+// NormalizeSHA1 decodes a hex encoded SHA1 into the 20 bytes of a
+// sha1.Sum(). Digits may be in either case, and any whitespace (including
+// separators between groups of digits and trailing newlines) is ignored, so
+// digests stored in lower case or copied from formatted output match the
+// upper case form used by the API.
+func NormalizeSHA1(input string) ([]byte, error) {
+	return normalizeHex(input, sha1.Size)
+}
+
+// NormalizeNTLM is like NormalizeSHA1, for the 16 bytes of an NTLM hash.
+func NormalizeNTLM(input string) ([]byte, error) {
+	return normalizeHex(input, ntlmSize)
+}
+
+func normalizeHex(input string, size int) ([]byte, error) {
+	clean := strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, input)
+	if len(clean) != hex.EncodedLen(size) {
+		return nil, fmt.Errorf("%w: expected %d digits: %d", ErrInvalidHex, hex.EncodedLen(size), len(clean))
+	}
+	sum, err := hex.DecodeString(clean)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidHex, err)
+	}
+	return sum, nil
+}
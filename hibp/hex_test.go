@@ -0,0 +1,113 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"errors"
+	"testing"
+)
+
+func TestNormalizeSHA1(t *testing.T) {
+	pwd := sha1.Sum([]byte("password"))
+
+	testCases := []struct {
+		name  string
+		input string
+		exErr bool
+	}{
+		{
+			"upper",
+			"5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8",
+			false,
+		},
+		{
+			"lower",
+			"5baa61e4c9b93f3f0682250b6cf8331b7ee68fd8",
+			false,
+		},
+		{
+			"mixed with newline",
+			"5baa61E4C9B93F3F0682250B6CF8331B7ee68fd8\r\n",
+			false,
+		},
+		{
+			"grouped",
+			" 5BAA6 1E4C9B93F3F0682250B6CF8331B7EE68FD8\t",
+			false,
+		},
+		{
+			"empty",
+			"",
+			true,
+		},
+		{
+			"too short",
+			"5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD",
+			true,
+		},
+		{
+			"too long",
+			"5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD80",
+			true,
+		},
+		{
+			"not hex",
+			"5BAA61E4C9B93F3F0682250B6CF8331B7EE68FDZ",
+			true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			sum, err := NormalizeSHA1(tc.input)
+			if tc.exErr {
+				if !errors.Is(err, ErrInvalidHex) {
+					t.Errorf("expected %v: %v\n", ErrInvalidHex, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected: %v\n", err)
+			}
+			if !bytes.Equal(sum, pwd[:]) {
+				t.Errorf("expected %X: %X\n", pwd, sum)
+			}
+		})
+	}
+}
+
+func TestNormalizeNTLM(t *testing.T) {
+	sum, err := NormalizeNTLM("8846f7eaee8fb117 AD06BDD830B7586C\n")
+	if err != nil {
+		t.Fatalf("unexpected: %v\n", err)
+	}
+	exp := ntlmSum("password")
+	if !bytes.Equal(sum, exp[:]) {
+		t.Errorf("expected %X: %X\n", exp, sum)
+	}
+
+	_, err = NormalizeNTLM("5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8")
+	if !errors.Is(err, ErrInvalidHex) {
+		t.Errorf("expected %v: %v\n", ErrInvalidHex, err)
+	}
+}
@@ -0,0 +1,183 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package hibptest runs a live smoke suite against the Have I Been Pwned
+// APIs, so projects using package hibp can check reachability of the
+// service as part of their own integration tests or deployment pipelines.
+//
+// The suite is deliberately small and paced: checks run one at a time with
+// a pause in between, and a check that is throttled by the API is retried
+// after backing off rather than failed.
+package hibptest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/nelz9999/go-hibp/hibp"
+)
+
+// DefaultInterval is the pause between checks, well inside the rate limits
+// of the unauthenticated breach API.
+const DefaultInterval = 2 * time.Second
+
+// DefaultAttempts is how many times a throttled check is tried.
+const DefaultAttempts = 3
+
+// A Check is a single step of a Suite.
+type Check struct {
+	Name string
+	Run  func(ctx context.Context) error
+}
+
+// Suite is a configurable smoke suite. The zero value checks the public
+// APIs with default clients.
+type Suite struct {
+	// Finder is used for the range checks; nil means hibp.NewFinder().
+	Finder *hibp.Finder
+	// Breaches is used for the breach checks; nil means
+	// hibp.NewBreachClient(). None of them need an API key.
+	Breaches *hibp.BreachClient
+	// Checks are run after the built-in ones.
+	Checks []Check
+	// Interval is the pause between checks, and the first backoff after a
+	// throttled attempt, doubling after each further one. Zero means
+	// DefaultInterval.
+	Interval time.Duration
+	// Attempts is how many times a throttled check is tried. Zero means
+	// DefaultAttempts.
+	Attempts int
+}
+
+// checks returns the built-in checks followed by s.Checks.
+func (s *Suite) checks() []Check {
+	f := s.Finder
+	if f == nil {
+		f = hibp.NewFinder()
+	}
+	c := s.Breaches
+	if c == nil {
+		c = hibp.NewBreachClient()
+	}
+	return append([]Check{
+		{"range", f.SelfTest},
+		{"breach", func(ctx context.Context) error {
+			b, err := c.GetBreach(ctx, "Adobe")
+			if err != nil {
+				return err
+			}
+			if b.PwnCount == 0 {
+				return fmt.Errorf("hibptest: breach %s has no accounts", b.Name)
+			}
+			return nil
+		}},
+		{"data classes", func(ctx context.Context) error {
+			classes, err := c.DataClasses(ctx)
+			if err != nil {
+				return err
+			}
+			if len(classes) == 0 {
+				return errors.New("hibptest: no data classes")
+			}
+			return nil
+		}},
+	}, s.Checks...)
+}
+
+// Run runs every check in turn and returns the errors of those that failed,
+// joined, or nil if all passed. It stops early if ctx is done.
+func (s *Suite) Run(ctx context.Context) error {
+	var errs []error
+	for i, c := range s.checks() {
+		if i > 0 {
+			if err := s.wait(ctx, s.interval()); err != nil {
+				return errors.Join(append(errs, err)...)
+			}
+		}
+		if err := s.run(ctx, c); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", c.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Test runs every check as a subtest of t. It is skipped in short mode, so
+// it can sit alongside unit tests:
+//
+//	func TestHIBP(t *testing.T) {
+//		new(hibptest.Suite).Test(t)
+//	}
+func (s *Suite) Test(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skip live HIBP checks in short mode")
+	}
+	for i, c := range s.checks() {
+		if i > 0 {
+			if err := s.wait(context.Background(), s.interval()); err != nil {
+				t.Fatal(err)
+			}
+		}
+		t.Run(c.Name, func(t *testing.T) {
+			if err := s.run(context.Background(), c); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+// run runs c, backing off and trying again while it is throttled.
+func (s *Suite) run(ctx context.Context, c Check) error {
+	attempts := s.Attempts
+	if attempts <= 0 {
+		attempts = DefaultAttempts
+	}
+	backoff := s.interval()
+	for i := 1; ; i++ {
+		err := c.Run(ctx)
+		if i == attempts || hibp.Classify(err) != hibp.CodeThrottled {
+			return err
+		}
+		if err := s.wait(ctx, backoff); err != nil {
+			return err
+		}
+		backoff *= 2
+	}
+}
+
+func (s *Suite) interval() time.Duration {
+	if s.Interval > 0 {
+		return s.Interval
+	}
+	return DefaultInterval
+}
+
+func (s *Suite) wait(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,149 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibptest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nelz9999/go-hibp/hibp"
+)
+
+func newSuite(t *testing.T, throttled int32) (*Suite, *int32) {
+	rs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "1E4C9B93F3F0682250B6CF8331B7EE68FD8:9545824\n")
+	}))
+	t.Cleanup(rs.Close)
+
+	var calls int32
+	bs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) <= throttled {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		switch r.URL.Path {
+		case "/breach/Adobe":
+			fmt.Fprint(w, `{"Name": "Adobe", "PwnCount": 152445165}`)
+		case "/dataclasses":
+			fmt.Fprint(w, `["Email addresses", "Passwords"]`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(bs.Close)
+
+	return &Suite{
+		Finder: hibp.NewFinder(
+			hibp.WithClient(rs.Client()),
+			hibp.WithURLTemplate(rs.URL+"/%s"),
+		),
+		Breaches: hibp.NewBreachClient(
+			hibp.WithBreachHTTPClient(bs.Client()),
+			hibp.WithBreachBaseURL(bs.URL),
+		),
+		Interval: time.Millisecond,
+	}, &calls
+}
+
+func TestRun(t *testing.T) {
+	s, calls := newSuite(t, 0)
+	if err := s.Run(context.Background()); err != nil {
+		t.Errorf("unexpected: %v\n", err)
+	}
+	if n := atomic.LoadInt32(calls); n != 2 {
+		t.Errorf("expected 2 breach calls: %d\n", n)
+	}
+}
+
+func TestRunThrottled(t *testing.T) {
+	testCases := []struct {
+		name      string
+		throttled int32
+		exErr     bool
+	}{
+		{
+			"retried",
+			DefaultAttempts - 1,
+			false,
+		},
+		{
+			"given up",
+			DefaultAttempts,
+			true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s, _ := newSuite(t, tc.throttled)
+			err := s.Run(context.Background())
+			if tc.exErr != (err != nil) {
+				t.Errorf("expected error %t: %v\n", tc.exErr, err)
+			}
+			if err != nil && hibp.Classify(err) != hibp.CodeThrottled {
+				t.Errorf("expected %v: %v\n", hibp.CodeThrottled, err)
+			}
+		})
+	}
+}
+
+func TestRunExtraChecks(t *testing.T) {
+	s, _ := newSuite(t, 0)
+	boom := errors.New("boom")
+	ran := false
+	s.Checks = []Check{
+		{"extra", func(ctx context.Context) error {
+			ran = true
+			return boom
+		}},
+	}
+
+	err := s.Run(context.Background())
+	if !ran {
+		t.Errorf("expected extra check to run\n")
+	}
+	if !errors.Is(err, boom) || !strings.HasPrefix(err.Error(), "extra: ") {
+		t.Errorf("expected extra: %v: %v\n", boom, err)
+	}
+}
+
+func TestRunCanceled(t *testing.T) {
+	s, _ := newSuite(t, 0)
+	s.Interval = time.Hour
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := s.Run(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected %v: %v\n", context.DeadlineExceeded, err)
+	}
+}
+
+func TestLive(t *testing.T) {
+	new(Suite).Test(t)
+}
@@ -0,0 +1,289 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// indexEntryOverhead estimates what a RangeIndex entry takes beyond its
+// suffixes and counts.
+const indexEntryOverhead = 128
+
+// RangeIndex keeps recently looked up ranges parsed, as sorted tables of
+// fixed-width suffixes and their counts, so that a lookup in a range it
+// holds is a binary search that takes no locks, rather than a pass over
+// the body. Hot ranges, as under load or in bulk work, gain the most. It
+// sits in front of a Finder's Cache or Source (see WithRangeIndex), and
+// holds each range for at most its TTL, as ResultCache does.
+//
+// A RangeIndex has a fixed number of slots, each holding one range, and a
+// range takes the place of any other whose prefix shares its slot; with
+// 1<<21 slots, every SHA1 and NTLM range has its own. A SHA1 range takes
+// around 40KB. It is safe for concurrent use, and may be shared by
+// Finders, which only see the ranges of their own backend.
+//
+// The index is consulted before a ResultCache, and a lookup it answers
+// takes no locks and allocates nothing, beyond what a Blocklist, a
+// Shortlist or a kill switch given to the Finder do.
+type RangeIndex struct {
+	ttl    time.Duration
+	budget *MemoryBudget
+	slots  []atomic.Pointer[indexEntry]
+
+	hits    uint64
+	misses  uint64
+	entries int64 // atomic
+	bytes   int64 // atomic
+
+	mu sync.Mutex // serializes put
+}
+
+type indexEntry struct {
+	key     uint32
+	name    string
+	expires time.Time
+	ranges  *parsedRange
+	cost    int64
+}
+
+// NewRangeIndex returns a RangeIndex of size slots, holding ranges for up
+// to ttl.
+func NewRangeIndex(size int, ttl time.Duration, options ...func(*RangeIndex)) *RangeIndex {
+	if size < 0 {
+		size = 0
+	}
+	x := &RangeIndex{
+		ttl:   ttl,
+		slots: make([]atomic.Pointer[indexEntry], size),
+	}
+	for _, opt := range options {
+		opt(x)
+	}
+	return x
+}
+
+// WithRangeIndexBudget has the RangeIndex draw the memory it takes from b,
+// which may be shared with other caches. A range that doesn't fit in what
+// is left of b isn't kept.
+func WithRangeIndexBudget(b *MemoryBudget) func(x *RangeIndex) {
+	return func(x *RangeIndex) {
+		x.budget = b
+	}
+}
+
+// WithRangeIndex has Find keep the ranges it looks up parsed in x, and
+// answer later lookups in them from it. Only ranges that are well formed
+// throughout are kept, and a range the backend says not to store isn't.
+func WithRangeIndex(x *RangeIndex) func(f *Finder) {
+	return func(f *Finder) {
+		f.index = x
+	}
+}
+
+// Stats returns the number of hits and misses so far, and what the index
+// holds.
+func (x *RangeIndex) Stats() CacheStats {
+	return CacheStats{
+		Hits:    atomic.LoadUint64(&x.hits),
+		Misses:  atomic.LoadUint64(&x.misses),
+		Entries: int(atomic.LoadInt64(&x.entries)),
+		Bytes:   atomic.LoadInt64(&x.bytes),
+	}
+}
+
+// hexDigits encodes suffixes for indexCount.
+const hexDigits = "0123456789ABCDEF"
+
+// indexCount answers a lookup of sum from the Finder's RangeIndex, if it
+// holds the range. It is the fast path for hot ranges, and must neither
+// lock nor allocate; see TestIndexCountAllocs.
+func (f *Finder) indexCount(mode HashMode, sum []byte) (int64, bool) {
+	key := uint32(sum[0])<<12 | uint32(sum[1])<<4 | uint32(sum[2])>>4 | uint32(mode)<<(4*prefixSize)
+	r, ok := f.index.get(f.clock.Now(), f.indexName, key)
+	if !ok {
+		return 0, false
+	}
+	var buf [2 * sha1.Size]byte
+	full := buf[:2*len(sum)]
+	for i, b := range sum {
+		full[2*i], full[2*i+1] = hexDigits[b>>4], hexDigits[b&0xf]
+	}
+	return r.count(full[prefixSize:]), true
+}
+
+// indexKey numbers the range for prefix, of the given mode.
+func indexKey(mode HashMode, prefix []byte) uint32 {
+	var key uint32
+	for _, c := range prefix {
+		key = key<<4 | uint32(unhex(c))
+	}
+	return key | uint32(mode)<<(4*prefixSize)
+}
+
+// unhex returns the value of the hex digit c.
+func unhex(c byte) byte {
+	switch {
+	case c >= 'a':
+		return c - 'a' + 10
+	case c >= 'A':
+		return c - 'A' + 10
+	}
+	return c - '0'
+}
+
+// indexNameDefault names the Finder's ranges in its RangeIndex after its
+// backend, so that Finders sharing the index keep theirs apart, as
+// cacheKey does for a Cache.
+func (f *Finder) indexNameDefault() {
+	if f.index == nil {
+		return
+	}
+	f.indexName = fmt.Sprintf("padding=%t@%s", f.padding, f.cacheBackend())
+}
+
+// get returns the range numbered key, for the Finder called name, if the
+// index holds it and it hasn't expired.
+func (x *RangeIndex) get(now time.Time, name string, key uint32) (*parsedRange, bool) {
+	if len(x.slots) == 0 {
+		return nil, false
+	}
+	e := x.slots[key%uint32(len(x.slots))].Load()
+	if e == nil || e.key != key || e.name != name || !now.Before(e.expires) {
+		atomic.AddUint64(&x.misses, 1)
+		return nil, false
+	}
+	atomic.AddUint64(&x.hits, 1)
+	return e.ranges, true
+}
+
+// put keeps r as the range numbered key, for the Finder called name, for
+// the index's TTL, or ttl if it is shorter and not zero.
+func (x *RangeIndex) put(now time.Time, name string, key uint32, r *parsedRange, ttl time.Duration) {
+	if len(x.slots) == 0 || x.ttl <= 0 {
+		return
+	}
+	if ttl <= 0 || ttl > x.ttl {
+		ttl = x.ttl
+	}
+	e := &indexEntry{
+		key:     key,
+		name:    name,
+		expires: now.Add(ttl),
+		ranges:  r,
+		cost:    r.size() + indexEntryOverhead,
+	}
+	slot := &x.slots[key%uint32(len(x.slots))]
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if old := slot.Swap(nil); old != nil {
+		atomic.AddInt64(&x.entries, -1)
+		atomic.AddInt64(&x.bytes, -old.cost)
+		if x.budget != nil {
+			x.budget.release(old.cost)
+		}
+	}
+	if x.budget != nil && !x.budget.reserve(e.cost) {
+		return
+	}
+	slot.Store(e)
+	atomic.AddInt64(&x.entries, 1)
+	atomic.AddInt64(&x.bytes, e.cost)
+}
+
+// parsedRange is a range body parsed into its suffixes, all of the same
+// width and sorted, and their counts.
+type parsedRange struct {
+	width    int
+	suffixes []byte
+	counts   []int64
+}
+
+// parseRange parses body, a range of the given mode, failing as
+// verifyRange does.
+func (f *Finder) parseRange(mode HashMode, body []byte) (*parsedRange, error) {
+	r := &parsedRange{width: 2*mode.size() - prefixSize}
+	lines := bytes.Count(body, []byte("\n")) + 1
+	r.suffixes = make([]byte, 0, lines*r.width)
+	r.counts = make([]int64, 0, lines)
+	err := f.scanRange(mode, body, func(suffix []byte, n int64) {
+		r.suffixes = append(r.suffixes, suffix...)
+		r.counts = append(r.counts, n)
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Ranges are normally sorted already. Sorting stably keeps the first
+	// of any repeated suffix first, which is the one a scan would find.
+	if !sort.IsSorted(r) {
+		sort.Stable(r)
+	}
+	return r, nil
+}
+
+// count returns the count for suffix, or zero if the range doesn't have
+// it.
+func (r *parsedRange) count(suffix []byte) int64 {
+	lo, hi := 0, len(r.counts)
+	for lo < hi {
+		mid := int(uint(lo+hi) >> 1)
+		if bytes.Compare(r.suffix(mid), suffix) < 0 {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	if lo < len(r.counts) && bytes.Equal(r.suffix(lo), suffix) {
+		return r.counts[lo]
+	}
+	return 0
+}
+
+// size returns the number of bytes r takes.
+func (r *parsedRange) size() int64 {
+	return int64(cap(r.suffixes) + 8*cap(r.counts))
+}
+
+func (r *parsedRange) suffix(i int) []byte {
+	return r.suffixes[i*r.width : (i+1)*r.width]
+}
+
+// Len, Less and Swap implement sort.Interface.
+
+func (r *parsedRange) Len() int { return len(r.counts) }
+
+func (r *parsedRange) Less(i, j int) bool {
+	return bytes.Compare(r.suffix(i), r.suffix(j)) < 0
+}
+
+func (r *parsedRange) Swap(i, j int) {
+	a, b := r.suffix(i), r.suffix(j)
+	for k := range a {
+		a[k], b[k] = b[k], a[k]
+	}
+	r.counts[i], r.counts[j] = r.counts[j], r.counts[i]
+}
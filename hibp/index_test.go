@@ -0,0 +1,236 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// suffixOf pads s out to a SHA1 range suffix.
+func suffixOf(s string) string {
+	return strings.Repeat("0", 35-len(s)) + s
+}
+
+func TestParseRange(t *testing.T) {
+	f := NewFinder()
+	a, b, c := suffixOf("A"), suffixOf("B"), suffixOf("C")
+	testCases := []struct {
+		name   string
+		body   string
+		suffix string
+		exp    int64
+		exErr  bool
+	}{
+		{"sorted", a + ":1\n" + b + ":2\n" + c + ":3\n", b, 2, false},
+		{"unsorted", c + ":3\r\n" + a + ":1\r\n" + b + ":2\r\n", c, 3, false},
+		{"first", a + ":1\n" + b + ":2\n", a, 1, false},
+		{"missing", a + ":1\n" + c + ":3\n", b, 0, false},
+		{"past the end", a + ":1\n" + b + ":2\n", c, 0, false},
+		{"repeated", b + ":2\n" + a + ":1\n" + b + ":5\n", b, 2, false},
+		{"malformed", a + ":1\n" + b + "\n", a, 0, true},
+		{"short suffix", a + ":1\nB:2\n", a, 0, true},
+		{"empty", "\n", a, 0, true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := f.parseRange(SHA1, []byte(tc.body))
+			if tc.exErr != (err != nil) {
+				t.Fatalf("expected error %t: %v\n", tc.exErr, err)
+			}
+			if err != nil {
+				return
+			}
+			if n := r.count([]byte(tc.suffix)); n != tc.exp {
+				t.Errorf("expected %d: %d\n", tc.exp, n)
+			}
+		})
+	}
+}
+
+func TestRangeIndex(t *testing.T) {
+	requests := 0
+	cacheControl := ""
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if cacheControl != "" {
+			w.Header().Set("Cache-Control", cacheControl)
+		}
+		w.Write([]byte(data))
+	}))
+	defer ts.Close()
+
+	clock := newFakeClock()
+	index := NewRangeIndex(16, time.Minute)
+	f := NewFinder(
+		WithClient(ts.Client()),
+		WithURLTemplate(fmt.Sprintf("%s/%%s", ts.URL)),
+		WithClock(clock),
+		WithRangeIndex(index),
+	)
+
+	check := func(f *Finder, pwd string, exp int64, xRequests int) {
+		t.Helper()
+		n, err := f.FindPassword(pwd)
+		if n != exp || err != nil {
+			t.Errorf("expected [%d, nil]: %d, %v\n", exp, n, err)
+		}
+		if requests != xRequests {
+			t.Errorf("expected %d requests: %d\n", xRequests, requests)
+		}
+	}
+
+	// melobie and lauragpe share a range.
+	check(f, "melobie", 401, 1)
+	check(f, "lauragpe", 229, 1)
+	check(f, "melobie", 401, 1)
+	got := index.Stats()
+	if got.Hits != 2 || got.Misses != 1 || got.Entries != 1 || got.Bytes <= indexEntryOverhead {
+		t.Errorf("expected [2, 1, 1, > %d]: %+v\n", indexEntryOverhead, got)
+	}
+
+	// A Finder sharing the index with another backend has its own ranges.
+	other := NewFinder(
+		WithClient(ts.Client()),
+		WithURLTemplate(fmt.Sprintf("%s/other/%%s", ts.URL)),
+		WithClock(clock),
+		WithRangeIndex(index),
+	)
+	check(other, "melobie", 401, 2)
+
+	clock.Advance(time.Minute)
+	check(f, "melobie", 401, 3)
+
+	// A range the backend says not to store isn't kept, and one to keep
+	// for less than the index's TTL is kept for that long.
+	clock.Advance(time.Minute)
+	cacheControl = "no-store"
+	check(f, "melobie", 401, 4)
+	check(f, "melobie", 401, 5)
+	cacheControl = "max-age=10"
+	check(f, "melobie", 401, 6)
+	check(f, "melobie", 401, 6)
+	clock.Advance(10 * time.Second)
+	check(f, "melobie", 401, 7)
+}
+
+func TestRangeIndexBudget(t *testing.T) {
+	r, err := NewFinder().parseRange(SHA1, []byte(data))
+	if err != nil {
+		t.Fatalf("unexpected: %v\n", err)
+	}
+	cost := r.size() + indexEntryOverhead
+	b := NewMemoryBudget(2*cost - 1)
+	x := NewRangeIndex(4, time.Minute, WithRangeIndexBudget(b))
+
+	now := time.Now()
+	x.put(now, "", 1, r, 0)
+	x.put(now, "", 2, r, 0)
+	if _, ok := x.get(now, "", 2); ok {
+		t.Errorf("expected a range past the budget not to be kept\n")
+	}
+	if got := b.Used(); got != cost {
+		t.Errorf("expected %d: %d\n", cost, got)
+	}
+
+	// Replacing a range gives back what it took.
+	x.put(now, "", 5, r, 0)
+	if _, ok := x.get(now, "", 5); !ok {
+		t.Errorf("expected the replacement to be kept\n")
+	}
+	if _, ok := x.get(now, "", 1); ok {
+		t.Errorf("expected the replaced range to be gone\n")
+	}
+	if got := x.Stats(); got.Entries != 1 || got.Bytes != cost || b.Used() != cost {
+		t.Errorf("expected [1, %d, %d]: %d, %d, %d\n", cost, cost, got.Entries, got.Bytes, b.Used())
+	}
+}
+
+// indexedFinder returns a Finder with a RangeIndex, and whatever else
+// options add, that has looked up melobie once.
+func indexedFinder(tb testing.TB, options ...func(*Finder)) *Finder {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(data))
+	}))
+	tb.Cleanup(ts.Close)
+	f := NewFinder(append([]func(*Finder){
+		WithClient(ts.Client()),
+		WithURLTemplate(fmt.Sprintf("%s/%%s", ts.URL)),
+	}, options...)...)
+	if n, err := f.FindPassword("melobie"); n != 401 || err != nil {
+		tb.Fatalf("expected [401, nil]: %d, %v\n", n, err)
+	}
+	return f
+}
+
+func TestIndexCountAllocs(t *testing.T) {
+	f := indexedFinder(t, WithRangeIndex(NewRangeIndex(16, time.Hour)))
+	sum := sha1.Sum([]byte("melobie"))
+	miss := sha1.Sum([]byte("gonna-miss"))
+	allocs := testing.AllocsPerRun(100, func() {
+		if n, err := f.Find(sum[:]); n != 401 || err != nil {
+			t.Errorf("expected [401, nil]: %d, %v\n", n, err)
+		}
+		if n, err := f.Find(miss[:]); n != 0 || err != nil {
+			t.Errorf("expected [0, nil]: %d, %v\n", n, err)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("expected no allocations: %v\n", allocs)
+	}
+}
+
+func BenchmarkFind(b *testing.B) {
+	sum := sha1.Sum([]byte("melobie"))
+	benchmarks := []struct {
+		name    string
+		options []func(*Finder)
+	}{
+		{"MemoryCache", []func(*Finder){WithCache(NewMemoryCache(16, time.Hour))}},
+		{"ResultCache", []func(*Finder){WithCache(NewMemoryCache(16, time.Hour)), WithResultCache(NewResultCache(nil, 16, time.Hour))}},
+		{"RangeIndex", []func(*Finder){WithRangeIndex(NewRangeIndex(16, time.Hour))}},
+	}
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			f := indexedFinder(b, bm.options...)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				f.Find(sum[:])
+			}
+		})
+		b.Run(bm.name+"Parallel", func(b *testing.B) {
+			f := indexedFinder(b, bm.options...)
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					f.Find(sum[:])
+				}
+			})
+		})
+	}
+}
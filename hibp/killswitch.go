@@ -0,0 +1,56 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import "errors"
+
+// ErrDisabled is returned by Find while the kill switch is on and the
+// fallback is DenyOnError.
+var ErrDisabled = errors.New("hibp: breach checking disabled")
+
+// WithKillSwitch lets breach checking be turned off at runtime, for when
+// it has to stop instantly during an incident. While disabled returns
+// true, lookups don't touch the backend and are answered according to
+// fallback: AllowOnError reports a zero count, anything else returns
+// ErrDisabled. Inputs are still validated, and the Blocklist and Shortlist,
+// which need no backend, still apply.
+//
+// disabled is called on every lookup, from many goroutines at once, so it
+// should be cheap and safe for concurrent use; the Load method of an
+// atomic.Bool is a good fit.
+func WithKillSwitch(disabled func() bool, fallback DegradedMode) func(f *Finder) {
+	return func(f *Finder) {
+		f.killed = disabled
+		f.killFallback = fallback
+	}
+}
+
+// disabled reports whether the kill switch is on, and if so what lookup
+// should return instead.
+func (f *Finder) disabled() (bool, error) {
+	if f.killed == nil || !f.killed() {
+		return false, nil
+	}
+	if f.killFallback == AllowOnError {
+		return true, &unverifiedError{ErrDisabled}
+	}
+	return true, ErrDisabled
+}
@@ -0,0 +1,119 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestKillSwitch(t *testing.T) {
+	var hits int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		fmt.Fprint(w, data)
+	}))
+	defer ts.Close()
+
+	testCases := []struct {
+		name     string
+		fallback DegradedMode
+		exErr    error
+	}{
+		{
+			"deny",
+			DenyOnError,
+			ErrDisabled,
+		},
+		{
+			"allow",
+			AllowOnError,
+			nil,
+		},
+	}
+
+	h := sha1.Sum([]byte("melobie"))
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			atomic.StoreInt32(&hits, 0)
+			var off atomic.Bool
+			f := NewFinder(
+				WithClient(ts.Client()),
+				WithURLTemplate(fmt.Sprintf("%s/%%s", ts.URL)),
+				WithKillSwitch(off.Load, tc.fallback),
+			)
+
+			n, err := f.Find(h[:])
+			if err != nil || n != 401 {
+				t.Errorf("expected 401: %d, %v\n", n, err)
+			}
+
+			off.Store(true)
+			n, err = f.Find(h[:])
+			if n != 0 {
+				t.Errorf("expected 0: %d\n", n)
+			}
+			if err != tc.exErr {
+				t.Errorf("expected %v: %v\n", tc.exErr, err)
+			}
+			if got := atomic.LoadInt32(&hits); got != 1 {
+				t.Errorf("expected 1 request: %d\n", got)
+			}
+
+			// Bad input is still rejected.
+			if _, err = f.Find(h[:10]); !errors.Is(err, io.ErrShortBuffer) {
+				t.Errorf("expected %v: %v\n", io.ErrShortBuffer, err)
+			}
+
+			off.Store(false)
+			if n, err = f.Find(h[:]); err != nil || n != 401 {
+				t.Errorf("expected 401: %d, %v\n", n, err)
+			}
+		})
+	}
+}
+
+func TestClassifyDisabled(t *testing.T) {
+	if code := Classify(ErrDisabled); code != CodeDisabled {
+		t.Errorf("expected %v: %v\n", CodeDisabled, code)
+	}
+}
+
+func TestKillSwitchLocalChecks(t *testing.T) {
+	f := NewFinder(
+		WithBlocklist(NewBlocklist("melobie")),
+		WithKillSwitch(func() bool { return true }, AllowOnError),
+	)
+
+	// A blocklisted password is never let through.
+	if _, err := f.FindPassword("melobie"); err != ErrBlocklisted {
+		t.Errorf("expected %v: %v\n", ErrBlocklisted, err)
+	}
+	if n, err := f.FindPassword("lauragpe"); n != 0 || err != nil {
+		t.Errorf("expected [0, nil]: %d, %v\n", n, err)
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import "errors"
+
+// DefaultMaxLineLength is the longest line accepted in a range response.
+// Real lines are under 50 bytes; anything near this limit is not range data.
+const DefaultMaxLineLength = 256
+
+// DefaultMaxBodySize is the largest range response that is read. Real
+// responses, padded or not, are a small fraction of this.
+const DefaultMaxBodySize = 1 << 20
+
+// The errors returned for range responses that can't be range data. They
+// are wrapped with detail about the offending input; use errors.Is to test
+// for them.
+var (
+	// ErrMalformedLine is for a line not of the form SUFFIX:COUNT.
+	ErrMalformedLine = errors.New(errMsgFormat)
+	// ErrInvalidCount is for a count that isn't a non-negative decimal
+	// number that fits in an int64.
+	ErrInvalidCount = errors.New("hibp: invalid count")
+	// ErrLineTooLong is for a line longer than the maximum line length.
+	ErrLineTooLong = errors.New("hibp: line too long")
+	// ErrBodyTooLarge is for a response larger than the maximum body size.
+	ErrBodyTooLarge = errors.New("hibp: response body too large")
+)
+
+// WithMaxLineLength replaces DefaultMaxLineLength as the longest line
+// accepted in a range response.
+func WithMaxLineLength(n int) func(f *Finder) {
+	return func(f *Finder) {
+		f.maxLine = n
+	}
+}
+
+// WithMaxBodySize replaces DefaultMaxBodySize as the largest range response
+// that is read.
+func WithMaxBodySize(n int64) func(f *Finder) {
+	return func(f *Finder) {
+		f.maxBody = n
+	}
+}
+
+func (f *Finder) maxLineLength() int {
+	if f.maxLine <= 0 {
+		return DefaultMaxLineLength
+	}
+	return f.maxLine
+}
+
+func (f *Finder) maxBodySize() int64 {
+	if f.maxBody <= 0 {
+		return DefaultMaxBodySize
+	}
+	return f.maxBody
+}
@@ -0,0 +1,215 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseCountHardening(t *testing.T) {
+	testCases := []struct {
+		name string
+		line string
+		xErr error
+	}{
+		{
+			"enormous count",
+			"alpha:99999999999999999999999",
+			ErrInvalidCount,
+		},
+		{
+			"negative count",
+			"alpha:-5",
+			ErrInvalidCount,
+		},
+		{
+			"signed count",
+			"alpha:+5",
+			ErrInvalidCount,
+		},
+		{
+			"NUL in count",
+			"alpha:5\x00",
+			ErrInvalidCount,
+		},
+		{
+			"invalid UTF-8",
+			"alpha:\xff\xfe",
+			ErrInvalidCount,
+		},
+		{
+			"no delim",
+			"alpha\x00",
+			ErrMalformedLine,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			n, err := parseCount([]byte(tc.line))
+			if n != 0 || !errors.Is(err, tc.xErr) {
+				t.Errorf("expected [0, %v]: %d, %v\n", tc.xErr, n, err)
+			}
+		})
+	}
+}
+
+func TestFindSuffixLineTooLong(t *testing.T) {
+	long := strings.Repeat("A", 100)
+	testCases := []struct {
+		name    string
+		content string
+		maxLine int
+		xErr    error
+	}{
+		{
+			"fits",
+			"alpha:1\r\n" + long + "\r\n",
+			100,
+			nil,
+		},
+		{
+			"too long",
+			"alpha:1\n" + long + "A\n",
+			100,
+			ErrLineTooLong,
+		},
+		{
+			"too long at EOF",
+			"alpha:1\n" + long + "A",
+			100,
+			ErrLineTooLong,
+		},
+		{
+			"megabyte line",
+			strings.Repeat("0", 1<<20),
+			DefaultMaxLineLength,
+			ErrLineTooLong,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := findSuffix([]byte("omega"), strings.NewReader(tc.content), tc.maxLine)
+			if !errors.Is(err, tc.xErr) {
+				t.Errorf("expected %v: %v\n", tc.xErr, err)
+			}
+		})
+	}
+}
+
+func TestMaxBodySize(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(data))
+	}))
+	defer ts.Close()
+
+	h := sha1.Sum([]byte("melobie"))
+	testCases := []struct {
+		name string
+		max  int64
+		xErr error
+	}{
+		{
+			"default",
+			0,
+			nil,
+		},
+		{
+			"exact",
+			int64(len(data)),
+			nil,
+		},
+		{
+			"too small",
+			int64(len(data)) - 1,
+			ErrBodyTooLarge,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			f := NewFinder(
+				WithClient(ts.Client()),
+				WithURLTemplate(fmt.Sprintf("%s/%%s", ts.URL)),
+				WithMaxBodySize(tc.max),
+			)
+			_, err := f.Find(h[:])
+			if !errors.Is(err, tc.xErr) {
+				t.Errorf("expected %v: %v\n", tc.xErr, err)
+			}
+		})
+	}
+}
+
+func FuzzParseCount(f *testing.F) {
+	for _, seed := range []string{
+		"", ":", "::", "alpha:", "alpha:117", "alpha:2345678901",
+		"alpha:9223372036854775807", "alpha:9223372036854775808",
+		"alpha:-1", "alpha:\x00", "\xff:\xfe", "0018A45C4D1DEF81644B54AB7F969B88D65:229",
+	} {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, line []byte) {
+		n, err := parseCount(line)
+		if err != nil {
+			if n != 0 {
+				t.Errorf("expected 0 with error: %d\n", n)
+			}
+			if !errors.Is(err, ErrMalformedLine) && !errors.Is(err, ErrInvalidCount) {
+				t.Errorf("untyped error: %v\n", err)
+			}
+			return
+		}
+		if n < 0 {
+			t.Errorf("negative count: %d\n", n)
+		}
+	})
+}
+
+func FuzzFindSuffix(f *testing.F) {
+	f.Add([]byte("alpha"), []byte(scanContent))
+	f.Add([]byte("0018A45C4D1DEF81644B54AB7F969B88D65"), []byte(data))
+	f.Add([]byte("alpha"), []byte("\x00\x00\nalpha:1\r\n"))
+	f.Add([]byte("alpha"), bytes.Repeat([]byte("A"), DefaultMaxLineLength+1))
+	f.Fuzz(func(t *testing.T, suffix, content []byte) {
+		line, err := findSuffix(suffix, bytes.NewReader(content), DefaultMaxLineLength)
+		if err != nil {
+			if !errors.Is(err, ErrLineTooLong) {
+				t.Errorf("untyped error: %v\n", err)
+			}
+			return
+		}
+		if line != nil && !bytes.HasPrefix(line, suffix) {
+			t.Errorf("%q does not start with %q\n", line, suffix)
+		}
+		if len(line) > DefaultMaxLineLength {
+			t.Errorf("line longer than limit: %d\n", len(line))
+		}
+	})
+}
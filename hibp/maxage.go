@@ -0,0 +1,64 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxAge returns how long a response with header h may be cached for, per
+// its Cache-Control and Age headers: zero when they don't say, leaving it
+// to the cache, and a negative duration when the response mustn't be
+// cached at all.
+func maxAge(h http.Header) time.Duration {
+	age := time.Duration(-1)
+	for _, cc := range h.Values("Cache-Control") {
+		for _, dir := range strings.Split(cc, ",") {
+			name, value := strings.TrimSpace(dir), ""
+			if i := strings.IndexByte(name, '='); i >= 0 {
+				name, value = strings.TrimSpace(name[:i]), strings.Trim(strings.TrimSpace(name[i+1:]), `"`)
+			}
+			switch strings.ToLower(name) {
+			case "no-store", "no-cache":
+				return -1
+			case "max-age":
+				secs, err := strconv.ParseInt(value, 10, 64)
+				if err != nil || secs < 0 {
+					return -1
+				}
+				age = time.Duration(secs) * time.Second
+			}
+		}
+	}
+	if age < 0 {
+		return 0
+	}
+	if secs, err := strconv.ParseInt(strings.TrimSpace(h.Get("Age")), 10, 64); err == nil && secs > 0 {
+		age -= time.Duration(secs) * time.Second
+	}
+	if age <= 0 {
+		return -1
+	}
+	return age
+}
@@ -0,0 +1,178 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMaxAge(t *testing.T) {
+	testCases := []struct {
+		name string
+		cc   []string
+		age  string
+		exp  time.Duration
+	}{
+		{
+			"absent",
+			nil,
+			"",
+			0,
+		},
+		{
+			"max-age",
+			[]string{"public, max-age=2678400"},
+			"",
+			31 * 24 * time.Hour,
+		},
+		{
+			"less age",
+			[]string{"max-age=600"},
+			"120",
+			8 * time.Minute,
+		},
+		{
+			"older than max-age",
+			[]string{"max-age=600"},
+			"600",
+			-1,
+		},
+		{
+			"quoted and cased",
+			[]string{`Max-Age="60"`},
+			"",
+			time.Minute,
+		},
+		{
+			"zero",
+			[]string{"max-age=0"},
+			"",
+			-1,
+		},
+		{
+			"malformed",
+			[]string{"max-age=soon"},
+			"",
+			-1,
+		},
+		{
+			"no-store",
+			[]string{"max-age=600", "no-store"},
+			"",
+			-1,
+		},
+		{
+			"no-cache",
+			[]string{"no-cache, max-age=600"},
+			"",
+			-1,
+		},
+		{
+			"other directives",
+			[]string{"public, immutable"},
+			"",
+			0,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := http.Header{}
+			for _, v := range tc.cc {
+				h.Add("Cache-Control", v)
+			}
+			if tc.age != "" {
+				h.Set("Age", tc.age)
+			}
+			if got := maxAge(h); got != tc.exp {
+				t.Errorf("expected %v: %v\n", tc.exp, got)
+			}
+		})
+	}
+}
+
+type ttlCache struct {
+	mapCache
+	ttls map[string]time.Duration
+}
+
+func (c *ttlCache) Set(key string, body []byte, ttl time.Duration) {
+	c.mapCache.Set(key, body, ttl)
+	c.ttls[key] = ttl
+}
+
+func TestCacheMaxAge(t *testing.T) {
+	testCases := []struct {
+		name   string
+		cc     string
+		stored bool
+		ttl    time.Duration
+	}{
+		{
+			"max-age",
+			"public, max-age=3600",
+			true,
+			time.Hour,
+		},
+		{
+			"default",
+			"",
+			true,
+			0,
+		},
+		{
+			"no-store",
+			"no-store",
+			false,
+			0,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tc.cc != "" {
+					w.Header().Set("Cache-Control", tc.cc)
+				}
+				w.Write([]byte(data))
+			}))
+			defer ts.Close()
+
+			c := &ttlCache{mapCache{}, map[string]time.Duration{}}
+			f := NewFinder(
+				WithClient(ts.Client()),
+				WithURLTemplate(fmt.Sprintf("%s/%%s", ts.URL)),
+				WithCache(c),
+			)
+			if n, err := f.FindPassword("melobie"); n != 401 || err != nil {
+				t.Fatalf("expected [401, nil]: %d, %v\n", n, err)
+			}
+			ttl, stored := c.ttls[f.cacheKey(SHA1, []byte("21BD1"))]
+			if stored != tc.stored || ttl != tc.ttl {
+				t.Errorf("expected [%t, %v]: %t, %v\n", tc.stored, tc.ttl, stored, ttl)
+			}
+		})
+	}
+}
@@ -0,0 +1,294 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package memcache provides a hibp.Cache stored in memcached, so that a
+// fleet of instances can share the range bodies any one of them has
+// retrieved. The instances must be given the same hibp.WithCacheSecret to
+// agree on keys, which are HMACs of the ranges rather than the ranges
+// themselves.
+//
+// It speaks just enough of the memcached text protocol for the cache (get,
+// set and delete), over a small pool of connections. Errors talking to
+// memcached are not reported: the cache misses, or doesn't store, and
+// lookups go to the backend as they would without it. An item longer than
+// the cache's maximum size is treated as an error too, so a misbehaving
+// server can't have it allocate without bound.
+package memcache
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Defaults for a Cache created without the corresponding option.
+const (
+	DefaultTTL     = 24 * time.Hour
+	DefaultTimeout = time.Second
+	DefaultPrefix  = "hibp:"
+	DefaultIdle    = 4
+	DefaultMaxSize = 1 << 20
+)
+
+// maxRelative is the longest expiry memcached takes as relative; longer
+// ones must be given as a Unix time.
+const maxRelative = 30 * 24 * time.Hour
+
+// Cache is a hibp.Cache stored in memcached. It is safe for concurrent use.
+type Cache struct {
+	addr    string
+	prefix  string
+	ttl     time.Duration
+	timeout time.Duration
+	maxSize int
+
+	idle chan *conn
+}
+
+// New returns a Cache using the memcached server at addr (host:port).
+// Connections are made as they are needed.
+func New(addr string, options ...func(*Cache)) *Cache {
+	c := &Cache{
+		addr:    addr,
+		prefix:  DefaultPrefix,
+		ttl:     DefaultTTL,
+		timeout: DefaultTimeout,
+		maxSize: DefaultMaxSize,
+		idle:    make(chan *conn, DefaultIdle),
+	}
+	for _, opt := range options {
+		opt(c)
+	}
+	return c
+}
+
+// WithPrefix replaces DefaultPrefix as what keys are prefixed with, to keep
+// them apart from other uses of the server.
+func WithPrefix(prefix string) func(*Cache) {
+	return func(c *Cache) {
+		c.prefix = prefix
+	}
+}
+
+// WithTTL replaces DefaultTTL as how long bodies are kept when they aren't
+// given a TTL of their own.
+func WithTTL(ttl time.Duration) func(*Cache) {
+	return func(c *Cache) {
+		c.ttl = ttl
+	}
+}
+
+// WithTimeout replaces DefaultTimeout as the limit on each exchange with
+// the server, so a slow cache can't hold up lookups.
+func WithTimeout(d time.Duration) func(*Cache) {
+	return func(c *Cache) {
+		c.timeout = d
+	}
+}
+
+// WithMaxSize replaces DefaultMaxSize as the longest item, in bytes, read
+// back from the server; a longer one is a miss. It should be at least the
+// Finder's maximum body size (see hibp.WithMaxBodySize).
+func WithMaxSize(n int) func(*Cache) {
+	return func(c *Cache) {
+		c.maxSize = n
+	}
+}
+
+// Get implements hibp.Cache.
+func (c *Cache) Get(key string) ([]byte, bool) {
+	var body []byte
+	err := c.do(func(cn *conn) error {
+		var err error
+		body, err = cn.get(c.prefix + key)
+		return err
+	})
+	return body, err == nil && body != nil
+}
+
+// Set implements hibp.Cache.
+func (c *Cache) Set(key string, body []byte, ttl time.Duration) {
+	if ttl <= 0 {
+		ttl = c.ttl
+	}
+	if ttl <= 0 {
+		return
+	}
+	c.do(func(cn *conn) error {
+		return cn.set(c.prefix+key, body, expiry(ttl, time.Now()))
+	})
+}
+
+// Delete implements hibp.Cache.
+func (c *Cache) Delete(key string) {
+	c.do(func(cn *conn) error {
+		return cn.delete(c.prefix + key)
+	})
+}
+
+// Close closes the idle connections.
+func (c *Cache) Close() error {
+	for {
+		select {
+		case cn := <-c.idle:
+			cn.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+// expiry returns the exptime to store an item with for ttl.
+func expiry(ttl time.Duration, now time.Time) int64 {
+	if ttl > maxRelative {
+		return now.Add(ttl).Unix()
+	}
+	// Round up, so short TTLs don't become 0, which means forever.
+	return int64((ttl + time.Second - 1) / time.Second)
+}
+
+// do runs fn with a connection, which is reused afterwards unless fn
+// failed in a way that may have left it out of step.
+func (c *Cache) do(fn func(*conn) error) error {
+	var cn *conn
+	select {
+	case cn = <-c.idle:
+	default:
+		nc, err := net.DialTimeout("tcp", c.addr, c.timeout)
+		if err != nil {
+			return err
+		}
+		cn = &conn{nc, bufio.NewReader(nc), c.maxSize}
+	}
+	cn.SetDeadline(time.Now().Add(c.timeout))
+	err := fn(cn)
+	if _, ok := err.(serverError); err != nil && !ok {
+		cn.Close()
+		return err
+	}
+	c.put(cn)
+	return err
+}
+
+// put returns a connection to the idle pool, or closes it if that's full.
+func (c *Cache) put(cn *conn) {
+	select {
+	case c.idle <- cn:
+	default:
+		cn.Close()
+	}
+}
+
+// serverError is an error reply from the server; the connection is still
+// usable after one.
+type serverError string
+
+func (e serverError) Error() string {
+	return "memcache: " + string(e)
+}
+
+// conn is a connection to the server.
+type conn struct {
+	net.Conn
+	r   *bufio.Reader
+	max int // longest item read
+}
+
+// line reads a reply line, without its "\r\n".
+func (cn *conn) line() (string, error) {
+	line, err := cn.r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasSuffix(line, "\r\n") {
+		return "", fmt.Errorf("memcache: malformed reply %q", line)
+	}
+	line = line[:len(line)-2]
+	if line == "ERROR" || strings.HasPrefix(line, "CLIENT_ERROR ") || strings.HasPrefix(line, "SERVER_ERROR ") {
+		return "", serverError(line)
+	}
+	return line, nil
+}
+
+// get returns the item stored for key, or nil if there is none.
+func (cn *conn) get(key string) ([]byte, error) {
+	if _, err := fmt.Fprintf(cn, "get %s\r\n", key); err != nil {
+		return nil, err
+	}
+	var body []byte
+	for {
+		line, err := cn.line()
+		if err != nil {
+			return nil, err
+		}
+		if line == "END" {
+			return body, nil
+		}
+		// VALUE <key> <flags> <bytes>
+		fields := strings.Fields(line)
+		if len(fields) != 4 || fields[0] != "VALUE" {
+			return nil, fmt.Errorf("memcache: unexpected reply %q", line)
+		}
+		n, err := strconv.Atoi(fields[3])
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("memcache: unexpected reply %q", line)
+		}
+		if n > cn.max {
+			return nil, fmt.Errorf("memcache: item of %d bytes exceeds the maximum of %d", n, cn.max)
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(cn.r, buf); err != nil {
+			return nil, err
+		}
+		body = buf[:n]
+	}
+}
+
+func (cn *conn) set(key string, body []byte, exptime int64) error {
+	if _, err := fmt.Fprintf(cn, "set %s 0 %d %d\r\n%s\r\n", key, exptime, len(body), body); err != nil {
+		return err
+	}
+	line, err := cn.line()
+	if err != nil {
+		return err
+	}
+	if line != "STORED" {
+		return serverError(line)
+	}
+	return nil
+}
+
+func (cn *conn) delete(key string) error {
+	if _, err := fmt.Fprintf(cn, "delete %s\r\n", key); err != nil {
+		return err
+	}
+	line, err := cn.line()
+	if err != nil {
+		return err
+	}
+	if line != "DELETED" && line != "NOT_FOUND" {
+		return serverError(line)
+	}
+	return nil
+}
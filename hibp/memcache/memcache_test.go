@@ -0,0 +1,213 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package memcache
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nelz9999/go-hibp/hibp"
+)
+
+var _ hibp.Cache = (*Cache)(nil)
+
+// fakeMemcached understands the commands the Cache sends, and records them.
+type fakeMemcached struct {
+	net.Listener
+	mu       sync.Mutex
+	data     map[string]string
+	commands []string
+	conns    int
+}
+
+func newFakeMemcached(t *testing.T) *fakeMemcached {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected: %v\n", err)
+	}
+	s := &fakeMemcached{Listener: l, data: map[string]string{}}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.conns++
+			s.mu.Unlock()
+			go s.serve(c)
+		}
+	}()
+	return s
+}
+
+func (s *fakeMemcached) serve(c net.Conn) {
+	defer c.Close()
+	r := bufio.NewReader(c)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		args := strings.Fields(line)
+		var body string
+		if args[0] == "set" {
+			var n int
+			fmt.Sscan(args[4], &n)
+			buf := make([]byte, n+2)
+			if _, err := io.ReadFull(r, buf); err != nil {
+				return
+			}
+			body = string(buf[:n])
+		}
+
+		s.mu.Lock()
+		s.commands = append(s.commands, strings.Join(args, " "))
+		switch args[0] {
+		case "get":
+			if v, ok := s.data[args[1]]; ok {
+				fmt.Fprintf(c, "VALUE %s 0 %d\r\n%s\r\n", args[1], len(v), v)
+			}
+			fmt.Fprint(c, "END\r\n")
+		case "set":
+			if len(args[1]) > 250 {
+				fmt.Fprint(c, "CLIENT_ERROR key too long\r\n")
+				break
+			}
+			s.data[args[1]] = body
+			fmt.Fprint(c, "STORED\r\n")
+		case "delete":
+			if _, ok := s.data[args[1]]; ok {
+				delete(s.data, args[1])
+				fmt.Fprint(c, "DELETED\r\n")
+			} else {
+				fmt.Fprint(c, "NOT_FOUND\r\n")
+			}
+		default:
+			fmt.Fprint(c, "ERROR\r\n")
+		}
+		s.mu.Unlock()
+	}
+}
+
+func TestCache(t *testing.T) {
+	s := newFakeMemcached(t)
+	c := New(s.Addr().String(), WithPrefix("test:"), WithTTL(time.Minute))
+	defer c.Close()
+
+	body := "0018A45C4D1DEF81644B54AB7F969B88D65:229\r\nEND\r\n"
+	if _, ok := c.Get("sha1:21BD1"); ok {
+		t.Errorf("expected a miss\n")
+	}
+	c.Set("sha1:21BD1", []byte(body), 0)
+	if got, ok := c.Get("sha1:21BD1"); !ok || string(got) != body {
+		t.Errorf("expected a hit: %q, %t\n", got, ok)
+	}
+	c.Set("sha1:FFFFF", []byte("x"), 1500*time.Millisecond)
+	c.Delete("sha1:21BD1")
+	c.Delete("sha1:21BD1")
+	if _, ok := c.Get("sha1:21BD1"); ok {
+		t.Errorf("expected a miss after delete\n")
+	}
+	// An error reply doesn't lose the connection.
+	c.Set(strings.Repeat("k", 300), []byte("x"), 0)
+	if _, ok := c.Get("sha1:FFFFF"); !ok {
+		t.Errorf("expected a hit\n")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	exp := strings.Join([]string{
+		"get test:sha1:21BD1",
+		fmt.Sprintf("set test:sha1:21BD1 0 60 %d", len(body)),
+		"get test:sha1:21BD1",
+		"set test:sha1:FFFFF 0 2 1",
+		"delete test:sha1:21BD1",
+		"delete test:sha1:21BD1",
+		"get test:sha1:21BD1",
+		fmt.Sprintf("set test:%s 0 60 1", strings.Repeat("k", 300)),
+		"get test:sha1:FFFFF",
+	}, "\n")
+	if got := strings.Join(s.commands, "\n"); got != exp {
+		t.Errorf("expected:\n%s\ngot:\n%s\n", exp, got)
+	}
+	if s.conns != 1 {
+		t.Errorf("expected the connection reused: %d\n", s.conns)
+	}
+}
+
+func TestCacheUnreachable(t *testing.T) {
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	addr := l.Addr().String()
+	l.Close()
+
+	c := New(addr, WithTimeout(100*time.Millisecond))
+	c.Set("sha1:21BD1", []byte("x"), 0)
+	if _, ok := c.Get("sha1:21BD1"); ok {
+		t.Errorf("expected a miss\n")
+	}
+}
+
+func TestCacheMaxSize(t *testing.T) {
+	s := newFakeMemcached(t)
+	c := New(s.Addr().String(), WithMaxSize(4))
+	defer c.Close()
+
+	c.Set("sha1:21BD1", []byte("12345"), 0)
+	if body, ok := c.Get("sha1:21BD1"); ok {
+		t.Errorf("expected a miss: %q\n", body)
+	}
+	c.Set("sha1:21BD1", []byte("1234"), 0)
+	if body, ok := c.Get("sha1:21BD1"); !ok || string(body) != "1234" {
+		t.Errorf("expected a hit: %q, %t\n", body, ok)
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	now := time.Unix(1500000000, 0)
+
+	testCases := []struct {
+		name string
+		ttl  time.Duration
+		exp  int64
+	}{
+		{"sub-second", time.Millisecond, 1},
+		{"seconds", 90 * time.Second, 90},
+		{"rounded up", 1500 * time.Millisecond, 2},
+		{"30 days", 30 * 24 * time.Hour, 30 * 24 * 60 * 60},
+		{"absolute", 31 * 24 * time.Hour, 1500000000 + 31*24*60*60},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := expiry(tc.ttl, now); got != tc.exp {
+				t.Errorf("expected %d: %d\n", tc.exp, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,216 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// memoryEntryOverhead estimates what a MemoryCache entry takes beyond its
+// key and body: the entry itself, its list element and its map slot.
+const memoryEntryOverhead = 160
+
+// MemoryCache is a Cache of range bodies held in memory, dropping the least
+// recently used when full. Entries stop being fresh after the TTL, but are
+// kept until they are evicted, for StaleCacheOnError. It is safe for
+// concurrent use.
+type MemoryCache struct {
+	size     int
+	maxBytes int64
+	budget   *MemoryBudget
+	ttl      time.Duration
+	clock    Clock
+
+	hits   uint64
+	misses uint64
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+	bytes   int64
+}
+
+type memoryEntry struct {
+	key     string
+	body    []byte
+	expires time.Time
+	cost    int64
+}
+
+// CacheStats counts how a cache has been used, and what it holds.
+type CacheStats struct {
+	Hits   uint64
+	Misses uint64
+
+	// Entries and Bytes are what the cache held when Stats was called.
+	// For caches in memory, Bytes includes an estimate of the overhead of
+	// each entry.
+	Entries int
+	Bytes   int64
+}
+
+// NewMemoryCache returns a MemoryCache holding up to size range bodies, each
+// fresh for ttl unless stored with a TTL of its own. A range is 20-35KB, so
+// a size of 1000 takes up to around 35MB; WithMemoryCacheMaxBytes bounds
+// the cache by memory instead, and then a size of 0 doesn't limit the
+// number of entries.
+func NewMemoryCache(size int, ttl time.Duration, options ...func(*MemoryCache)) *MemoryCache {
+	c := &MemoryCache{
+		size:    size,
+		ttl:     ttl,
+		clock:   systemClock{},
+		entries: map[string]*list.Element{},
+		lru:     list.New(),
+	}
+	for _, opt := range options {
+		opt(c)
+	}
+	return c
+}
+
+// WithMemoryCacheClock replaces the system clock the MemoryCache expires
+// entries by. (A cache may be shared by Finders, so it doesn't take the
+// clock of a Finder it is given to; see WithClock.)
+func WithMemoryCacheClock(clock Clock) func(c *MemoryCache) {
+	return func(c *MemoryCache) {
+		c.clock = clock
+	}
+}
+
+// WithMemoryCacheMaxBytes limits the memory the MemoryCache takes to n
+// bytes, counting each entry's key, body and bookkeeping.
+func WithMemoryCacheMaxBytes(n int64) func(c *MemoryCache) {
+	return func(c *MemoryCache) {
+		c.maxBytes = n
+	}
+}
+
+// WithMemoryCacheBudget has the MemoryCache draw the memory it takes from
+// b, which may be shared with other caches.
+func WithMemoryCacheBudget(b *MemoryBudget) func(c *MemoryCache) {
+	return func(c *MemoryCache) {
+		c.budget = b
+	}
+}
+
+// Get implements Cache.
+func (c *MemoryCache) Get(key string) ([]byte, bool) {
+	now := c.clock.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok || !now.Before(el.Value.(*memoryEntry).expires) {
+		atomic.AddUint64(&c.misses, 1)
+		return nil, false
+	}
+	c.lru.MoveToFront(el)
+	atomic.AddUint64(&c.hits, 1)
+	return el.Value.(*memoryEntry).body, true
+}
+
+// GetStale implements StaleCache. It doesn't count as a hit or a miss.
+func (c *MemoryCache) GetStale(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	return el.Value.(*memoryEntry).body, true
+}
+
+// Set implements Cache.
+func (c *MemoryCache) Set(key string, body []byte, ttl time.Duration) {
+	if ttl <= 0 {
+		ttl = c.ttl
+	}
+	if (c.size <= 0 && c.maxBytes <= 0) || ttl <= 0 {
+		return
+	}
+	expires := c.clock.Now().Add(ttl)
+	cost := int64(len(key)+len(body)) + memoryEntryOverhead
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		c.remove(el)
+	}
+	// Make room by evicting the least recently used entries; if the cache
+	// is empty and there still isn't room, the body isn't kept.
+	for !c.fits(cost) || (c.budget != nil && !c.budget.reserve(cost)) {
+		if c.lru.Len() == 0 {
+			return
+		}
+		c.remove(c.lru.Back())
+	}
+	c.entries[key] = c.lru.PushFront(&memoryEntry{key: key, body: body, expires: expires, cost: cost})
+	c.bytes += cost
+}
+
+// fits reports whether an entry of cost bytes can be added within the
+// cache's own limits. c.mu must be held.
+func (c *MemoryCache) fits(cost int64) bool {
+	return (c.size <= 0 || c.lru.Len() < c.size) &&
+		(c.maxBytes <= 0 || c.bytes+cost <= c.maxBytes)
+}
+
+// remove drops el, returning its memory. c.mu must be held.
+func (c *MemoryCache) remove(el *list.Element) {
+	e := el.Value.(*memoryEntry)
+	c.lru.Remove(el)
+	delete(c.entries, e.key)
+	c.bytes -= e.cost
+	if c.budget != nil {
+		c.budget.release(e.cost)
+	}
+}
+
+// Delete implements Cache.
+func (c *MemoryCache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		c.remove(el)
+	}
+}
+
+// Len returns the number of entries held, including ones that are no
+// longer fresh.
+func (c *MemoryCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+// Stats returns the number of hits and misses so far, and what the cache
+// holds.
+func (c *MemoryCache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return CacheStats{
+		Hits:    atomic.LoadUint64(&c.hits),
+		Misses:  atomic.LoadUint64(&c.misses),
+		Entries: c.lru.Len(),
+		Bytes:   c.bytes,
+	}
+}
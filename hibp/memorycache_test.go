@@ -0,0 +1,153 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemoryCache(t *testing.T) {
+	clock := newFakeClock()
+	c := NewMemoryCache(2, time.Minute, WithMemoryCacheClock(clock))
+
+	check := func(key, exp string, exOK bool) {
+		t.Helper()
+		body, ok := c.Get(key)
+		if string(body) != exp || ok != exOK {
+			t.Errorf("expected [%q, %t]: %q, %t\n", exp, exOK, body, ok)
+		}
+	}
+
+	check("a", "", false)
+	c.Set("a", []byte("alpha"), 0)
+	c.Set("b", []byte("bravo"), 0)
+	check("a", "alpha", true)
+
+	// Evicts b, the least recently used.
+	c.Set("c", []byte("charlie"), 0)
+	check("b", "", false)
+	check("c", "charlie", true)
+	if n := c.Len(); n != 2 {
+		t.Errorf("expected 2 entries: %d\n", n)
+	}
+
+	clock.Advance(time.Minute)
+	check("a", "", false)
+	if body, ok := c.GetStale("a"); string(body) != "alpha" || !ok {
+		t.Errorf("expected [alpha, true]: %q, %t\n", body, ok)
+	}
+	if _, ok := c.GetStale("b"); ok {
+		t.Errorf("expected evicted entry to be gone\n")
+	}
+
+	c.Set("a", []byte("alpha2"), 0)
+	check("a", "alpha2", true)
+
+	exp := CacheStats{Hits: 3, Misses: 3, Entries: 2, Bytes: int64(len("a")+len("alpha2")+len("c")+len("charlie")) + 2*memoryEntryOverhead}
+	if got := c.Stats(); got != exp {
+		t.Errorf("expected %+v: %+v\n", exp, got)
+	}
+
+	// An entry's own TTL overrides the default.
+	c.Set("c", []byte("charlie"), 2*time.Minute)
+	clock.Advance(time.Minute)
+	check("a", "", false)
+	check("c", "charlie", true)
+
+	c.Delete("c")
+	check("c", "", false)
+	if _, ok := c.GetStale("c"); ok {
+		t.Errorf("expected deleted entry to be gone\n")
+	}
+}
+
+func TestMemoryCacheDisabled(t *testing.T) {
+	for _, c := range []*MemoryCache{NewMemoryCache(0, time.Minute), NewMemoryCache(10, 0)} {
+		c.Set("a", []byte("alpha"), 0)
+		if c.Len() != 0 {
+			t.Errorf("expected nothing stored: %d\n", c.Len())
+		}
+	}
+}
+
+func TestMemoryCacheMaxBytes(t *testing.T) {
+	cost := func(key, body string) int64 {
+		return int64(len(key)+len(body)) + memoryEntryOverhead
+	}
+	c := NewMemoryCache(0, time.Minute, WithMemoryCacheMaxBytes(cost("a", "alpha")+cost("b", "bravo")))
+
+	c.Set("a", []byte("alpha"), 0)
+	c.Set("b", []byte("bravo"), 0)
+	if n := c.Len(); n != 2 {
+		t.Errorf("expected 2 entries: %d\n", n)
+	}
+
+	// Evicts a, then b, to make room.
+	c.Set("c", []byte("charlie"), 0)
+	if _, ok := c.Get("a"); ok {
+		t.Errorf("expected a to be evicted\n")
+	}
+	if got := c.Stats(); got.Entries != 1 || got.Bytes != cost("c", "charlie") {
+		t.Errorf("expected [1, %d]: %d, %d\n", cost("c", "charlie"), got.Entries, got.Bytes)
+	}
+
+	// A body too large for the cache isn't kept, and replacing c with it
+	// drops c.
+	c.Set("c", make([]byte, 1000), 0)
+	if got := c.Stats(); got.Entries != 0 || got.Bytes != 0 {
+		t.Errorf("expected [0, 0]: %d, %d\n", got.Entries, got.Bytes)
+	}
+}
+
+func TestMemoryCacheBudget(t *testing.T) {
+	entry := int64(len("a")+len("alpha")) + memoryEntryOverhead
+	b := NewMemoryBudget(3 * entry)
+	c1 := NewMemoryCache(10, time.Minute, WithMemoryCacheBudget(b))
+	c2 := NewMemoryCache(10, time.Minute, WithMemoryCacheBudget(b))
+
+	c1.Set("a", []byte("alpha"), 0)
+	c1.Set("b", []byte("bravo"), 0)
+	c2.Set("c", []byte("charl"), 0)
+	if exp, got := 3*entry, b.Used(); got != exp {
+		t.Errorf("expected %d: %d\n", exp, got)
+	}
+
+	// c2 only evicts its own entries, so with the budget spent by c1 it
+	// can't keep a second one.
+	c2.Set("d", []byte("delta"), 0)
+	if _, ok := c2.Get("c"); ok {
+		t.Errorf("expected c to be evicted\n")
+	}
+	if _, ok := c2.Get("d"); !ok {
+		t.Errorf("expected d to be kept\n")
+	}
+	if n := c1.Len(); n != 2 {
+		t.Errorf("expected c1 untouched: %d\n", n)
+	}
+
+	c1.Delete("a")
+	c1.Delete("b")
+	c2.Delete("d")
+	if got := b.Used(); got != 0 {
+		t.Errorf("expected 0: %d\n", got)
+	}
+}
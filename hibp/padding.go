@@ -0,0 +1,42 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import "bytes"
+
+// paddingSuffix ends the made-up lines added to padded responses.
+var paddingSuffix = []byte(":0")
+
+// WithPadding asks the API to pad responses with made-up entries (by
+// sending "Add-Padding: true"), so that the size of a response reveals
+// nothing about the prefix asked for. The made-up entries all have a count
+// of zero, and are ignored when matching.
+func WithPadding(padding bool) func(f *Finder) {
+	return func(f *Finder) {
+		f.padding = padding
+	}
+}
+
+// isPadding reports whether line is one of the made-up entries of a padded
+// response. No real entry has a count of zero.
+func isPadding(line []byte) bool {
+	return bytes.HasSuffix(line, paddingSuffix) && bytes.Count(line, delim) == 1
+}
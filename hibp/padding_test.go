@@ -0,0 +1,98 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const paddedData = `
+0018A45C4D1DEF81644B54AB7F969B88D65:229
+012A7CA357541F0AC487871FEEC1891C49C:0
+0E8B0DBE9C2C1A2F7D7A05A1B1A3BDD2A1F:0
+`
+
+func TestPadding(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Add-Padding") != "true" {
+			w.Write([]byte(data))
+			return
+		}
+		w.Write([]byte(paddedData))
+	}))
+	defer ts.Close()
+
+	f := NewFinder(
+		WithClient(ts.Client()),
+		WithURLTemplate(fmt.Sprintf("%s/%%s", ts.URL)),
+		WithPadding(true),
+	)
+
+	testCases := []struct {
+		pwd string
+		exp int64
+	}{
+		{
+			"lauragpe",
+			229,
+		},
+		{
+			// Its suffix only shows up as a padding entry here.
+			"melobie",
+			0,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.pwd, func(t *testing.T) {
+			h := sha1.Sum([]byte(tc.pwd))
+			n, err := f.Find(h[:])
+			if n != tc.exp || err != nil {
+				t.Errorf("expected [%d, nil]: %d, %v\n", tc.exp, n, err)
+			}
+		})
+	}
+}
+
+func TestIsPadding(t *testing.T) {
+	testCases := []struct {
+		line string
+		exp  bool
+	}{
+		{"0E8B0DBE9C2C1A2F7D7A05A1B1A3BDD2A1F:0", true},
+		{"0E8B0DBE9C2C1A2F7D7A05A1B1A3BDD2A1F:10", false},
+		{"0E8B0DBE9C2C1A2F7D7A05A1B1A3BDD2A1F:1", false},
+		{"0E8B0DBE9C2C1A2F7D7A05A1B1A3BDD2A1F:0:0", false},
+		{"", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.line, func(t *testing.T) {
+			if got := isPadding([]byte(tc.line)); got != tc.exp {
+				t.Errorf("expected %t: %t\n", tc.exp, got)
+			}
+		})
+	}
+}
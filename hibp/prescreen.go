@@ -0,0 +1,118 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrWeakPassword is wrapped by the error FindPassword returns for a
+// password rejected by the Finder's PreScreen.
+var ErrWeakPassword = errors.New("hibp: password fails pre-screen")
+
+// keyboardWalks are the sequences that keyboard walks are made of; they are
+// also checked in reverse.
+var keyboardWalks = []string{
+	"1234567890",
+	"qwertyuiop",
+	"asdfghjkl",
+	"zxcvbnm",
+	"abcdefghijklmnopqrstuvwxyz",
+}
+
+// PreScreen holds cheap local checks that reject obviously terrible
+// passwords before spending an API call on them. A zero field disables its
+// check.
+type PreScreen struct {
+	// MinLength is the fewest characters a password may have.
+	MinLength int
+	// MaxRepeat is the most times a character may repeat consecutively.
+	MaxRepeat int
+	// MinWalk is the length from which a run along a keyboard row or
+	// the alphabet ("qwerty", "54321", "abcdef") is rejected.
+	MinWalk int
+}
+
+// DefaultPreScreen follows NIST SP 800-63B on length, and only rejects
+// repeats and walks long enough that they can't be an accident.
+var DefaultPreScreen = PreScreen{MinLength: 8, MaxRepeat: 4, MinWalk: 6}
+
+// WithPreScreen has FindPassword apply p before going to the network,
+// returning an error wrapping ErrWeakPassword for any password it rejects.
+func WithPreScreen(p PreScreen) func(f *Finder) {
+	return func(f *Finder) {
+		f.prescreen = &p
+	}
+}
+
+// Check returns an error wrapping ErrWeakPassword if pwd fails any of the
+// checks. The error says which check failed, but holds no part of pwd, so
+// it is safe to log.
+func (p PreScreen) Check(pwd string) error {
+	if p.MinLength > 0 && utf8.RuneCountInString(pwd) < p.MinLength {
+		return fmt.Errorf("%w: shorter than %d characters", ErrWeakPassword, p.MinLength)
+	}
+	if p.MaxRepeat > 0 {
+		var prev rune
+		run := 0
+		for _, r := range pwd {
+			if r == prev {
+				run++
+			} else {
+				prev, run = r, 1
+			}
+			if run > p.MaxRepeat {
+				return fmt.Errorf("%w: a character repeats more than %d times", ErrWeakPassword, p.MaxRepeat)
+			}
+		}
+	}
+	if p.MinWalk > 0 {
+		if hasWalk(strings.ToLower(pwd), p.MinWalk) {
+			return fmt.Errorf("%w: contains a keyboard or alphabet sequence of %d characters", ErrWeakPassword, p.MinWalk)
+		}
+	}
+	return nil
+}
+
+// hasWalk reports whether s has a substring, n bytes long, that is part of
+// a keyboard walk.
+func hasWalk(s string, n int) bool {
+	for i := 0; i+n <= len(s); i++ {
+		sub := s[i : i+n]
+		for _, w := range keyboardWalks {
+			if strings.Contains(w, sub) || strings.Contains(w, reverse(sub)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func reverse(s string) string {
+	b := []byte(s)
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+	return string(b)
+}
@@ -0,0 +1,96 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPreScreen(t *testing.T) {
+	testCases := []struct {
+		pwd  string
+		weak bool
+	}{
+		{"short", true},
+		{"correct horse battery staple", false},
+		{"aaaaa-bbbbb", true},
+		{"aaaa-bbbb", false},
+		{"Qwert!!x", false},
+		{"Qwerty!!", true},
+		{"xQWERTYx", true},
+		{"9876543210", true},
+		{"ghijklmn", true},
+		{"lkjhgfdsa", true},
+		{"pässwörd-ü", false},
+		{"üü", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.pwd, func(t *testing.T) {
+			err := DefaultPreScreen.Check(tc.pwd)
+			if tc.weak != errors.Is(err, ErrWeakPassword) {
+				t.Errorf("expected weak %t: %v\n", tc.weak, err)
+			}
+		})
+	}
+
+	// The reason mustn't give away any of the password.
+	exp := "hibp: password fails pre-screen: contains a keyboard or alphabet sequence of 6 characters"
+	if err := DefaultPreScreen.Check("xQWERTYx"); err == nil || err.Error() != exp {
+		t.Errorf("expected %q: %v\n", exp, err)
+	}
+
+	if err := (PreScreen{}).Check(""); err != nil {
+		t.Errorf("expected zero PreScreen to accept anything: %v\n", err)
+	}
+}
+
+func TestFindPasswordPreScreen(t *testing.T) {
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte(data))
+	}))
+	defer ts.Close()
+
+	f := NewFinder(
+		WithClient(ts.Client()),
+		WithURLTemplate(fmt.Sprintf("%s/%%s", ts.URL)),
+		WithPreScreen(DefaultPreScreen),
+	)
+
+	n, err := f.FindPassword("melobie")
+	if n != 0 || Classify(err) != CodeWeakPassword {
+		t.Errorf("expected [0, %q]: %d, %v\n", CodeWeakPassword, n, err)
+	}
+	if requests != 0 {
+		t.Errorf("expected no requests: %d\n", requests)
+	}
+
+	n, err = f.FindPassword("lauragpe")
+	if n != 229 || err != nil {
+		t.Errorf("expected [229, nil]: %d, %v\n", n, err)
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// probePrefix is the range requested by Probe; it holds selfTestPassword.
+const probePrefix = "5BAA6"
+
+// Capabilities are the optional features a range backend was found to
+// support by Probe.
+type Capabilities struct {
+	// Padding means the backend pads responses when asked to.
+	Padding bool
+	// NTLM means the backend serves ranges of NTLM hashes.
+	NTLM bool
+	// Gzip means the backend compresses responses when asked to.
+	Gzip bool
+	// ETags means the backend tags responses for conditional requests.
+	ETags bool
+}
+
+// Options returns the options that make a Finder use the capabilities that
+// it has an option for, to be passed to NewFinder after those the probing
+// Finder was made with. That is only padding. The others need no option,
+// or aren't for Probe to choose:
+//   - NTLM is a different corpus, not a better way to query the same one;
+//     WithHashMode(NTLM) is for callers that have NTLM hashes to check.
+//   - Gzip is used already: the transport asks for compressed responses,
+//     and decompresses them, unless the client is set up otherwise.
+//   - ETags are used by BucketWatcher wherever the backend sends them.
+func (c Capabilities) Options() []func(*Finder) {
+	return []func(*Finder){WithPadding(c.Padding)}
+}
+
+// Probe discovers which optional features the Finder's backend supports, so
+// that a Finder pointed at a mirror can be configured to match it rather
+// than by hand:
+//
+//	f := hibp.NewFinder(hibp.WithURLTemplate(mirror))
+//	caps, err := f.Probe(ctx)
+//	...
+//	f = hibp.NewFinder(append([]func(*hibp.Finder){
+//		hibp.WithURLTemplate(mirror),
+//	}, caps.Options()...)...)
+//
+// It makes a handful of ordinary range requests and inspects the
+// responses. An error means the backend couldn't be probed at all; a
+// feature whose request fails is reported as unsupported.
+func (f *Finder) Probe(ctx context.Context) (Capabilities, error) {
+	var caps Capabilities
+
+	resp, body, err := f.request(ctx, SHA1, probePrefix, http.Header{"Accept-Encoding": {"gzip"}})
+	if err != nil {
+		return caps, fmt.Errorf("hibp: probe: %w", err)
+	}
+	caps.ETags = resp.Header.Get("ETag") != ""
+	caps.Gzip = resp.Header.Get("Content-Encoding") == "gzip"
+
+	if _, body, err = f.request(ctx, SHA1, probePrefix, http.Header{"Add-Padding": {"true"}}); err == nil {
+		caps.Padding = hasLine(body, isPadding)
+	}
+	if _, body, err = f.request(ctx, NTLM, probePrefix, nil); err == nil {
+		caps.NTLM = hasLine(body, func(line []byte) bool {
+			i := bytes.Index(line, delim)
+			return i+prefixSize == 2*ntlmSize && isHex(line[:i])
+		})
+	}
+	return caps, nil
+}
+
+// request fetches the range for prefix with the given extra headers, as
+// get does, and returns the response along with its (decompressed) body.
+// Unlike fetchPrefix, it makes a single request, leaves checking the
+// content to the caller, and returns a 304 response to a conditional
+// request, with a nil body, rather than an error.
+func (f *Finder) request(ctx context.Context, mode HashMode, prefix string, header http.Header) (*http.Response, []byte, error) {
+	var resp *http.Response
+	var body []byte
+	err := f.get(ctx, mode, []byte(prefix), header, func(r *http.Response) error {
+		if r.StatusCode == http.StatusNotModified {
+			resp = r
+			return nil
+		}
+		b, err := f.readBody(r)
+		if err != nil {
+			return err
+		}
+		resp, body = r, b
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return resp, body, nil
+}
+
+// hasLine reports whether any line of body satisfies fn.
+func hasLine(body []byte, fn func(line []byte) bool) bool {
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		if fn(bytes.TrimSpace(scanner.Bytes())) {
+			return true
+		}
+	}
+	return false
+}
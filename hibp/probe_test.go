@@ -0,0 +1,193 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newMirror serves ranges with whichever of the optional features are
+// switched on in caps.
+func newMirror(caps Capabilities) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := data
+		if r.URL.Query().Get("mode") == "ntlm" {
+			if !caps.NTLM {
+				http.Error(w, "no such mode", http.StatusBadRequest)
+				return
+			}
+			body = ntlmData
+		} else if caps.Padding && r.Header.Get("Add-Padding") == "true" {
+			body = paddedData
+		}
+		if caps.ETags {
+			w.Header().Set("ETag", `"abc"`)
+		}
+		if caps.Gzip && r.Header.Get("Accept-Encoding") == "gzip" {
+			w.Header().Set("Content-Encoding", "gzip")
+			zw := gzip.NewWriter(w)
+			defer zw.Close()
+			fmt.Fprint(zw, body)
+			return
+		}
+		fmt.Fprint(w, body)
+	}))
+}
+
+func TestProbe(t *testing.T) {
+	testCases := []struct {
+		name string
+		caps Capabilities
+	}{
+		{
+			"bare",
+			Capabilities{},
+		},
+		{
+			"everything",
+			Capabilities{Padding: true, NTLM: true, Gzip: true, ETags: true},
+		},
+		{
+			"padding and gzip",
+			Capabilities{Padding: true, Gzip: true},
+		},
+		{
+			"ntlm and etags",
+			Capabilities{NTLM: true, ETags: true},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ts := newMirror(tc.caps)
+			defer ts.Close()
+
+			options := []func(*Finder){
+				WithClient(ts.Client()),
+				WithURLTemplate(fmt.Sprintf("%s/%%s", ts.URL)),
+			}
+			caps, err := NewFinder(options...).Probe(context.Background())
+			if err != nil {
+				t.Fatalf("unexpected: %v\n", err)
+			}
+			if caps != tc.caps {
+				t.Errorf("expected %+v: %+v\n", tc.caps, caps)
+			}
+
+			f := NewFinder(append(options, caps.Options()...)...)
+			if f.padding != tc.caps.Padding {
+				t.Errorf("expected padding %t: %t\n", tc.caps.Padding, f.padding)
+			}
+		})
+	}
+}
+
+func TestProbeDown(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	f := NewFinder(
+		WithClient(ts.Client()),
+		WithURLTemplate(fmt.Sprintf("%s/%%s", ts.URL)),
+	)
+	_, err := f.Probe(context.Background())
+	if Classify(err) != CodeBackendDown {
+		t.Errorf("expected %v: %v\n", CodeBackendDown, err)
+	}
+}
+
+func TestProbeRequests(t *testing.T) {
+	ts := newMirror(Capabilities{Padding: true, NTLM: true, Gzip: true, ETags: true})
+	defer ts.Close()
+
+	f := NewFinder(
+		WithClient(ts.Client()),
+		WithURLTemplate(fmt.Sprintf("%s/%%s", ts.URL)),
+	)
+	if _, err := f.Probe(context.Background()); err != nil {
+		t.Fatalf("unexpected: %v\n", err)
+	}
+	if got := f.requestCount(); got != 3 {
+		t.Errorf("expected 3 requests counted: %d\n", got)
+	}
+}
+
+func TestProbeErrors(t *testing.T) {
+	testCases := []struct {
+		name    string
+		handler http.HandlerFunc
+		options []func(*Finder)
+		exp     error
+	}{
+		{
+			"redirect",
+			func(w http.ResponseWriter, r *http.Request) {
+				http.Redirect(w, r, "/login", http.StatusFound)
+			},
+			[]func(*Finder){WithNoRedirects()},
+			ErrUpstream,
+		},
+		{
+			"too large",
+			func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, strings.Repeat(data, 10))
+			},
+			[]func(*Finder){WithMaxBodySize(int64(len(data)))},
+			ErrBodyTooLarge,
+		},
+		{
+			"too large compressed",
+			func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Encoding", "gzip")
+				zw := gzip.NewWriter(w)
+				defer zw.Close()
+				fmt.Fprint(zw, strings.Repeat(data, 10))
+			},
+			[]func(*Finder){WithMaxBodySize(int64(len(data)))},
+			ErrBodyTooLarge,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ts := httptest.NewServer(tc.handler)
+			defer ts.Close()
+
+			f := NewFinder(append([]func(*Finder){
+				WithClient(ts.Client()),
+				WithURLTemplate(fmt.Sprintf("%s/%%s", ts.URL)),
+			}, tc.options...)...)
+			_, err := f.Probe(context.Background())
+			if !errors.Is(err, tc.exp) {
+				t.Errorf("expected %v: %v\n", tc.exp, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrQueueClosed is returned when adding to a Queue that has stopped.
+var ErrQueueClosed = errors.New("hibp: queue closed")
+
+// Queue runs lookups asynchronously on a pool of workers, so callers can
+// take password checks off their own latency path. Work is held in a
+// bounded buffer; adding to a full Queue waits for room.
+type Queue struct {
+	finder  *Finder
+	workers int
+	grace   time.Duration
+	jobs    chan *Ticket
+
+	done   chan struct{}
+	stop   sync.Once
+	mu     sync.RWMutex // held for writing to close
+	closed bool
+}
+
+// Ticket tracks a lookup added to a Queue.
+type Ticket struct {
+	sum  []byte
+	fn   func(Result)
+	done chan struct{}
+	res  Result
+}
+
+// NewQueue returns a Queue that looks hashes up with f using the given
+// number of workers, buffering up to size pending lookups. Nothing is
+// looked up until Run is called.
+func NewQueue(f *Finder, workers, size int, options ...func(*Queue)) *Queue {
+	if workers < 1 {
+		workers = 1
+	}
+	q := &Queue{
+		finder:  f,
+		workers: workers,
+		jobs:    make(chan *Ticket, size),
+		done:    make(chan struct{}),
+	}
+	for _, opt := range options {
+		opt(q)
+	}
+	return q
+}
+
+// Run processes lookups until ctx is done, then returns nil once all the
+// workers have stopped. Lookups are bound to ctx, unless WithQueueDrain
+// says otherwise. Lookups still pending at that point complete with ctx's
+// error, and further additions fail with ErrQueueClosed. Run must only be
+// called once.
+func (q *Queue) Run(ctx context.Context) error {
+	work, cancel := ctx, context.CancelFunc(func() {})
+	if q.grace > 0 {
+		work, cancel = drainContext(ctx, q.grace, q.finder.clock)
+		// Workers busy with a lookup don't notice ctx is done right away.
+		go func() {
+			<-ctx.Done()
+			q.stopIntake()
+		}()
+	}
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for i := 0; i < q.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					if q.grace > 0 {
+						q.drain(work)
+					}
+					return
+				case t := <-q.jobs:
+					t.finish(q.finder.result(work, fmt.Sprintf("%X", t.sum)))
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	q.stopIntake()
+	for {
+		select {
+		case t := <-q.jobs:
+			t.finish(Result{
+				Input: fmt.Sprintf("%X", t.sum),
+				Hash:  t.sum,
+				Err:   ctx.Err(),
+				Code:  Classify(ctx.Err()),
+			})
+		default:
+			return nil
+		}
+	}
+}
+
+// stopIntake has further additions fail. It wakes up anyone waiting for
+// room, then waits for them to leave, so that nothing makes it in after it
+// returns.
+func (q *Queue) stopIntake() {
+	q.stop.Do(func() {
+		close(q.done)
+		q.mu.Lock()
+		q.closed = true
+		q.mu.Unlock()
+	})
+}
+
+// Enqueue adds a lookup of the given hash to the Queue, waiting for room
+// if need be, and returns a Ticket to Await its Result with. The ctx only
+// bounds the wait to be added.
+func (q *Queue) Enqueue(ctx context.Context, sum []byte) (*Ticket, error) {
+	return q.add(ctx, sum, nil)
+}
+
+// EnqueueFunc is like Enqueue, but has fn called with the Result instead.
+// It is called on one of the Queue's workers, so it should not block for
+// long.
+func (q *Queue) EnqueueFunc(ctx context.Context, sum []byte, fn func(Result)) error {
+	_, err := q.add(ctx, sum, fn)
+	return err
+}
+
+// Await waits for the Result of t, or until ctx is done.
+func (q *Queue) Await(ctx context.Context, t *Ticket) (Result, error) {
+	select {
+	case <-t.done:
+		return t.res, nil
+	case <-ctx.Done():
+		return Result{}, ctx.Err()
+	}
+}
+
+func (q *Queue) add(ctx context.Context, sum []byte, fn func(Result)) (*Ticket, error) {
+	t := &Ticket{
+		sum:  append([]byte(nil), sum...),
+		fn:   fn,
+		done: make(chan struct{}),
+	}
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if q.closed {
+		return nil, ErrQueueClosed
+	}
+	select {
+	case q.jobs <- t:
+		return t, nil
+	case <-q.done:
+		return nil, ErrQueueClosed
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (t *Ticket) finish(res Result) {
+	t.res = res
+	close(t.done)
+	if t.fn != nil {
+		t.fn(res)
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"context"
+	"crypto/sha1"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestQueue(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(data))
+	}))
+	defer ts.Close()
+
+	f := NewFinder(
+		WithClient(ts.Client()),
+		WithURLTemplate(fmt.Sprintf("%s/%%s", ts.URL)),
+	)
+	q := NewQueue(f, 4, 2)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan error)
+	go func() {
+		stopped <- q.Run(ctx)
+	}()
+
+	melobie := sha1.Sum([]byte("melobie"))
+	tk, err := q.Enqueue(context.Background(), melobie[:])
+	if err != nil {
+		t.Fatalf("unexpected: %v\n", err)
+	}
+
+	var mu sync.Mutex
+	var counts []int64
+	var wg sync.WaitGroup
+	for _, pwd := range []string{"lauragpe", "gonna-miss", "lauragpe"} {
+		h := sha1.Sum([]byte(pwd))
+		wg.Add(1)
+		err := q.EnqueueFunc(context.Background(), h[:], func(res Result) {
+			defer wg.Done()
+			mu.Lock()
+			counts = append(counts, res.Count)
+			mu.Unlock()
+		})
+		if err != nil {
+			t.Fatalf("unexpected: %v\n", err)
+		}
+	}
+
+	res, err := q.Await(context.Background(), tk)
+	if err != nil || res.Count != 401 || res.Err != nil {
+		t.Errorf("expected [401, nil]: %+v, %v\n", res, err)
+	}
+	wg.Wait()
+	if sum := counts[0] + counts[1] + counts[2]; sum != 458 || len(counts) != 3 {
+		t.Errorf("unexpected: %v\n", counts)
+	}
+
+	cancel()
+	select {
+	case err := <-stopped:
+		if err != nil {
+			t.Errorf("unexpected: %v\n", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Run did not stop")
+	}
+	if _, err := q.Enqueue(context.Background(), melobie[:]); err != ErrQueueClosed {
+		t.Errorf("expected %v: %v\n", ErrQueueClosed, err)
+	}
+}
+
+func TestQueueStopsPending(t *testing.T) {
+	q := NewQueue(NewFinder(), 1, 2)
+	h := sha1.Sum([]byte("melobie"))
+
+	// Nothing is processed before Run, so the buffer fills up.
+	pending, err := q.Enqueue(context.Background(), h[:])
+	if err != nil {
+		t.Fatalf("unexpected: %v\n", err)
+	}
+	if _, err = q.Enqueue(context.Background(), h[:]); err != nil {
+		t.Fatalf("unexpected: %v\n", err)
+	}
+	full, cancelFull := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancelFull()
+	if _, err = q.Enqueue(full, h[:]); err != context.DeadlineExceeded {
+		t.Errorf("expected %v: %v\n", context.DeadlineExceeded, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := q.Run(ctx); err != nil {
+		t.Errorf("unexpected: %v\n", err)
+	}
+
+	res, err := q.Await(context.Background(), pending)
+	if err != nil || res.Code != CodeCanceled {
+		t.Errorf("expected %q: %+v, %v\n", CodeCanceled, res, err)
+	}
+}
+
+func TestQueueAwaitCanceled(t *testing.T) {
+	q := NewQueue(NewFinder(), 1, 1)
+	h := sha1.Sum([]byte("melobie"))
+	tk, err := q.Enqueue(context.Background(), h[:])
+	if err != nil {
+		t.Fatalf("unexpected: %v\n", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := q.Await(ctx, tk); err != context.Canceled {
+		t.Errorf("expected %v: %v\n", context.Canceled, err)
+	}
+}
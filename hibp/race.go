@@ -0,0 +1,79 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// errNoFinders is returned by Race when it has nothing to race.
+var errNoFinders = errors.New("hibp: no finders to race")
+
+// Race looks up a hex encoded hash (see NormalizeSHA1 for what is
+// accepted) with all of finders at once, typically one pointed at a local
+// mirror and one at the upstream API, and returns the Result of whichever
+// answers successfully first. Its Backend records which one that was. The
+// lookups still in flight are canceled.
+//
+// A budget above zero bounds the whole race. If no finder succeeds within
+// it, the Result of the first of finders is returned, carrying its error.
+//
+// Only successful answers win, so finders should leave failures to be
+// reported (DenyOnError, the default); a Finder with AllowOnError answers
+// every lookup "successfully", and would win races it shouldn't.
+func Race(ctx context.Context, budget time.Duration, input string, finders ...*Finder) Result {
+	if len(finders) == 0 {
+		return Result{Input: input, Err: errNoFinders, Code: Classify(errNoFinders)}
+	}
+	if budget > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, budget)
+		defer cancel()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type answer struct {
+		i   int
+		res Result
+	}
+	// Buffered, so the losers don't block once the winner is returned.
+	answers := make(chan answer, len(finders))
+	for i, f := range finders {
+		go func(i int, f *Finder) {
+			answers <- answer{i, f.result(ctx, input)}
+		}(i, f)
+	}
+
+	var first Result
+	for range finders {
+		a := <-answers
+		if a.res.Err == nil {
+			return a.res
+		}
+		if a.i == 0 {
+			first = a.res
+		}
+	}
+	return first
+}
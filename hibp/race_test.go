@@ -0,0 +1,112 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestRace(t *testing.T) {
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(data))
+	}))
+	defer fast.Close()
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(5 * time.Second):
+		case <-r.Context().Done():
+		}
+		w.Write([]byte(data))
+	}))
+	defer slow.Close()
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+
+	finder := func(ts *httptest.Server) *Finder {
+		return NewFinder(
+			WithClient(ts.Client()),
+			WithURLTemplate(fmt.Sprintf("%s/%%s", ts.URL)),
+		)
+	}
+	host := func(ts *httptest.Server) string {
+		u, _ := url.Parse(ts.URL)
+		return u.Host
+	}
+
+	testCases := []struct {
+		name      string
+		finders   []*Finder
+		exBackend string
+		exCode    ErrorCode
+	}{
+		{
+			"fast wins",
+			[]*Finder{finder(slow), finder(fast)},
+			host(fast),
+			"",
+		},
+		{
+			"failure loses",
+			[]*Finder{finder(down), finder(fast)},
+			host(fast),
+			"",
+		},
+		{
+			"out of budget",
+			[]*Finder{finder(slow), finder(down)},
+			host(slow),
+			CodeCanceled,
+		},
+		{
+			"none",
+			nil,
+			"",
+			CodeUnknown,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			start := time.Now()
+			res := Race(context.Background(), 100*time.Millisecond, "21BD1012A7CA357541F0AC487871FEEC1891C49C", tc.finders...)
+			if res.Backend != tc.exBackend {
+				t.Errorf("expected %s: %s\n", tc.exBackend, res.Backend)
+			}
+			if res.Code != tc.exCode {
+				t.Errorf("expected %q: %q (%v)\n", tc.exCode, res.Code, res.Err)
+			}
+			if tc.exCode == "" && res.Count != 401 {
+				t.Errorf("expected 401: %d\n", res.Count)
+			}
+			if d := time.Since(start); d > time.Second {
+				t.Errorf("expected the race to end within budget: %v\n", d)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+	"time"
+)
+
+// WithRandom replaces crypto/rand.Reader as the source of randomness for
+// anything the Finder randomizes, such as backoff jitter. A fixed source
+// makes behavior reproducible in tests; environments that mandate a
+// particular CSPRNG can supply it here.
+func WithRandom(r io.Reader) func(f *Finder) {
+	return func(f *Finder) {
+		f.random = r
+	}
+}
+
+// jitter returns a random duration in [0, d). If the random source fails,
+// it returns d/2 rather than an error, as callers only use it to spread
+// load.
+func (f *Finder) jitter(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+	var b [8]byte
+	f.randMu.Lock()
+	r := f.random
+	if r == nil {
+		r = rand.Reader
+	}
+	_, err := io.ReadFull(r, b[:])
+	f.randMu.Unlock()
+	if err != nil {
+		return d / 2
+	}
+	return time.Duration(binary.BigEndian.Uint64(b[:]) % uint64(d))
+}
@@ -0,0 +1,77 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestJitter(t *testing.T) {
+	seed := []byte{
+		0, 0, 0, 0, 0, 0, 0, 42,
+		0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+	}
+
+	testCases := []struct {
+		name string
+		d    time.Duration
+		exp  time.Duration
+	}{
+		{
+			"small",
+			time.Second,
+			42,
+		},
+		{
+			"wraps",
+			time.Second,
+			time.Duration(uint64(1<<64-1) % uint64(time.Second)),
+		},
+		{
+			"exhausted",
+			time.Second,
+			time.Second / 2,
+		},
+		{
+			"zero",
+			0,
+			0,
+		},
+	}
+
+	f := NewFinder(WithRandom(bytes.NewReader(seed)))
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := f.jitter(tc.d); got != tc.exp {
+				t.Errorf("expected %v: %v\n", tc.exp, got)
+			}
+		})
+	}
+
+	f = NewFinder()
+	for i := 0; i < 100; i++ {
+		if got := f.jitter(time.Millisecond); got < 0 || got >= time.Millisecond {
+			t.Fatalf("out of range: %v\n", got)
+		}
+	}
+}
@@ -29,12 +29,18 @@ package hibp
 import (
 	"bufio"
 	"bytes"
-	"crypto/sha1"
+	"compress/gzip"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 const prefixSize = 5
@@ -44,34 +50,60 @@ var delim = []byte(":")
 const errMsgFormat = "hibp: problem parsing results"
 
 // DefaultTemplate creates URLs pointing to the original Pwned Passwords API
-const DefaultTemplate = "https://api.pwnedpasswords.com/range/%s"
+const DefaultTemplate = DefaultBaseURL + "/range/%s"
 
 // NewFinder returns a new Finder, set up with the options provided.
 func NewFinder(options ...func(*Finder)) *Finder {
 	f := &Finder{
-		tmpl: DefaultTemplate,
-		conn: http.DefaultClient,
+		tmpl:  DefaultTemplate,
+		clock: systemClock{},
 	}
 	for _, opt := range options {
 		opt(f)
 	}
+	if f.serverless {
+		f.serverlessDefaults()
+	}
+	f.cacheSecretDefault()
+	f.indexNameDefault()
+	if f.conn == nil {
+		f.conn = defaultClient
+	}
+	f.conn = withTimeout(f.conn, f.timeout)
+	if f.dial != nil {
+		f.dial.clock = f.clock
+		f.conn = f.dial.wrap(f.conn)
+		if u, err := f.rangeURL(SHA1, nil); err == nil {
+			f.dial.warm(u)
+		}
+	}
+	if f.limit != nil {
+		f.limit.clock = f.clock
+	}
+	if f.breaker != nil {
+		f.breaker.clock = f.clock
+	}
 	return f
 }
 
 // WithURLTemplate replaces the DefaultTemplate to build the URL to fetch.
 //
-// This is useful to retrieve from a different hosted solution.
+// This is useful to retrieve from a different hosted solution. The prefix
+// is substituted for the one %s with fmt.Sprintf, so any other % in the
+// template must be doubled; WithBaseURL is safer for services laid out
+// like the API.
 //
 // (It is possible to download and self-host the data, see the "Downloading
 // the Data" section at
 // https://www.troyhunt.com/ive-just-launched-pwned-passwords-version-2/)
 func WithURLTemplate(template string) func(f *Finder) {
 	return func(f *Finder) {
-		f.tmpl = template
+		f.tmpl, f.base, f.baseErr = template, nil, nil
 	}
 }
 
-// WithClient replaces the http.DefaultClient
+// WithClient replaces the default client, which has a timeout of
+// DefaultTimeout.
 func WithClient(client *http.Client) func(f *Finder) {
 	return func(f *Finder) {
 		f.conn = client
@@ -79,15 +111,67 @@ func WithClient(client *http.Client) func(f *Finder) {
 }
 
 // Finder looks for reported password breaches.
+//
+// A Finder is safe for concurrent use by multiple goroutines. Its
+// configuration is fixed once NewFinder returns, and the state shared
+// between lookups is guarded internally. Anything handed to an option that
+// is called during lookups (a Clock, an APIKeyFunc, the reader given to
+// WithRandom, the kill switch) is used concurrently, and must be safe for
+// that, apart from the reader, whose use is serialized.
 type Finder struct {
-	tmpl string
-	conn *http.Client
+	// Configuration; read-only after NewFinder.
+	tmpl        string
+	base        *url.URL
+	baseErr     error
+	conn        *http.Client
+	timeout     time.Duration
+	clock       Clock
+	mode        HashMode
+	noDetect    bool
+	padding     bool
+	bisect      bool
+	serverless  bool
+	noRedirects bool
+	legacyErrs  bool
+	apiKey      APIKeyFunc
+	userAgent   string
+	degraded    DegradedMode
+	threshold   int64
+	maxLine     int
+	maxBody     int64
+	block       *Blocklist
+	shortlist   *Shortlist
+	prescreen   *PreScreen
+	attempts    int
+	maxWait     time.Duration
+	cacheSecret []byte
+	indexName   string
+	retry       *RetryPolicy
+	source      Source
+
+	killed       func() bool
+	killFallback DegradedMode
+
+	// Shared between lookups; each guards its own state.
+	dial    *dialer
+	results *ResultCache
+	cache   Cache
+	index   *RangeIndex
+	limit   *limiter
+	breaker *breaker
+	flights flight
+
+	requests uint64 // range requests made; updated atomically
+
+	randMu sync.Mutex // guards random
+	random io.Reader
 }
 
 // Find takes the 20 byte output of a sha1.Sum(), and retrieves the count
 // of time that the source string has been found in breaches. A zero (without
 // an error) means there's no evidence that the given string has had a
-// previous breach.
+// previous breach. (In NTLM mode, see WithHashMode, it takes a 16 byte NTLM
+// hash instead.)
 //
 // (Some passwords have been breached THOUSANDS of times, most of the entries
 // have only been seen a handful of times. It is up to the consumer to decide
@@ -101,56 +185,316 @@ type Finder struct {
 // k-Anonymity" at
 // https://www.troyhunt.com/ive-just-launched-pwned-passwords-version-2/)
 func (f *Finder) Find(sum []byte) (int64, error) {
-	if len(sum) < sha1.Size {
-		return 0, io.ErrShortBuffer
+	return f.FindContext(context.Background(), sum)
+}
+
+// FindPassword is a convenience for calling Find with the sha1.Sum() of
+// pwd (or its NTLM hash, see WithHashMode). Only the hash is used past this
+// point; the plaintext isn't kept or sent anywhere.
+func (f *Finder) FindPassword(pwd string) (int64, error) {
+	if f.prescreen != nil {
+		if err := f.prescreen.Check(pwd); err != nil {
+			return 0, err
+		}
+	}
+	return f.Find(f.hashPassword(pwd))
+}
+
+// FindHex is like Find, but takes the SHA1 as a hex encoded string, in
+// either case. An input that isn't 40 hex digits (32 for NTLM) returns an
+// error wrapping ErrInvalidHex, without making a request. (See
+// NormalizeSHA1.)
+func (f *Finder) FindHex(hexDigest string) (int64, error) {
+	sum, err := f.normalize(hexDigest)
+	if err != nil {
+		return 0, err
 	}
-	if len(sum) > sha1.Size {
-		return 0, io.ErrShortWrite
+	return f.Find(sum)
+}
+
+// FindContext is like Find, but the request upstream is bound to ctx, so
+// that cancelation and deadlines of the caller are respected.
+func (f *Finder) FindContext(ctx context.Context, sum []byte) (int64, error) {
+	n, _, err := f.find(ctx, f.mode, sum)
+	return n, err
+}
+
+// find looks up sum, a hash of the given mode. It also reports whether the
+// answer came without a request to the backend.
+func (f *Finder) find(ctx context.Context, mode HashMode, sum []byte) (int64, bool, error) {
+	n, hit, err := f.lookup(ctx, mode, sum)
+	if _, ok := err.(*unverifiedError); ok {
+		return 0, false, nil
 	}
+	return n, hit, err
+}
+
+// lookup is find, except that a failure the configured fallback answers
+// for with a zero count is returned as an *unverifiedError.
+func (f *Finder) lookup(ctx context.Context, mode HashMode, sum []byte) (int64, bool, error) {
+	if len(sum) != mode.size() {
+		err := &DigestLengthError{Got: len(sum), Want: mode.size()}
+		if f.legacyErrs {
+			return 0, false, err.legacy()
+		}
+		return 0, false, err
+	}
+	// Local checks first: they need no backend, so the kill switch has no
+	// reason to skip them.
+	if f.block != nil && f.block.Contains(sum) {
+		return 0, false, ErrBlocklisted
+	}
+	if f.shortlist != nil {
+		if n, ok := f.shortlist.Lookup(sum); ok {
+			return n, false, nil
+		}
+	}
+	if off, err := f.disabled(); off {
+		return 0, false, err
+	}
+	if f.index != nil {
+		if n, ok := f.indexCount(mode, sum); ok {
+			return n, true, nil
+		}
+	}
+	if f.results != nil {
+		if n, ok := f.results.get(f.clock.Now(), sum); ok {
+			return n, true, nil
+		}
+	}
+
 	full := []byte(fmt.Sprintf("%X", sum))
-	body, err := f.fetchPrefix(full[:prefixSize])
+	n, hit, bad, err := f.rangeCount(ctx, mode, full[:prefixSize], full[prefixSize:])
 	if err != nil {
-		return 0, err
+		if f.degraded == AllowOnError {
+			return 0, false, &unverifiedError{err}
+		}
+		return 0, false, err
+	}
+	if bad == nil && f.results != nil {
+		f.results.put(f.clock.Now(), sum, n)
 	}
+	return n, hit, bad
+}
 
-	line, err := findSuffix(full[prefixSize:], bytes.NewReader(body))
+// count returns the count for suffix in the range body.
+func (f *Finder) count(suffix, body []byte) (int64, error) {
+	var line []byte
+	var err error
+	if f.bisect {
+		line, err = searchSuffix(suffix, body, f.maxLineLength())
+	} else {
+		line, err = findSuffix(suffix, bytes.NewReader(body), f.maxLineLength())
+	}
 	if err != nil {
 		return 0, err
 	}
-	if len(line) == 0 {
+	if len(line) == 0 || (f.padding && isPadding(line)) {
 		return 0, nil
 	}
 	return parseCount(line)
 }
 
-func (f *Finder) fetchPrefix(prefix []byte) ([]byte, error) {
-	url := fmt.Sprintf(f.tmpl, prefix)
-	resp, err := f.conn.Get(url)
+// fetchPrefix retrieves the range for prefix, retrying failed requests as
+// WithMaxAttempts and WithRetry describe, and minding the circuit breaker.
+func (f *Finder) fetchPrefix(ctx context.Context, mode HashMode, prefix []byte) ([]byte, error) {
+	body, _, err := f.fetchRange(ctx, mode, prefix)
+	return body, err
+}
+
+// fetchRange is fetchPrefix, also returning how long the body may be
+// cached for, as given by maxAge.
+func (f *Finder) fetchRange(ctx context.Context, mode HashMode, prefix []byte) ([]byte, time.Duration, error) {
+	var body []byte
+	var ttl time.Duration
+	err := f.guarded(ctx, func() error {
+		var err error
+		body, ttl, err = f.fetchOnce(ctx, mode, prefix)
+		return err
+	})
+	return body, ttl, err
+}
+
+// guarded calls once, which makes a single request to the backend,
+// retrying it as WithMaxAttempts and WithRetry describe, and minding the
+// circuit breaker.
+func (f *Finder) guarded(ctx context.Context, once func() error) error {
+	if f.breaker == nil {
+		return f.retrying(ctx, once)
+	}
+	token, err := f.breaker.allow()
 	if err != nil {
-		return nil, err
+		return err
+	}
+	err = f.retrying(ctx, once)
+	f.breaker.record(token, err)
+	return err
+}
+
+func (f *Finder) retrying(ctx context.Context, once func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := once()
+		var he *HTTPError
+		if errors.As(err, &he) {
+			he.Attempts = attempt
+		}
+		if err == nil || attempt >= f.maxAttempts() {
+			return err
+		}
+		wait, ok := f.retryWait(ctx, attempt, err)
+		if !ok {
+			return err
+		}
+		select {
+		case <-f.clock.After(wait):
+		case <-ctx.Done():
+			return err
+		}
+	}
+}
+
+func (f *Finder) fetchOnce(ctx context.Context, mode HashMode, prefix []byte) ([]byte, time.Duration, error) {
+	if f.source != nil {
+		atomic.AddUint64(&f.requests, 1)
+		body, err := f.source.Range(ctx, mode, string(prefix))
+		return body, 0, err
+	}
+	var body []byte
+	var ttl time.Duration
+	err := f.get(ctx, mode, prefix, nil, func(resp *http.Response) error {
+		b, err := f.readBody(resp)
+		if err != nil {
+			return err
+		}
+		if err := checkContent(resp.Header.Get("Content-Type"), b); err != nil {
+			return err
+		}
+		body, ttl = b, maxAge(resp.Header)
+		return nil
+	})
+	return body, ttl, err
+}
+
+// get requests the range for prefix from the backend, with any extra
+// header given, and has read consume the body of the response if it is
+// successful. A 304 response to a request with If-None-Match counts as
+// successful too.
+func (f *Finder) get(ctx context.Context, mode HashMode, prefix []byte, header http.Header, read func(*http.Response) error) error {
+	u, err := f.rangeURL(mode, prefix)
+	if err != nil {
+		return err
+	}
+	if f.limit != nil {
+		if err := f.limit.wait(ctx); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	setUserAgent(ctx, req, f.userAgent)
+	if err := setAPIKey(ctx, req, f.apiKey); err != nil {
+		return err
+	}
+	if f.padding {
+		req.Header.Set("Add-Padding", "true")
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	atomic.AddUint64(&f.requests, 1)
+	resp, err := f.client().Do(req)
+	if err != nil {
+		return err
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotModified && req.Header.Get("If-None-Match") != "" {
+		return read(resp)
+	}
+	if resp.StatusCode >= 300 && resp.StatusCode < 400 {
+		return &RedirectError{
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+			Location:   resp.Header.Get("Location"),
+		}
+	}
 	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf(resp.Status)
+		return &HTTPError{
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+			Prefix:     string(prefix),
+			Attempts:   1,
+			retryAfter: resp.Header.Get("Retry-After"),
+		}
 	}
-	return ioutil.ReadAll(resp.Body)
+	return read(resp)
 }
 
-func findSuffix(suffix []byte, content io.Reader) ([]byte, error) {
+// readBody reads the body of resp in full, failing with ErrBodyTooLarge
+// past maxBodySize. A body that was asked for compressed, and so wasn't
+// decompressed by the transport, is decompressed, and its limit applies
+// to the decompressed size.
+func (f *Finder) readBody(resp *http.Response) ([]byte, error) {
+	max := f.maxBodySize()
+	var r io.Reader = resp.Body
+	if resp.Header.Get("Content-Encoding") == "gzip" {
+		zr, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		r = zr
+	}
+	b, err := ioutil.ReadAll(io.LimitReader(r, max+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(b)) > max {
+		return nil, fmt.Errorf("%w: over %d bytes", ErrBodyTooLarge, max)
+	}
+	return b, nil
+}
+
+func findSuffix(suffix []byte, content io.Reader, maxLine int) ([]byte, error) {
 	scanner := bufio.NewScanner(content)
+	// Leave room for a trailing "\r\n"; the scanner needs to see the end of
+	// a line to return it.
+	scanner.Buffer(make([]byte, 0, 4096), maxLine+2)
 	for scanner.Scan() {
 		b := scanner.Bytes()
+		if len(b) > maxLine {
+			return nil, fmt.Errorf("%w: over %d bytes", ErrLineTooLong, maxLine)
+		}
 		if bytes.HasPrefix(b, suffix) {
 			return b, nil
 		}
 	}
-	return nil, scanner.Err()
+	if err := scanner.Err(); err != nil {
+		if err == bufio.ErrTooLong {
+			return nil, fmt.Errorf("%w: over %d bytes", ErrLineTooLong, maxLine)
+		}
+		return nil, err
+	}
+	return nil, nil
 }
 
 func parseCount(line []byte) (int64, error) {
 	parts := bytes.Split(line, delim)
 	if len(parts) != 2 {
-		return 0, fmt.Errorf("%s: %s", errMsgFormat, line)
+		return 0, fmt.Errorf("%w: %q", ErrMalformedLine, line)
+	}
+	digits := parts[1]
+	if len(digits) == 0 {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidCount, digits)
+	}
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return 0, fmt.Errorf("%w: %q", ErrInvalidCount, digits)
+		}
+	}
+	n, err := strconv.ParseInt(string(digits), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrInvalidCount, err)
 	}
-	return strconv.ParseInt(string(parts[1]), 10, 64)
+	return n, nil
 }
@@ -23,8 +23,10 @@ package hibp
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"crypto/rand"
 	"crypto/sha1"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -32,11 +34,9 @@ import (
 	"os"
 	"strings"
 	"testing"
+	"time"
 )
 
-const minResultLines = 381
-const maxResultLines = 584
-
 const data = `
 00000000000000000000000000000000000:13
 0018A45C4D1DEF81644B54AB7F969B88D65:229
@@ -88,6 +88,79 @@ func TestFind(t *testing.T) {
 	}
 }
 
+func TestFindPassword(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(data))
+	}))
+	defer ts.Close()
+
+	f := NewFinder(
+		WithClient(ts.Client()),
+		WithURLTemplate(fmt.Sprintf("%s/%%s", ts.URL)),
+	)
+
+	n, err := f.FindPassword("lauragpe")
+	if n != 229 || err != nil {
+		t.Errorf("expected [229, nil]: %d, %v\n", n, err)
+	}
+}
+
+func TestFindHex(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(data))
+	}))
+	defer ts.Close()
+
+	f := NewFinder(
+		WithClient(ts.Client()),
+		WithURLTemplate(fmt.Sprintf("%s/%%s", ts.URL)),
+	)
+
+	testCases := []struct {
+		name string
+		hex  string
+		exp  int64
+		xErr error
+	}{
+		{
+			"upper",
+			"21BD1012A7CA357541F0AC487871FEEC1891C49C",
+			401,
+			nil,
+		},
+		{
+			"lower",
+			"21bd1012a7ca357541f0ac487871feec1891c49c",
+			401,
+			nil,
+		},
+		{
+			"short",
+			"21BD1012A7CA357541F0AC487871FEEC1891C49",
+			0,
+			ErrInvalidHex,
+		},
+		{
+			"charset",
+			"21BD1012A7CA357541F0AC487871FEEC1891C49G",
+			0,
+			ErrInvalidHex,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			n, err := f.FindHex(tc.hex)
+			if !errors.Is(err, tc.xErr) {
+				t.Errorf("expected %v: %v\n", tc.xErr, err)
+			}
+			if n != tc.exp {
+				t.Errorf("expected %d: %d\n", tc.exp, n)
+			}
+		})
+	}
+}
+
 func TestFindErrors(t *testing.T) {
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(429) // Throttled
@@ -132,15 +205,38 @@ func TestFindErrors(t *testing.T) {
 			if !strings.Contains(err.Error(), tc.xErr) {
 				t.Errorf("expected %q: %v\n", tc.xErr, err)
 			}
+			if errors.Is(err, ErrInvalidDigestLength) != (len(tc.buf) != 20) {
+				t.Errorf("expected %v %t: %v\n", ErrInvalidDigestLength, len(tc.buf) != 20, err)
+			}
 			// t.Logf("%v\n", err)
 		})
 	}
 }
 
+func TestFindContext(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer ts.Close()
+
+	f := NewFinder(
+		WithClient(ts.Client()),
+		WithURLTemplate(fmt.Sprintf("%s/%%s", ts.URL)),
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	h := sha1.Sum([]byte("melobie"))
+	n, err := f.FindContext(ctx, h[:])
+	if n != 0 || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected [0, %v]: %d, %v\n", context.DeadlineExceeded, n, err)
+	}
+}
+
 func TestIntegrationFetch(t *testing.T) {
 	// Per (https://haveibeenpwned.com/API/v2#SearchingPwnedPasswordsByRange)
 	// The docs say EVERY valid 5-character hex string will return a 200,
-	// with at least 381 lines returned, and at most 584
+	// with a number of lines within DefaultRangeBounds
 	if testing.Short() {
 		t.Skipf("skip integration test in short mode")
 	}
@@ -150,32 +246,27 @@ func TestIntegrationFetch(t *testing.T) {
 	prefix := []byte(fmt.Sprintf("%5X", b))[:prefixSize]
 
 	f := NewFinder()
-	body, err := f.fetchPrefix(prefix)
+	body, err := f.fetchPrefix(context.Background(), SHA1, prefix)
 	if err != nil {
 		t.Errorf("unexpected: %v\n", err)
 	}
 
-	verbose := os.Getenv("VERBOSE") != ""
-	size := 0
-	buf := bufio.NewReader(bytes.NewReader(body))
-	for {
-		buf, _, err := buf.ReadLine()
-		if err != nil {
-			if err != io.EOF {
-				t.Logf("Error: %v\n", err)
+	if os.Getenv("VERBOSE") != "" {
+		buf := bufio.NewReader(bytes.NewReader(body))
+		for {
+			line, _, err := buf.ReadLine()
+			if err != nil {
+				if err != io.EOF {
+					t.Logf("Error: %v\n", err)
+				}
+				break
 			}
-			break
+			t.Logf("%s\n", line)
 		}
-		size++
-		if verbose {
-			t.Logf("%s\n", buf)
-		}
-	}
-	if size < minResultLines {
-		t.Errorf("expected min %d lines: %d\n", minResultLines, size)
 	}
-	if size > maxResultLines {
-		t.Errorf("expected max %d lines: %d\n", maxResultLines, size)
+	size, err := ValidateRangeBody(body, DefaultRangeBounds)
+	if err != nil {
+		t.Errorf("unexpected: %v\n", err)
 	}
 	t.Logf("Prefix: %s; Size: %d\n", prefix, size)
 }
@@ -280,7 +371,7 @@ func TestFindSuffix(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			r, err := findSuffix([]byte(tc.suffix), tc.r)
+			r, err := findSuffix([]byte(tc.suffix), tc.r, DefaultMaxLineLength)
 			if err != nil {
 				t.Fatalf("unexpected: %v\n", err)
 			}
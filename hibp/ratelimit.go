@@ -0,0 +1,87 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// WithRateLimit keeps range requests under rps per second on average,
+// allowing bursts of up to burst requests, so bulk work stays below the
+// backend's throttling thresholds rather than reacting to 429s. Requests
+// over the limit wait their turn (or until their context is done). The
+// limit applies to the Finder as a whole, across all goroutines using it.
+// A burst below 1 is taken as 1; an rps of zero or less means no limit.
+func WithRateLimit(rps float64, burst int) func(f *Finder) {
+	return func(f *Finder) {
+		if rps <= 0 {
+			f.limit = nil
+			return
+		}
+		if burst < 1 {
+			burst = 1
+		}
+		f.limit = &limiter{rate: rps, burst: float64(burst), tokens: float64(burst)}
+	}
+}
+
+// limiter is a token bucket. Tokens may go negative: each waiter takes its
+// token up front, so later callers queue up behind it.
+type limiter struct {
+	clock Clock
+
+	mu     sync.Mutex // guards the fields below
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// wait blocks until a request may be made, or ctx is done.
+func (l *limiter) wait(ctx context.Context) error {
+	l.mu.Lock()
+	now := l.clock.Now()
+	if !l.last.IsZero() {
+		l.tokens += now.Sub(l.last).Seconds() * l.rate
+		if l.tokens > l.burst {
+			l.tokens = l.burst
+		}
+	}
+	l.last = now
+	l.tokens--
+	short := -l.tokens
+	l.mu.Unlock()
+
+	if short <= 0 {
+		return nil
+	}
+	select {
+	case <-l.clock.After(time.Duration(short / l.rate * float64(time.Second))):
+		return nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		l.tokens++
+		l.mu.Unlock()
+		return ctx.Err()
+	}
+}
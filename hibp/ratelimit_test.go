@@ -0,0 +1,130 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"context"
+	"crypto/sha1"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	clock := newFakeClock()
+	f := NewFinder(WithRateLimit(2, 2), WithClock(clock))
+	l := f.limit
+
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if err := l.wait(ctx); err != nil {
+			t.Fatalf("unexpected: %v\n", err)
+		}
+	}
+	if n := clock.pending(); n != 0 {
+		t.Errorf("expected the burst without waiting: %d\n", n)
+	}
+
+	done := make(chan error)
+	go func() { done <- l.wait(ctx) }()
+	for clock.pending() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	clock.Advance(499 * time.Millisecond)
+	select {
+	case <-done:
+		t.Fatalf("released early\n")
+	case <-time.After(10 * time.Millisecond):
+	}
+	clock.Advance(time.Millisecond)
+	if err := <-done; err != nil {
+		t.Errorf("unexpected: %v\n", err)
+	}
+
+	// A canceled wait gives its token back.
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := l.wait(cctx); err != context.Canceled {
+		t.Errorf("expected %v: %v\n", context.Canceled, err)
+	}
+	clock.Advance(500 * time.Millisecond)
+	if err := l.wait(ctx); err != nil {
+		t.Errorf("unexpected: %v\n", err)
+	}
+	if n := clock.pending(); n != 0 {
+		t.Errorf("expected no wait after refill: %d\n", n)
+	}
+}
+
+func TestWithRateLimit(t *testing.T) {
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Write([]byte(data))
+	}))
+	defer ts.Close()
+
+	clock := newFakeClock()
+	f := NewFinder(
+		WithClient(ts.Client()),
+		WithURLTemplate(fmt.Sprintf("%s/%%s", ts.URL)),
+		WithRateLimit(1, 1),
+		WithClock(clock),
+	)
+
+	// Passwords in different ranges, so the requests aren't coalesced.
+	done := make(chan error, 3)
+	for _, pwd := range []string{"melobie", "gonna-miss", "password"} {
+		go func(pwd string) {
+			h := sha1.Sum([]byte(pwd))
+			_, err := f.Find(h[:])
+			done <- err
+		}(pwd)
+	}
+	for clock.pending() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("unexpected: %v\n", err)
+	}
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Errorf("expected 1 call: %d\n", got)
+	}
+
+	clock.Advance(2 * time.Second)
+	for i := 0; i < 2; i++ {
+		if err := <-done; err != nil {
+			t.Errorf("unexpected: %v\n", err)
+		}
+	}
+	if got := atomic.LoadInt32(&calls); got != 3 {
+		t.Errorf("expected 3 calls: %d\n", got)
+	}
+}
+
+func TestWithRateLimitOff(t *testing.T) {
+	if f := NewFinder(WithRateLimit(0, 10)); f.limit != nil {
+		t.Errorf("expected no limiter\n")
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// RedirectError is returned when the backend answers with a redirect that
+// wasn't followed, such as when WithNoRedirects is in effect.
+type RedirectError struct {
+	StatusCode int
+	Status     string
+	Location   string
+}
+
+func (e *RedirectError) Error() string {
+	return fmt.Sprintf("hibp: unexpected redirect (%s) to %q", e.Status, e.Location)
+}
+
+// Is matches ErrUpstream.
+func (e *RedirectError) Is(target error) bool {
+	return target == ErrUpstream
+}
+
+// WithNoRedirects stops the Finder from following redirects, and reports
+// them as a *RedirectError instead. A captive portal or misconfigured proxy
+// redirecting to some other page would otherwise have that page scanned
+// for the hash, and come back as "no match".
+func WithNoRedirects() func(f *Finder) {
+	return func(f *Finder) {
+		f.noRedirects = true
+	}
+}
+
+// client returns the http.Client to make requests with.
+func (f *Finder) client() *http.Client {
+	if !f.noRedirects {
+		return f.conn
+	}
+	c := *f.conn
+	c.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	return &c
+}
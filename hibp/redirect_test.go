@@ -0,0 +1,74 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNoRedirects(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/portal" {
+			w.Write([]byte("<html>Please log in</html>"))
+			return
+		}
+		http.Redirect(w, r, "/portal", http.StatusFound)
+	}))
+	defer ts.Close()
+
+	h := sha1.Sum([]byte("melobie"))
+
+	// By default the redirect is followed, and it's left to content
+	// sniffing to notice the portal page.
+	f := NewFinder(
+		WithClient(ts.Client()),
+		WithURLTemplate(fmt.Sprintf("%s/%%s", ts.URL)),
+	)
+	n, err := f.Find(h[:])
+	if n != 0 || !errors.Is(err, ErrUnexpectedContent) {
+		t.Errorf("expected [0, %v]: %d, %v\n", ErrUnexpectedContent, n, err)
+	}
+
+	f = NewFinder(
+		WithClient(ts.Client()),
+		WithURLTemplate(fmt.Sprintf("%s/%%s", ts.URL)),
+		WithNoRedirects(),
+	)
+	n, err = f.Find(h[:])
+	if n != 0 {
+		t.Errorf("expected 0: %d\n", n)
+	}
+	var re *RedirectError
+	if !errors.As(err, &re) {
+		t.Fatalf("expected *RedirectError: %v\n", err)
+	}
+	if re.StatusCode != http.StatusFound || re.Location != "/portal" {
+		t.Errorf("unexpected: %+v\n", re)
+	}
+	if ts.Client().CheckRedirect != nil {
+		t.Errorf("expected the provided client to be untouched")
+	}
+}
@@ -0,0 +1,284 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package rediscache provides a hibp.Cache stored in Redis, so that a fleet
+// of instances can share the range bodies any one of them has retrieved. The
+// instances must be given the same hibp.WithCacheSecret to agree on keys,
+// which are HMACs of the ranges rather than the ranges themselves.
+//
+// It speaks just enough of the Redis protocol for the cache (GET, SET with
+// an expiry, DEL, plus AUTH and SELECT), over a small pool of connections.
+// Errors talking to Redis are not reported: the cache misses, or doesn't
+// store, and lookups go to the backend as they would without it. A value
+// longer than the cache's maximum size is treated as an error too, so a
+// misbehaving server can't have it allocate without bound.
+package rediscache
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// Defaults for a Cache created without the corresponding option.
+const (
+	DefaultTTL     = 24 * time.Hour
+	DefaultTimeout = time.Second
+	DefaultPrefix  = "hibp:"
+	DefaultIdle    = 4
+	DefaultMaxSize = 1 << 20
+)
+
+// Cache is a hibp.Cache stored in Redis. It is safe for concurrent use.
+type Cache struct {
+	addr     string
+	password string
+	db       int
+	prefix   string
+	ttl      time.Duration
+	timeout  time.Duration
+	maxSize  int
+
+	idle chan *conn
+}
+
+// New returns a Cache using the Redis server at addr (host:port).
+// Connections are made as they are needed.
+func New(addr string, options ...func(*Cache)) *Cache {
+	c := &Cache{
+		addr:    addr,
+		prefix:  DefaultPrefix,
+		ttl:     DefaultTTL,
+		timeout: DefaultTimeout,
+		maxSize: DefaultMaxSize,
+		idle:    make(chan *conn, DefaultIdle),
+	}
+	for _, opt := range options {
+		opt(c)
+	}
+	return c
+}
+
+// WithPassword has connections authenticate with password.
+func WithPassword(password string) func(*Cache) {
+	return func(c *Cache) {
+		c.password = password
+	}
+}
+
+// WithDB selects the numbered database to store bodies in.
+func WithDB(db int) func(*Cache) {
+	return func(c *Cache) {
+		c.db = db
+	}
+}
+
+// WithPrefix replaces DefaultPrefix as what keys are prefixed with, to keep
+// them apart from other uses of the server.
+func WithPrefix(prefix string) func(*Cache) {
+	return func(c *Cache) {
+		c.prefix = prefix
+	}
+}
+
+// WithTTL replaces DefaultTTL as how long bodies are kept when they aren't
+// given a TTL of their own.
+func WithTTL(ttl time.Duration) func(*Cache) {
+	return func(c *Cache) {
+		c.ttl = ttl
+	}
+}
+
+// WithTimeout replaces DefaultTimeout as the limit on each exchange with
+// the server, so a slow cache can't hold up lookups.
+func WithTimeout(d time.Duration) func(*Cache) {
+	return func(c *Cache) {
+		c.timeout = d
+	}
+}
+
+// WithMaxSize replaces DefaultMaxSize as the longest value, in bytes, read
+// back from the server; a longer one is a miss. It should be at least the
+// Finder's maximum body size (see hibp.WithMaxBodySize).
+func WithMaxSize(n int) func(*Cache) {
+	return func(c *Cache) {
+		c.maxSize = n
+	}
+}
+
+// Get implements hibp.Cache.
+func (c *Cache) Get(key string) ([]byte, bool) {
+	reply, err := c.do("GET", c.prefix+key)
+	body, ok := reply.([]byte)
+	return body, err == nil && ok
+}
+
+// Set implements hibp.Cache.
+func (c *Cache) Set(key string, body []byte, ttl time.Duration) {
+	if ttl <= 0 {
+		ttl = c.ttl
+	}
+	ms := ttl.Milliseconds()
+	if ms <= 0 {
+		return
+	}
+	c.do("SET", c.prefix+key, string(body), "PX", strconv.FormatInt(ms, 10))
+}
+
+// Delete implements hibp.Cache.
+func (c *Cache) Delete(key string) {
+	c.do("DEL", c.prefix+key)
+}
+
+// Close closes the idle connections.
+func (c *Cache) Close() error {
+	for {
+		select {
+		case cn := <-c.idle:
+			cn.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+// conn is a connection to the server.
+type conn struct {
+	net.Conn
+	r   *bufio.Reader
+	max int // longest bulk string read
+}
+
+// do sends a command and returns its reply: a string for a status, an
+// int64, a []byte for a bulk string, nil for a missing value.
+func (c *Cache) do(args ...string) (interface{}, error) {
+	cn, err := c.get()
+	if err != nil {
+		return nil, err
+	}
+	reply, err := cn.do(c.timeout, args...)
+	if err != nil {
+		var re redisError
+		if !errors.As(err, &re) {
+			// The connection may be out of step; don't reuse it.
+			cn.Close()
+			return nil, err
+		}
+	}
+	c.put(cn)
+	return reply, err
+}
+
+// get returns an idle connection, or a new one.
+func (c *Cache) get() (*conn, error) {
+	select {
+	case cn := <-c.idle:
+		return cn, nil
+	default:
+	}
+	nc, err := net.DialTimeout("tcp", c.addr, c.timeout)
+	if err != nil {
+		return nil, err
+	}
+	cn := &conn{nc, bufio.NewReader(nc), c.maxSize}
+	if c.password != "" {
+		if _, err := cn.do(c.timeout, "AUTH", c.password); err != nil {
+			cn.Close()
+			return nil, err
+		}
+	}
+	if c.db != 0 {
+		if _, err := cn.do(c.timeout, "SELECT", strconv.Itoa(c.db)); err != nil {
+			cn.Close()
+			return nil, err
+		}
+	}
+	return cn, nil
+}
+
+// put returns a connection to the idle pool, or closes it if that's full.
+func (c *Cache) put(cn *conn) {
+	select {
+	case c.idle <- cn:
+	default:
+		cn.Close()
+	}
+}
+
+// redisError is an error reply from the server; the connection is still
+// usable after one.
+type redisError string
+
+func (e redisError) Error() string {
+	return "rediscache: " + string(e)
+}
+
+func (cn *conn) do(timeout time.Duration, args ...string) (interface{}, error) {
+	cn.SetDeadline(time.Now().Add(timeout))
+	w := bufio.NewWriter(cn)
+	fmt.Fprintf(w, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(w, "$%d\r\n%s\r\n", len(a), a)
+	}
+	if err := w.Flush(); err != nil {
+		return nil, err
+	}
+	return cn.reply()
+}
+
+func (cn *conn) reply() (interface{}, error) {
+	line, err := cn.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("rediscache: malformed reply %q", line)
+	}
+	kind, rest := line[0], line[1:len(line)-2]
+	switch kind {
+	case '+':
+		return rest, nil
+	case '-':
+		return nil, redisError(rest)
+	case ':':
+		return strconv.ParseInt(rest, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(rest)
+		if err != nil {
+			return nil, fmt.Errorf("rediscache: malformed reply %q", line)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		if n > cn.max {
+			return nil, fmt.Errorf("rediscache: reply of %d bytes exceeds the maximum of %d", n, cn.max)
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(cn.r, buf); err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	}
+	return nil, fmt.Errorf("rediscache: unexpected reply %q", line)
+}
@@ -0,0 +1,219 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package rediscache
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nelz9999/go-hibp/hibp"
+)
+
+var _ hibp.Cache = (*Cache)(nil)
+
+// fakeRedis understands the commands the Cache sends, and records them.
+type fakeRedis struct {
+	net.Listener
+	mu       sync.Mutex
+	data     map[string]string
+	commands []string
+	conns    int
+}
+
+func newFakeRedis(t *testing.T) *fakeRedis {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected: %v\n", err)
+	}
+	s := &fakeRedis{Listener: l, data: map[string]string{}}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.conns++
+			s.mu.Unlock()
+			go s.serve(c)
+		}
+	}()
+	return s
+}
+
+func (s *fakeRedis) serve(c net.Conn) {
+	defer c.Close()
+	r := bufio.NewReader(c)
+	for {
+		var n int
+		if _, err := fmt.Fscanf(r, "*%d\r\n", &n); err != nil {
+			return
+		}
+		args := make([]string, n)
+		for i := range args {
+			var size int
+			if _, err := fmt.Fscanf(r, "$%d\r\n", &size); err != nil {
+				return
+			}
+			buf := make([]byte, size+2)
+			if _, err := io.ReadFull(r, buf); err != nil {
+				return
+			}
+			args[i] = string(buf[:size])
+		}
+
+		s.mu.Lock()
+		cmd := strings.Join(args, " ")
+		if args[0] == "SET" {
+			cmd = strings.Join(append(args[:2:2], args[3:]...), " ")
+		}
+		s.commands = append(s.commands, cmd)
+		switch args[0] {
+		case "AUTH", "SELECT":
+			fmt.Fprint(c, "+OK\r\n")
+		case "GET":
+			if v, ok := s.data[args[1]]; ok {
+				fmt.Fprintf(c, "$%d\r\n%s\r\n", len(v), v)
+			} else {
+				fmt.Fprint(c, "$-1\r\n")
+			}
+		case "SET":
+			s.data[args[1]] = args[2]
+			fmt.Fprint(c, "+OK\r\n")
+		case "DEL":
+			_, ok := s.data[args[1]]
+			delete(s.data, args[1])
+			fmt.Fprintf(c, ":%d\r\n", map[bool]int{true: 1}[ok])
+		default:
+			fmt.Fprintf(c, "-ERR unknown command '%s'\r\n", args[0])
+		}
+		s.mu.Unlock()
+	}
+}
+
+func (s *fakeRedis) log() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return strings.Join(s.commands, "\n")
+}
+
+func TestCache(t *testing.T) {
+	s := newFakeRedis(t)
+	c := New(s.Addr().String(), WithPassword("s3cret"), WithDB(2), WithTTL(time.Minute))
+	defer c.Close()
+
+	if _, ok := c.Get("sha1:21BD1"); ok {
+		t.Errorf("expected a miss\n")
+	}
+	c.Set("sha1:21BD1", []byte("0018A45C4D1DEF81644B54AB7F969B88D65:229\r\n"), 0)
+	if body, ok := c.Get("sha1:21BD1"); !ok || string(body) != "0018A45C4D1DEF81644B54AB7F969B88D65:229\r\n" {
+		t.Errorf("expected a hit: %q, %t\n", body, ok)
+	}
+	c.Set("sha1:FFFFF", []byte("x"), 1500*time.Millisecond)
+	c.Delete("sha1:21BD1")
+	if _, ok := c.Get("sha1:21BD1"); ok {
+		t.Errorf("expected a miss after delete\n")
+	}
+
+	exp := strings.Join([]string{
+		"AUTH s3cret",
+		"SELECT 2",
+		"GET hibp:sha1:21BD1",
+		"SET hibp:sha1:21BD1 PX 60000",
+		"GET hibp:sha1:21BD1",
+		"SET hibp:sha1:FFFFF PX 1500",
+		"DEL hibp:sha1:21BD1",
+		"GET hibp:sha1:21BD1",
+	}, "\n")
+	if got := s.log(); got != exp {
+		t.Errorf("expected:\n%s\ngot:\n%s\n", exp, got)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conns != 1 {
+		t.Errorf("expected the connection reused: %d\n", s.conns)
+	}
+}
+
+func TestCacheUnreachable(t *testing.T) {
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	addr := l.Addr().String()
+	l.Close()
+
+	c := New(addr, WithTimeout(100*time.Millisecond))
+	c.Set("sha1:21BD1", []byte("x"), 0)
+	if _, ok := c.Get("sha1:21BD1"); ok {
+		t.Errorf("expected a miss\n")
+	}
+}
+
+func TestCacheMaxSize(t *testing.T) {
+	s := newFakeRedis(t)
+	c := New(s.Addr().String(), WithMaxSize(4))
+	defer c.Close()
+
+	c.Set("sha1:21BD1", []byte("12345"), 0)
+	if body, ok := c.Get("sha1:21BD1"); ok {
+		t.Errorf("expected a miss: %q\n", body)
+	}
+	c.Set("sha1:21BD1", []byte("1234"), 0)
+	if body, ok := c.Get("sha1:21BD1"); !ok || string(body) != "1234" {
+		t.Errorf("expected a hit: %q, %t\n", body, ok)
+	}
+}
+
+func TestReplies(t *testing.T) {
+	testCases := []struct {
+		name    string
+		in      string
+		exReply interface{}
+		exErr   bool
+	}{
+		{"status", "+OK\r\n", "OK", false},
+		{"error", "-ERR nope\r\n", nil, true},
+		{"integer", ":42\r\n", int64(42), false},
+		{"bulk", "$5\r\nhello\r\n", []byte("hello"), false},
+		{"nil", "$-1\r\n", nil, false},
+		{"malformed", "hello\n", nil, true},
+		{"unexpected", "*1\r\n", nil, true},
+		{"too large", "$11\r\nhello world\r\n", nil, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cn := &conn{r: bufio.NewReader(strings.NewReader(tc.in)), max: 10}
+			reply, err := cn.reply()
+			if tc.exErr != (err != nil) {
+				t.Errorf("expected error %t: %v\n", tc.exErr, err)
+			}
+			if fmt.Sprint(reply) != fmt.Sprint(tc.exReply) {
+				t.Errorf("expected %v: %v\n", tc.exReply, reply)
+			}
+		})
+	}
+}
@@ -0,0 +1,172 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/url"
+	"time"
+)
+
+// Result is the outcome of looking up a single input, in the shape shared
+// by the batch and streaming APIs.
+type Result struct {
+	// Input is the value as it was provided, before normalization.
+	Input string
+	// Hash is the decoded sum that was looked up; nil if Input was invalid.
+	Hash []byte
+	// Count is the number of times Hash has been seen in breaches.
+	Count int64
+	// Err is any problem encountered with this particular input.
+	Err error
+	// Code classifies Err; it is empty when Err is nil.
+	Code ErrorCode
+	// Duration is how long the lookup took.
+	Duration time.Duration
+	// Backend is the host that was asked for the range.
+	Backend string
+	// CacheHit reports whether the range was served without a request.
+	CacheHit bool
+}
+
+// ErrorCode is a stable classification of why a lookup failed, meant for
+// deciding what to do about it (retry, flag the input, page someone)
+// without inspecting error values. The string values will not change.
+type ErrorCode string
+
+const (
+	// CodeInvalidInput means the input was not a well-formed digest; it
+	// will never succeed.
+	CodeInvalidInput ErrorCode = "invalid_input"
+	// CodeBlocklisted means the input is on the local Blocklist.
+	CodeBlocklisted ErrorCode = "blocklisted"
+	// CodeWeakPassword means the password was rejected by the PreScreen.
+	CodeWeakPassword ErrorCode = "weak_password"
+	// CodeThrottled means the backend asked for requests to slow down; the
+	// lookup can be retried later.
+	CodeThrottled ErrorCode = "throttled"
+	// CodeBackendDown means the backend couldn't be reached or answered
+	// with an error.
+	CodeBackendDown ErrorCode = "backend_down"
+	// CodeParseError means the backend answered with something that isn't
+	// range data.
+	CodeParseError ErrorCode = "parse_error"
+	// CodeDisabled means the kill switch is on; see WithKillSwitch.
+	CodeDisabled ErrorCode = "disabled"
+	// CodeCanceled means the lookup was abandoned by its context.
+	CodeCanceled ErrorCode = "canceled"
+	// CodeUnknown is for errors that fit none of the other codes.
+	CodeUnknown ErrorCode = "unknown"
+)
+
+// Classify returns the ErrorCode for an error returned by this package, or
+// an empty ErrorCode for a nil error.
+func Classify(err error) ErrorCode {
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, ErrInvalidHex), errors.Is(err, ErrInvalidDigestLength),
+		errors.Is(err, io.ErrShortBuffer), errors.Is(err, io.ErrShortWrite):
+		return CodeInvalidInput
+	case errors.Is(err, ErrBlocklisted):
+		return CodeBlocklisted
+	case errors.Is(err, ErrWeakPassword):
+		return CodeWeakPassword
+	case errors.Is(err, ErrDisabled):
+		return CodeDisabled
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return CodeCanceled
+	case errors.Is(err, ErrThrottled):
+		return CodeThrottled
+	case errors.Is(err, ErrUpstream), errors.Is(err, ErrCircuitOpen):
+		return CodeBackendDown
+	case errors.Is(err, ErrMalformedLine), errors.Is(err, ErrInvalidCount),
+		errors.Is(err, ErrLineTooLong), errors.Is(err, ErrBodyTooLarge),
+		errors.Is(err, ErrUnexpectedContent):
+		return CodeParseError
+	}
+	var ne net.Error
+	if errors.As(err, &ne) {
+		return CodeBackendDown
+	}
+	return CodeUnknown
+}
+
+// FindStream looks up each hex encoded SHA1 received from in (see
+// NormalizeSHA1 for what is accepted), and sends one Result per input, in
+// order, on the returned channel.
+//
+// The returned channel is closed after in is closed and drained, or as soon
+// as ctx is done.
+func (f *Finder) FindStream(ctx context.Context, in <-chan string) <-chan Result {
+	out := make(chan Result)
+	go func() {
+		defer close(out)
+		for {
+			var input string
+			var ok bool
+			select {
+			case <-ctx.Done():
+				return
+			case input, ok = <-in:
+				if !ok {
+					return
+				}
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case out <- f.result(ctx, input):
+			}
+		}
+	}()
+	return out
+}
+
+func (f *Finder) result(ctx context.Context, input string) Result {
+	start := f.clock.Now()
+	res := Result{Input: input}
+	res.Hash, res.Err = f.normalize(input)
+	if res.Err == nil {
+		res.Backend = f.backend()
+		res.Count, res.CacheHit, res.Err = f.find(ctx, f.mode, res.Hash)
+	}
+	res.Code = Classify(res.Err)
+	res.Duration = f.clock.Now().Sub(start)
+	return res
+}
+
+// backend returns the host that range requests are sent to.
+func (f *Finder) backend() string {
+	raw, err := f.rangeURL(SHA1, nil)
+	if err != nil {
+		return ""
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
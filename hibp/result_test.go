@@ -0,0 +1,170 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestFindStream(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(data))
+	}))
+	defer ts.Close()
+
+	f := NewFinder(
+		WithClient(ts.Client()),
+		WithURLTemplate(fmt.Sprintf("%s/%%s", ts.URL)),
+	)
+	u, _ := url.Parse(ts.URL)
+
+	testCases := []struct {
+		input string
+		exp   int64
+		xErr  error
+	}{
+		{
+			// melobie
+			"21bd1012a7ca357541f0ac487871feec1891c49c",
+			401,
+			nil,
+		},
+		{
+			"not a hash",
+			0,
+			ErrInvalidHex,
+		},
+		{
+			// gonna-miss
+			"5D284D04B6A031675E0E060A97986C30E8A67B61",
+			0,
+			nil,
+		},
+	}
+
+	in := make(chan string)
+	go func() {
+		defer close(in)
+		for _, tc := range testCases {
+			in <- tc.input
+		}
+	}()
+
+	i := 0
+	for res := range f.FindStream(context.Background(), in) {
+		tc := testCases[i]
+		i++
+		if res.Input != tc.input {
+			t.Errorf("expected %q: %q\n", tc.input, res.Input)
+		}
+		if !errors.Is(res.Err, tc.xErr) {
+			t.Errorf("expected %v: %v\n", tc.xErr, res.Err)
+		}
+		if res.Code != Classify(tc.xErr) {
+			t.Errorf("expected %q: %q\n", Classify(tc.xErr), res.Code)
+		}
+		if res.Count != tc.exp {
+			t.Errorf("expected %d: %d\n", tc.exp, res.Count)
+		}
+		if tc.xErr == nil && res.Backend != u.Host {
+			t.Errorf("expected %q: %q\n", u.Host, res.Backend)
+		}
+	}
+	if i != len(testCases) {
+		t.Errorf("expected %d results: %d\n", len(testCases), i)
+	}
+}
+
+func TestFindStreamCanceled(t *testing.T) {
+	f := NewFinder()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	in := make(chan string)
+	for res := range f.FindStream(ctx, in) {
+		t.Errorf("unexpected: %v\n", res)
+	}
+}
+
+func TestClassify(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/throttled":
+			w.WriteHeader(http.StatusTooManyRequests)
+		case "/down":
+			w.WriteHeader(http.StatusBadGateway)
+		case "/html":
+			w.Write([]byte("<html></html>"))
+		}
+	}))
+	defer ts.Close()
+	fetch := func(path string) error {
+		f := NewFinder(
+			WithClient(ts.Client()),
+			WithURLTemplate(ts.URL+"/"+path+"?%s"),
+		)
+		_, err := f.fetchPrefix(context.Background(), SHA1, []byte("21BD1"))
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	canceled := NewFinder()
+	_, cancelErr := canceled.FindContext(ctx, make([]byte, 20))
+
+	unreachable := NewFinder(WithURLTemplate("http://127.0.0.1:1/%s"))
+	_, netErr := unreachable.FindContext(context.Background(), make([]byte, 20))
+
+	testCases := []struct {
+		name string
+		err  error
+		exp  ErrorCode
+	}{
+		{"nil", nil, ""},
+		{"short", io.ErrShortBuffer, CodeInvalidInput},
+		{"long", io.ErrShortWrite, CodeInvalidInput},
+		{"hex", fmt.Errorf("%w: nope", ErrInvalidHex), CodeInvalidInput},
+		{"blocklisted", ErrBlocklisted, CodeBlocklisted},
+		{"throttled", fetch("throttled"), CodeThrottled},
+		{"bad gateway", fetch("down"), CodeBackendDown},
+		{"unreachable", netErr, CodeBackendDown},
+		{"redirect", &RedirectError{}, CodeBackendDown},
+		{"html", fetch("html"), CodeParseError},
+		{"count", fmt.Errorf("%w: x", ErrInvalidCount), CodeParseError},
+		{"canceled", cancelErr, CodeCanceled},
+		{"other", errors.New("mystery"), CodeUnknown},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Classify(tc.err); got != tc.exp {
+				t.Errorf("expected %q: %q (%v)\n", tc.exp, got, tc.err)
+			}
+		})
+	}
+}
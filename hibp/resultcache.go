@@ -0,0 +1,181 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"container/list"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// resultKeySize is the size of the secret generated when NewResultCache
+// isn't given one.
+const resultKeySize = 32
+
+// resultEntryCost estimates what a ResultCache entry takes: its key, the
+// entry itself, its list element and its map slot.
+const resultEntryCost = 192
+
+// ResultCache remembers the counts of recently looked up hashes, for
+// flows like sign-up forms where the same password is typically retried
+// within seconds. Entries are keyed by an HMAC of the hash rather than the
+// hash itself, so the cache's contents don't reveal which passwords were
+// checked. It is safe for concurrent use.
+type ResultCache struct {
+	secret []byte
+	size   int
+	ttl    time.Duration
+	budget *MemoryBudget
+
+	hits   uint64
+	misses uint64
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+}
+
+type resultEntry struct {
+	key     string
+	count   int64
+	expires time.Time
+}
+
+// NewResultCache returns a ResultCache holding up to size entries, each for
+// at most ttl. The secret keys the HMAC; if it is empty, a random one is
+// generated, which is only a problem if several caches must agree on keys.
+func NewResultCache(secret []byte, size int, ttl time.Duration, options ...func(*ResultCache)) *ResultCache {
+	if len(secret) == 0 {
+		secret = make([]byte, resultKeySize)
+		if _, err := rand.Read(secret); err != nil {
+			panic("hibp: generating result cache secret: " + err.Error())
+		}
+	}
+	c := &ResultCache{
+		secret:  append([]byte(nil), secret...),
+		size:    size,
+		ttl:     ttl,
+		entries: map[string]*list.Element{},
+		lru:     list.New(),
+	}
+	for _, opt := range options {
+		opt(c)
+	}
+	return c
+}
+
+// WithResultCacheBudget has the ResultCache draw the memory it takes from
+// b, which may be shared with other caches.
+func WithResultCacheBudget(b *MemoryBudget) func(c *ResultCache) {
+	return func(c *ResultCache) {
+		c.budget = b
+	}
+}
+
+// WithResultCache has Find remember the counts of hashes it looks up in c,
+// and answer repeated lookups from it. Only successful lookups are kept.
+func WithResultCache(c *ResultCache) func(f *Finder) {
+	return func(f *Finder) {
+		f.results = c
+	}
+}
+
+// Len returns the number of entries held, including expired ones that
+// haven't been dropped yet.
+func (c *ResultCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+// Stats returns the number of hits and misses so far, and what the cache
+// holds.
+func (c *ResultCache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return CacheStats{
+		Hits:    atomic.LoadUint64(&c.hits),
+		Misses:  atomic.LoadUint64(&c.misses),
+		Entries: c.lru.Len(),
+		Bytes:   int64(c.lru.Len()) * resultEntryCost,
+	}
+}
+
+func (c *ResultCache) key(sum []byte) string {
+	mac := hmac.New(sha256.New, c.secret)
+	mac.Write(sum)
+	return string(mac.Sum(nil))
+}
+
+func (c *ResultCache) get(now time.Time, sum []byte) (int64, bool) {
+	k := c.key(sum)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[k]
+	if !ok {
+		atomic.AddUint64(&c.misses, 1)
+		return 0, false
+	}
+	e := el.Value.(*resultEntry)
+	if !now.Before(e.expires) {
+		c.remove(el)
+		atomic.AddUint64(&c.misses, 1)
+		return 0, false
+	}
+	c.lru.MoveToFront(el)
+	atomic.AddUint64(&c.hits, 1)
+	return e.count, true
+}
+
+func (c *ResultCache) put(now time.Time, sum []byte, count int64) {
+	if c.size <= 0 || c.ttl <= 0 {
+		return
+	}
+	k := c.key(sum)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[k]; ok {
+		e := el.Value.(*resultEntry)
+		e.count, e.expires = count, now.Add(c.ttl)
+		c.lru.MoveToFront(el)
+		return
+	}
+	for c.lru.Len() >= c.size || (c.budget != nil && !c.budget.reserve(resultEntryCost)) {
+		if c.lru.Len() == 0 {
+			return
+		}
+		c.remove(c.lru.Back())
+	}
+	c.entries[k] = c.lru.PushFront(&resultEntry{key: k, count: count, expires: now.Add(c.ttl)})
+}
+
+// remove drops el, returning its memory. c.mu must be held.
+func (c *ResultCache) remove(el *list.Element) {
+	c.lru.Remove(el)
+	delete(c.entries, el.Value.(*resultEntry).key)
+	if c.budget != nil {
+		c.budget.release(resultEntryCost)
+	}
+}
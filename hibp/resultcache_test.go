@@ -0,0 +1,146 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResultCache(t *testing.T) {
+	requests := 0
+	fail := false
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if fail {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(data))
+	}))
+	defer ts.Close()
+
+	clock := newFakeClock()
+	cache := NewResultCache([]byte("s3cret"), 2, 10*time.Second)
+	f := NewFinder(
+		WithClient(ts.Client()),
+		WithURLTemplate(fmt.Sprintf("%s/%%s", ts.URL)),
+		WithClock(clock),
+		WithResultCache(cache),
+	)
+
+	check := func(pwd string, exp int64, xRequests int) {
+		t.Helper()
+		n, err := f.FindPassword(pwd)
+		if n != exp || err != nil {
+			t.Errorf("expected [%d, nil]: %d, %v\n", exp, n, err)
+		}
+		if requests != xRequests {
+			t.Errorf("expected %d requests: %d\n", xRequests, requests)
+		}
+	}
+
+	check("melobie", 401, 1)
+	check("melobie", 401, 1)
+	check("gonna-miss", 0, 2)
+	check("gonna-miss", 0, 2)
+
+	// Evicts melobie, the least recently used.
+	check("lauragpe", 229, 3)
+	check("melobie", 401, 4)
+
+	clock.Advance(10 * time.Second)
+	check("melobie", 401, 5)
+	if exp, got := (CacheStats{Hits: 2, Misses: 5, Entries: 2, Bytes: 2 * resultEntryCost}), cache.Stats(); got != exp {
+		t.Errorf("expected %+v: %+v\n", exp, got)
+	}
+
+	// Failures aren't remembered, even when degraded mode hides them.
+	fail = true
+	f = NewFinder(
+		WithClient(ts.Client()),
+		WithURLTemplate(fmt.Sprintf("%s/%%s", ts.URL)),
+		WithClock(clock),
+		WithResultCache(NewResultCache(nil, 2, time.Minute)),
+		WithDegradedMode(AllowOnError),
+	)
+	check("melobie", 0, 6)
+	fail = false
+	check("melobie", 401, 7)
+}
+
+func TestResultCacheKeys(t *testing.T) {
+	h := sha1.Sum([]byte("password"))
+	hex := fmt.Sprintf("%X", h)
+
+	a := NewResultCache([]byte("alpha"), 10, time.Minute)
+	b := NewResultCache([]byte("bravo"), 10, time.Minute)
+	if a.key(h[:]) == b.key(h[:]) {
+		t.Errorf("expected keys to depend on the secret")
+	}
+	if a.key(h[:]) != NewResultCache([]byte("alpha"), 1, time.Second).key(h[:]) {
+		t.Errorf("expected keys to be stable for a secret")
+	}
+
+	now := time.Now()
+	a.put(now, h[:], 9545824)
+	for k := range a.entries {
+		if bytes.Contains([]byte(k), h[:]) || strings.Contains(k, hex) {
+			t.Errorf("key reveals the hash")
+		}
+	}
+	if n, ok := a.get(now, h[:]); !ok || n != 9545824 {
+		t.Errorf("expected [9545824, true]: %d, %t\n", n, ok)
+	}
+}
+
+func TestResultCacheBudget(t *testing.T) {
+	b := NewMemoryBudget(2 * resultEntryCost)
+	c := NewResultCache(nil, 10, time.Minute, WithResultCacheBudget(b))
+	now := time.Now()
+	for i, pwd := range []string{"alpha", "bravo", "charlie"} {
+		h := sha1.Sum([]byte(pwd))
+		c.put(now, h[:], int64(i))
+	}
+	if n := c.Len(); n != 2 {
+		t.Errorf("expected 2 entries: %d\n", n)
+	}
+	if exp, got := int64(2*resultEntryCost), b.Used(); got != exp {
+		t.Errorf("expected %d: %d\n", exp, got)
+	}
+	h := sha1.Sum([]byte("alpha"))
+	if _, ok := c.get(now, h[:]); ok {
+		t.Errorf("expected alpha to be evicted\n")
+	}
+
+	// Expired entries give their memory back.
+	h = sha1.Sum([]byte("bravo"))
+	c.get(now.Add(time.Minute), h[:])
+	if exp, got := int64(resultEntryCost), b.Used(); got != exp {
+		t.Errorf("expected %d: %d\n", exp, got)
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"time"
+)
+
+// DefaultRetryBase is the wait before the first retry of a RetryPolicy that
+// doesn't set its own.
+const DefaultRetryBase = 200 * time.Millisecond
+
+// RetryPolicy describes how failed range requests are retried, with
+// exponential backoff, by WithRetry.
+type RetryPolicy struct {
+	// MaxAttempts bounds how many times a request is tried, including the
+	// first; it is the same setting as WithMaxAttempts. Zero leaves it as
+	// it is.
+	MaxAttempts int
+	// Base is the wait before the first retry; zero means
+	// DefaultRetryBase.
+	Base time.Duration
+	// Multiplier grows the wait after each retry; less than 1 means 2.
+	Multiplier float64
+	// Jitter spreads each wait randomly over its second half, so clients
+	// that failed together don't retry together.
+	Jitter bool
+	// Retryable decides which errors are worth retrying; nil means
+	// RetryableError.
+	Retryable func(error) bool
+}
+
+// WithRetry has range requests that fail with a retryable error tried
+// again after a backoff, as described by policy. A throttled request that
+// carries a Retry-After header waits as long as the header says instead
+// (see WithMaxAttempts). Waits are bounded by WithMaxRetryWait, and a
+// retry whose wait would outlast the deadline of the lookup's context
+// isn't made.
+func WithRetry(policy RetryPolicy) func(f *Finder) {
+	return func(f *Finder) {
+		if policy.MaxAttempts > 0 {
+			f.attempts = policy.MaxAttempts
+		}
+		f.retry = &policy
+	}
+}
+
+// RetryableError reports whether err is likely to go away on its own:
+// server errors, throttling, and network failures. It is the default
+// RetryPolicy.Retryable.
+func RetryableError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var he *HTTPError
+	if errors.As(err, &he) {
+		return he.StatusCode >= 500 || he.StatusCode == http.StatusTooManyRequests
+	}
+	var ne net.Error
+	return errors.As(err, &ne)
+}
+
+// backoff returns the wait before the retry that follows attempt.
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	base := p.Base
+	if base <= 0 {
+		base = DefaultRetryBase
+	}
+	mult := p.Multiplier
+	if mult < 1 {
+		mult = 2
+	}
+	d := float64(base) * math.Pow(mult, float64(attempt-1))
+	if d > math.MaxInt64 {
+		return math.MaxInt64
+	}
+	return time.Duration(d)
+}
+
+// retryWait returns how long to wait before retrying after attempt failed
+// with err, and whether to retry at all.
+func (f *Finder) retryWait(ctx context.Context, attempt int, err error) (time.Duration, bool) {
+	wait := f.retryAfter(err)
+	if wait > f.maxRetryWait() {
+		return 0, false
+	}
+	if wait < 0 {
+		if f.retry == nil {
+			return 0, false
+		}
+		retryable := f.retry.Retryable
+		if retryable == nil {
+			retryable = RetryableError
+		}
+		if !retryable(err) {
+			return 0, false
+		}
+		wait = f.retry.backoff(attempt)
+		if f.retry.Jitter {
+			wait = wait/2 + f.jitter(wait-wait/2)
+		}
+		if max := f.maxRetryWait(); wait > max {
+			wait = max
+		}
+	}
+	if deadline, ok := ctx.Deadline(); ok && deadline.Sub(f.clock.Now()) < wait {
+		return 0, false
+	}
+	return wait, true
+}
@@ -0,0 +1,195 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetryableError(t *testing.T) {
+	testCases := []struct {
+		name string
+		err  error
+		exp  bool
+	}{
+		{"server error", &HTTPError{StatusCode: 503}, true},
+		{"throttled", &HTTPError{StatusCode: 429}, true},
+		{"not found", &HTTPError{StatusCode: 404}, false},
+		{"network", &net.OpError{Op: "dial", Err: errors.New("refused")}, true},
+		{"canceled", context.Canceled, false},
+		{"deadline", fmt.Errorf("get: %w", context.DeadlineExceeded), false},
+		{"parse", ErrMalformedLine, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := RetryableError(tc.err); got != tc.exp {
+				t.Errorf("expected %t: %t\n", tc.exp, got)
+			}
+		})
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	testCases := []struct {
+		name   string
+		policy RetryPolicy
+		exp    []time.Duration
+	}{
+		{
+			"defaults",
+			RetryPolicy{},
+			[]time.Duration{200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond},
+		},
+		{
+			"custom",
+			RetryPolicy{Base: 100 * time.Millisecond, Multiplier: 3},
+			[]time.Duration{100 * time.Millisecond, 300 * time.Millisecond, 900 * time.Millisecond},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for i, exp := range tc.exp {
+				if got := tc.policy.backoff(i + 1); got != exp {
+					t.Errorf("expected %v: %v\n", exp, got)
+				}
+			}
+		})
+	}
+}
+
+func TestRetryWait(t *testing.T) {
+	ctx := context.Background()
+	unavailable := &HTTPError{StatusCode: 503}
+
+	f := NewFinder()
+	if _, ok := f.retryWait(ctx, 1, unavailable); ok {
+		t.Errorf("expected no retry without a policy\n")
+	}
+
+	f = NewFinder(
+		WithRetry(RetryPolicy{Base: 100 * time.Millisecond, Jitter: true}),
+		WithRandom(bytes.NewReader(make([]byte, 8))),
+	)
+	if wait, ok := f.retryWait(ctx, 1, unavailable); !ok || wait != 50*time.Millisecond {
+		t.Errorf("expected [50ms, true]: %v, %t\n", wait, ok)
+	}
+
+	dctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if _, ok := f.retryWait(dctx, 1, unavailable); ok {
+		t.Errorf("expected no retry past the deadline\n")
+	}
+
+	// The deadline is measured by the Finder's clock.
+	clock := newFakeClock()
+	f = NewFinder(
+		WithRetry(RetryPolicy{Base: 100 * time.Millisecond}),
+		WithClock(clock),
+	)
+	dctx, cancel = context.WithDeadline(ctx, clock.Now().Add(time.Second))
+	defer cancel()
+	if wait, ok := f.retryWait(dctx, 1, unavailable); !ok || wait != 100*time.Millisecond {
+		t.Errorf("expected [100ms, true]: %v, %t\n", wait, ok)
+	}
+	clock.Advance(950 * time.Millisecond)
+	if _, ok := f.retryWait(dctx, 1, unavailable); ok {
+		t.Errorf("expected no retry past the deadline\n")
+	}
+}
+
+func TestWithRetry(t *testing.T) {
+	testCases := []struct {
+		name    string
+		options []func(*Finder)
+		exCalls int32
+		exErr   bool
+	}{
+		{
+			"no policy",
+			nil,
+			1,
+			true,
+		},
+		{
+			"retried",
+			[]func(*Finder){WithRetry(RetryPolicy{Base: time.Millisecond})},
+			3,
+			false,
+		},
+		{
+			"out of attempts",
+			[]func(*Finder){WithRetry(RetryPolicy{MaxAttempts: 2, Base: time.Millisecond})},
+			2,
+			true,
+		},
+		{
+			"not retryable",
+			[]func(*Finder){WithRetry(RetryPolicy{
+				Base:      time.Millisecond,
+				Retryable: func(error) bool { return false },
+			})},
+			1,
+			true,
+		},
+	}
+
+	h := sha1.Sum([]byte("melobie"))
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var calls int32
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if atomic.AddInt32(&calls, 1) <= 2 {
+					w.WriteHeader(http.StatusBadGateway)
+					return
+				}
+				w.Write([]byte(data))
+			}))
+			defer ts.Close()
+
+			f := NewFinder(append([]func(*Finder){
+				WithClient(ts.Client()),
+				WithURLTemplate(fmt.Sprintf("%s/%%s", ts.URL)),
+			}, tc.options...)...)
+			n, err := f.Find(h[:])
+			if tc.exErr != (err != nil) {
+				t.Errorf("expected error %t: %v\n", tc.exErr, err)
+			}
+			if err == nil && n != 401 {
+				t.Errorf("expected 401: %d\n", n)
+			}
+			if got := atomic.LoadInt32(&calls); got != tc.exCalls {
+				t.Errorf("expected %d calls: %d\n", tc.exCalls, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultMaxAttempts is how many times a throttled range request is tried
+// unless WithMaxAttempts says otherwise.
+const DefaultMaxAttempts = 3
+
+// DefaultMaxRetryWait is the longest wait before a retry unless
+// WithMaxRetryWait says otherwise.
+const DefaultMaxRetryWait = 10 * time.Second
+
+// WithMaxAttempts sets how many times a range request is tried when the
+// backend throttles it. A 429 response carrying a Retry-After header is
+// retried, transparently, once the time it asks for has passed; one without
+// the header, or one asking for a wait longer than WithMaxRetryWait allows
+// or that would outlast the deadline of the lookup's context, is returned
+// right away. Other failures are only retried under WithRetry. A value of
+// 1 turns retries off.
+func WithMaxAttempts(n int) func(f *Finder) {
+	return func(f *Finder) {
+		f.attempts = n
+	}
+}
+
+// WithMaxRetryWait bounds the wait before a retry to d, in place of
+// DefaultMaxRetryWait. A throttled response asking for a longer wait is
+// returned as it is, rather than leaving the lookup blocked for however
+// long the backend says; a longer backoff under WithRetry is cut to d.
+func WithMaxRetryWait(d time.Duration) func(f *Finder) {
+	return func(f *Finder) {
+		f.maxWait = d
+	}
+}
+
+// maxRetryWait returns the configured bound on waits, or the default.
+func (f *Finder) maxRetryWait() time.Duration {
+	if f.maxWait > 0 {
+		return f.maxWait
+	}
+	return DefaultMaxRetryWait
+}
+
+// maxAttempts returns the configured number of attempts, or the default.
+func (f *Finder) maxAttempts() int {
+	if f.attempts > 0 {
+		return f.attempts
+	}
+	return DefaultMaxAttempts
+}
+
+// retryAfter returns the wait asked for by err, if it is a 429 response
+// with a usable Retry-After header, or a negative duration otherwise.
+func (f *Finder) retryAfter(err error) time.Duration {
+	he, ok := err.(*HTTPError)
+	if !ok || he.StatusCode != http.StatusTooManyRequests || he.retryAfter == "" {
+		return -1
+	}
+	return parseRetryAfter(he.retryAfter, f.clock.Now())
+}
+
+// parseRetryAfter reads a Retry-After header, given either as a number of
+// seconds or as an HTTP date. It returns a negative duration if the header
+// is missing or malformed, and zero for a date that has passed.
+func parseRetryAfter(h string, now time.Time) time.Duration {
+	h = strings.TrimSpace(h)
+	if h == "" {
+		return -1
+	}
+	if secs, err := strconv.ParseInt(h, 10, 64); err == nil {
+		if secs < 0 {
+			return -1
+		}
+		return time.Duration(secs) * time.Second
+	}
+	t, err := http.ParseTime(h)
+	if err != nil {
+		return -1
+	}
+	if d := t.Sub(now); d > 0 {
+		return d
+	}
+	return 0
+}
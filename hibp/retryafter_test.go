@@ -0,0 +1,289 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"context"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2017, 12, 1, 0, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name   string
+		header string
+		exWait time.Duration
+	}{
+		{"missing", "", -1},
+		{"garbage", "soon", -1},
+		{"negative", "-5", -1},
+		{"zero", "0", 0},
+		{"seconds", " 120 ", 2 * time.Minute},
+		{"date", "Fri, 01 Dec 2017 00:00:30 GMT", 30 * time.Second},
+		{"past date", "Thu, 30 Nov 2017 23:00:00 GMT", 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := parseRetryAfter(tc.header, now); got != tc.exWait {
+				t.Errorf("expected %v: %v\n", tc.exWait, got)
+			}
+		})
+	}
+}
+
+// newThrottlingServer answers the first throttled requests with a 429,
+// with the given Retry-After header if it isn't empty, and with data after
+// that.
+func newThrottlingServer(throttled int32, retryAfter string) (*httptest.Server, *int32) {
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) <= throttled {
+			if retryAfter != "" {
+				w.Header().Set("Retry-After", retryAfter)
+			}
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte(data))
+	}))
+	return ts, &calls
+}
+
+func TestRetryAfter(t *testing.T) {
+	testCases := []struct {
+		name       string
+		throttled  int32
+		retryAfter string
+		options    []func(*Finder)
+		exCalls    int32
+		exErr      bool
+	}{
+		{
+			"retried",
+			2,
+			"0",
+			nil,
+			3,
+			false,
+		},
+		{
+			"out of attempts",
+			3,
+			"0",
+			nil,
+			3,
+			true,
+		},
+		{
+			"more attempts",
+			3,
+			"0",
+			[]func(*Finder){WithMaxAttempts(4)},
+			4,
+			false,
+		},
+		{
+			"retries off",
+			1,
+			"0",
+			[]func(*Finder){WithMaxAttempts(1)},
+			1,
+			true,
+		},
+		{
+			"no header",
+			1,
+			"",
+			nil,
+			1,
+			true,
+		},
+	}
+
+	h := sha1.Sum([]byte("melobie"))
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ts, calls := newThrottlingServer(tc.throttled, tc.retryAfter)
+			defer ts.Close()
+
+			f := NewFinder(append([]func(*Finder){
+				WithClient(ts.Client()),
+				WithURLTemplate(fmt.Sprintf("%s/%%s", ts.URL)),
+			}, tc.options...)...)
+			n, err := f.Find(h[:])
+			if tc.exErr != (err != nil) {
+				t.Errorf("expected error %t: %v\n", tc.exErr, err)
+			}
+			if err == nil && n != 401 {
+				t.Errorf("expected 401: %d\n", n)
+			}
+			if err != nil && Classify(err) != CodeThrottled {
+				t.Errorf("expected %v: %v\n", CodeThrottled, err)
+			}
+			if got := atomic.LoadInt32(calls); got != tc.exCalls {
+				t.Errorf("expected %d calls: %d\n", tc.exCalls, got)
+			}
+		})
+	}
+}
+
+func TestRetryAfterWaits(t *testing.T) {
+	ts, calls := newThrottlingServer(1, "30")
+	defer ts.Close()
+
+	clock := newFakeClock()
+	f := NewFinder(
+		WithClient(ts.Client()),
+		WithURLTemplate(fmt.Sprintf("%s/%%s", ts.URL)),
+		WithClock(clock),
+		WithMaxRetryWait(time.Minute),
+	)
+
+	h := sha1.Sum([]byte("melobie"))
+	done := make(chan int64)
+	go func() {
+		n, _ := f.Find(h[:])
+		done <- n
+	}()
+
+	for clock.pending() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	clock.Advance(29 * time.Second)
+	select {
+	case <-done:
+		t.Fatalf("retried early\n")
+	case <-time.After(10 * time.Millisecond):
+	}
+	clock.Advance(time.Second)
+	if n := <-done; n != 401 {
+		t.Errorf("expected 401: %d\n", n)
+	}
+	if got := atomic.LoadInt32(calls); got != 2 {
+		t.Errorf("expected 2 calls: %d\n", got)
+	}
+}
+
+func TestRetryAfterDeadline(t *testing.T) {
+	ts, calls := newThrottlingServer(1, "60")
+	defer ts.Close()
+
+	f := NewFinder(
+		WithClient(ts.Client()),
+		WithURLTemplate(fmt.Sprintf("%s/%%s", ts.URL)),
+	)
+
+	// The wait asked for doesn't fit in the deadline, so there's no point
+	// in waiting at all.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	h := sha1.Sum([]byte("melobie"))
+	start := time.Now()
+	_, err := f.FindContext(ctx, h[:])
+	if Classify(err) != CodeThrottled {
+		t.Errorf("expected %v: %v\n", CodeThrottled, err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("expected no wait: %v\n", d)
+	}
+	if got := atomic.LoadInt32(calls); got != 1 {
+		t.Errorf("expected 1 call: %d\n", got)
+	}
+}
+
+func TestMaxRetryWait(t *testing.T) {
+	testCases := []struct {
+		name       string
+		retryAfter string
+		options    []func(*Finder)
+		exCalls    int32
+	}{
+		{
+			"over the default",
+			"3600",
+			nil,
+			1,
+		},
+		{
+			"just over",
+			"11",
+			[]func(*Finder){WithMaxRetryWait(10 * time.Second)},
+			1,
+		},
+		{
+			"within",
+			"0",
+			[]func(*Finder){WithMaxRetryWait(time.Second)},
+			2,
+		},
+	}
+
+	h := sha1.Sum([]byte("melobie"))
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ts, calls := newThrottlingServer(1, tc.retryAfter)
+			defer ts.Close()
+
+			f := NewFinder(append([]func(*Finder){
+				WithClient(ts.Client()),
+				WithURLTemplate(fmt.Sprintf("%s/%%s", ts.URL)),
+			}, tc.options...)...)
+			start := time.Now()
+			_, err := f.Find(h[:])
+			if d := time.Since(start); d > time.Second {
+				t.Errorf("expected no wait: %v\n", d)
+			}
+			if got := atomic.LoadInt32(calls); got != tc.exCalls {
+				t.Errorf("expected %d calls: %d\n", tc.exCalls, got)
+			}
+			if tc.exCalls > 1 {
+				if err != nil {
+					t.Errorf("unexpected: %v\n", err)
+				}
+				return
+			}
+			var he *HTTPError
+			if !errors.As(err, &he) || he.StatusCode != http.StatusTooManyRequests {
+				t.Errorf("expected a 429 HTTPError: %v\n", err)
+			}
+		})
+	}
+}
+
+func TestMaxRetryWaitBackoff(t *testing.T) {
+	f := NewFinder(
+		WithRetry(RetryPolicy{Base: time.Hour}),
+		WithMaxRetryWait(time.Second),
+	)
+	wait, ok := f.retryWait(context.Background(), 1, &HTTPError{StatusCode: http.StatusServiceUnavailable})
+	if !ok || wait != time.Second {
+		t.Errorf("expected [1s, true]: %v, %t\n", wait, ok)
+	}
+}
@@ -0,0 +1,263 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package s3source provides a hibp.Source reading range bodies from an S3
+// bucket, or any store speaking the S3 API. Google Cloud Storage does, at
+// https://storage.googleapis.com with HMAC keys; so do MinIO and most other
+// object stores.
+//
+// Objects are laid out like hibp.DirSource: one per range, named after its
+// prefix with a ".txt" extension, and NTLM ranges under "ntlm/". Requests
+// are signed with AWS Signature Version 4, using static credentials.
+package s3source
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nelz9999/go-hibp/hibp"
+)
+
+// Defaults for a Source created without the corresponding option.
+const (
+	DefaultRegion  = "us-east-1"
+	DefaultMaxSize = 1 << 20
+)
+
+// defaultClient is used by Sources not given a client of their own. Unlike
+// http.DefaultClient, it has a timeout, so a store that stops responding
+// can't hang a lookup forever.
+var defaultClient = &http.Client{Timeout: hibp.DefaultTimeout}
+
+// emptySHA256 is the hex SHA256 of an empty payload, as sent with GETs.
+const emptySHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// Source is a hibp.Source reading from an S3 bucket. It is safe for
+// concurrent use.
+type Source struct {
+	bucket   string
+	endpoint string
+	region   string
+	prefix   string
+	maxSize  int64
+	conn     *http.Client
+	now      func() time.Time
+
+	accessKey    string
+	secretKey    string
+	sessionToken string
+}
+
+// New returns a Source reading from bucket. The region and credentials are
+// taken from the AWS_REGION, AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and
+// AWS_SESSION_TOKEN environment variables, unless options say otherwise.
+func New(bucket string, options ...func(*Source)) *Source {
+	s := &Source{
+		bucket:       bucket,
+		region:       os.Getenv("AWS_REGION"),
+		maxSize:      DefaultMaxSize,
+		conn:         defaultClient,
+		now:          time.Now,
+		accessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		sessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if s.region == "" {
+		s.region = DefaultRegion
+	}
+	for _, opt := range options {
+		opt(s)
+	}
+	if s.endpoint == "" {
+		s.endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", s.region)
+	}
+	s.endpoint = strings.TrimSuffix(s.endpoint, "/")
+	return s
+}
+
+// WithRegion sets the region requests are signed for.
+func WithRegion(region string) func(*Source) {
+	return func(s *Source) {
+		s.region = region
+	}
+}
+
+// WithEndpoint replaces the regional AWS endpoint with another S3 API,
+// such as "https://storage.googleapis.com". Buckets are addressed in the
+// path, not the host name.
+func WithEndpoint(endpoint string) func(*Source) {
+	return func(s *Source) {
+		s.endpoint = endpoint
+	}
+}
+
+// WithCredentials sets the keys requests are signed with. The session
+// token is only needed for temporary credentials.
+func WithCredentials(accessKey, secretKey, sessionToken string) func(*Source) {
+	return func(s *Source) {
+		s.accessKey = accessKey
+		s.secretKey = secretKey
+		s.sessionToken = sessionToken
+	}
+}
+
+// WithPrefix sets a path within the bucket that objects are under, such as
+// "pwned-passwords/v8/".
+func WithPrefix(prefix string) func(*Source) {
+	return func(s *Source) {
+		s.prefix = prefix
+	}
+}
+
+// WithMaxSize replaces DefaultMaxSize as the largest object that is read.
+func WithMaxSize(n int64) func(*Source) {
+	return func(s *Source) {
+		s.maxSize = n
+	}
+}
+
+// WithClient replaces the default client for requests to the store, which
+// has a timeout of hibp.DefaultTimeout.
+func WithClient(c *http.Client) func(*Source) {
+	return func(s *Source) {
+		s.conn = c
+	}
+}
+
+// String returns the endpoint, bucket and prefix objects are read from,
+// which tells Sources apart in hibp cache keys.
+func (s *Source) String() string {
+	return s.endpoint + "/" + s.bucket + "/" + s.prefix
+}
+
+// Range implements hibp.Source. A response other than 200 OK is returned
+// as a *hibp.HTTPError, as the Finder's own requests are, so 404 Not Found
+// for a missing object is told apart from throttling or a failing store
+// in the same way.
+func (s *Source) Range(ctx context.Context, mode hibp.HashMode, prefix string) ([]byte, error) {
+	key := s.prefix + prefix + ".txt"
+	if mode == hibp.NTLM {
+		key = s.prefix + "ntlm/" + prefix + ".txt"
+	}
+	u := &url.URL{Path: "/" + s.bucket + "/" + key}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.endpoint+u.EscapedPath(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if s.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.sessionToken)
+	}
+	s.sign(req, s.now())
+
+	resp, err := s.conn.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, &hibp.HTTPError{
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+			Prefix:     prefix,
+			Attempts:   1,
+		}
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, s.maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) > s.maxSize {
+		return nil, fmt.Errorf("%w: %s over %d bytes", hibp.ErrBodyTooLarge, key, s.maxSize)
+	}
+	return body, nil
+}
+
+// sign adds the headers of AWS Signature Version 4 to a request with an
+// empty body, covering the host and every header already set on it.
+func (s *Source) sign(req *http.Request, now time.Time) {
+	now = now.UTC()
+	stamp := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", stamp)
+	req.Header.Set("X-Amz-Content-Sha256", emptySHA256)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.TrimSpace(strings.Join(v, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonical strings.Builder
+	for _, k := range names {
+		fmt.Fprintf(&canonical, "%s:%s\n", k, headers[k])
+	}
+	signed := strings.Join(names, ";")
+
+	request := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.Query().Encode(),
+		canonical.String(),
+		signed,
+		emptySHA256,
+	}, "\n")
+	scope := day + "/" + s.region + "/s3/aws4_request"
+	toSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		stamp,
+		scope,
+		hexSHA256(request),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.secretKey), day)
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	sig := hex.EncodeToString(hmacSHA256(key, toSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.accessKey, scope, signed, sig))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func hexSHA256(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
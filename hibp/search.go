@@ -0,0 +1,67 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// WithBinarySearch has bodies read in full binary searched for the
+// suffix, rather than scanned line by line, which speeds up bulk work over
+// cached ranges. The search relies on the body being sorted by suffix, as
+// bodies from the API and the official downloader are; in a body that
+// isn't, it misses lines that are there, and the lookup wrongly returns a
+// count of zero. Streamed bodies (see rangeCount) are always scanned, and
+// a RangeIndex sorts the ranges it keeps itself.
+func WithBinarySearch() func(f *Finder) {
+	return func(f *Finder) {
+		f.bisect = true
+	}
+}
+
+// searchSuffix is findSuffix for a body sorted by suffix, by binary search
+// over its lines. Only the lines it looks at are checked against maxLine.
+func searchSuffix(suffix, body []byte, maxLine int) ([]byte, error) {
+	body = bytes.TrimSpace(body)
+	lo, hi := 0, len(body)
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		start := lo + bytes.LastIndexByte(body[lo:mid], '\n') + 1
+		end := mid + bytes.IndexByte(body[mid:hi], '\n')
+		if end < mid {
+			end = hi
+		}
+		line := bytes.TrimRight(body[start:end], "\r")
+		if len(line) > maxLine {
+			return nil, fmt.Errorf("%w: over %d bytes", ErrLineTooLong, maxLine)
+		}
+		if bytes.HasPrefix(line, suffix) {
+			return line, nil
+		}
+		if bytes.Compare(line, suffix) < 0 {
+			lo = end + 1
+		} else {
+			hi = start
+		}
+	}
+	return nil, nil
+}
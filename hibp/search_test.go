@@ -0,0 +1,166 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSearchSuffix(t *testing.T) {
+	var lines []string
+	for i := 0; i < 500; i++ {
+		lines = append(lines, fmt.Sprintf("%035X:%d", i*4+2, i))
+	}
+	sorted := strings.Join(lines, "\n")
+
+	testCases := []struct {
+		name string
+		body string
+	}{
+		{
+			"lf",
+			sorted,
+		},
+		{
+			"crlf",
+			strings.Join(lines, "\r\n"),
+		},
+		{
+			"surrounding whitespace",
+			"\n" + sorted + "\r\n\n",
+		},
+		{
+			"one line",
+			lines[0],
+		},
+		{
+			"empty",
+			"",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			body := []byte(tc.body)
+			// Every line, and every gap between, before and after them.
+			for i := 0; i < 2002; i++ {
+				suffix := []byte(fmt.Sprintf("%035X", i))
+				exp, err := findSuffix(suffix, bytes.NewReader(body), DefaultMaxLineLength)
+				if err != nil {
+					t.Fatalf("unexpected: %v\n", err)
+				}
+				got, err := searchSuffix(suffix, body, DefaultMaxLineLength)
+				if err != nil {
+					t.Fatalf("unexpected: %v\n", err)
+				}
+				if !bytes.Equal(exp, got) {
+					t.Fatalf("expected %q: %q\n", exp, got)
+				}
+			}
+		})
+	}
+
+	long := strings.Repeat("A", 300) + "\n" + sorted
+	if _, err := searchSuffix([]byte(strings.Repeat("0", 35)), []byte(long), DefaultMaxLineLength); !errors.Is(err, ErrLineTooLong) {
+		t.Errorf("expected %v: %v\n", ErrLineTooLong, err)
+	}
+}
+
+func TestWithBinarySearch(t *testing.T) {
+	// The line for melobie sorts first, but comes last.
+	unsorted := strings.Repeat("AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA:23\n", 10) +
+		"012A7CA357541F0AC487871FEEC1891C49C:401\n"
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(unsorted))
+	}))
+	defer ts.Close()
+
+	testCases := []struct {
+		name   string
+		search bool
+		exp    int64
+	}{
+		{
+			"scan",
+			false,
+			401,
+		},
+		{
+			"search misses in an unsorted body",
+			true,
+			0,
+		},
+	}
+
+	h := sha1.Sum([]byte("melobie"))
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := []func(*Finder){
+				WithClient(ts.Client()),
+				WithURLTemplate(fmt.Sprintf("%s/%%s", ts.URL)),
+				WithCache(NewMemoryCache(10, time.Hour)),
+			}
+			if tc.search {
+				opts = append(opts, WithBinarySearch())
+			}
+			n, err := NewFinder(opts...).Find(h[:])
+			if n != tc.exp || err != nil {
+				t.Errorf("expected [%d, nil]: %d, %v\n", tc.exp, n, err)
+			}
+		})
+	}
+}
+
+func BenchmarkSearchSuffix(b *testing.B) {
+	// About the size of a SHA1 range from the API.
+	var lines []string
+	for i := 0; i < 900; i++ {
+		lines = append(lines, fmt.Sprintf("%035X:%d", i*4+2, i))
+	}
+	body := []byte(strings.Join(lines, "\r\n"))
+	suffixes := [][]byte{
+		[]byte(fmt.Sprintf("%035X", 2)),
+		[]byte(fmt.Sprintf("%035X", 1802)),
+		[]byte(fmt.Sprintf("%035X", 3598)),
+		[]byte(fmt.Sprintf("%035X", 3599)),
+	}
+
+	b.Run("binary", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			searchSuffix(suffixes[i%len(suffixes)], body, DefaultMaxLineLength)
+		}
+	})
+	b.Run("linear", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			findSuffix(suffixes[i%len(suffixes)], bytes.NewReader(body), DefaultMaxLineLength)
+		}
+	})
+}
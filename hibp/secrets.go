@@ -0,0 +1,106 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// APIKeyFromEnv returns an APIKeyFunc reading the key from the named
+// environment variable. The variable is read on every call, and it is an
+// error for it to be empty or unset.
+func APIKeyFromEnv(name string) APIKeyFunc {
+	return func(context.Context) (string, error) {
+		key := strings.TrimSpace(os.Getenv(name))
+		if key == "" {
+			return "", fmt.Errorf("hibp: $%s is not set", name)
+		}
+		return key, nil
+	}
+}
+
+// APIKeyFromFile returns an APIKeyFunc reading the key from the file at
+// path, ignoring surrounding whitespace. The file is read again whenever
+// its size or modification time changes, which picks up keys rotated in
+// place as well as mounted secrets swapped by symlink.
+func APIKeyFromFile(path string) APIKeyFunc {
+	var mu sync.Mutex
+	var key string
+	var seen os.FileInfo
+	return func(context.Context) (string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		fi, err := os.Stat(path)
+		if err != nil {
+			return "", fmt.Errorf("hibp: API key file: %w", err)
+		}
+		if seen != nil && fi.Size() == seen.Size() && fi.ModTime().Equal(seen.ModTime()) {
+			return key, nil
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("hibp: API key file: %w", err)
+		}
+		k := strings.TrimSpace(string(b))
+		if k == "" {
+			return "", fmt.Errorf("hibp: API key file %s is empty", path)
+		}
+		key, seen = k, fi
+		return key, nil
+	}
+}
+
+// APIKeyFromCommand returns an APIKeyFunc that runs the named command and
+// uses what it prints to stdout, ignoring surrounding whitespace, as the
+// key. The key is reused for ttl before the command is run again; a failed
+// run is not cached.
+func APIKeyFromCommand(ttl time.Duration, name string, arg ...string) APIKeyFunc {
+	return apiKeyFromCommand(systemClock{}, ttl, name, arg...)
+}
+
+func apiKeyFromCommand(clock Clock, ttl time.Duration, name string, arg ...string) APIKeyFunc {
+	var mu sync.Mutex
+	var key string
+	var expires time.Time
+	return func(ctx context.Context) (string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if key != "" && clock.Now().Before(expires) {
+			return key, nil
+		}
+		out, err := exec.CommandContext(ctx, name, arg...).Output()
+		if err != nil {
+			return "", fmt.Errorf("hibp: API key command: %w", err)
+		}
+		k := strings.TrimSpace(string(out))
+		if k == "" {
+			return "", fmt.Errorf("hibp: API key command %s printed nothing", name)
+		}
+		key, expires = k, clock.Now().Add(ttl)
+		return key, nil
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAPIKeyFromEnv(t *testing.T) {
+	fn := APIKeyFromEnv("HIBP_TEST_API_KEY")
+
+	t.Setenv("HIBP_TEST_API_KEY", "")
+	if _, err := fn(context.Background()); err == nil {
+		t.Errorf("expected error")
+	}
+
+	t.Setenv("HIBP_TEST_API_KEY", " s3cret\n")
+	key, err := fn(context.Background())
+	if key != "s3cret" || err != nil {
+		t.Errorf("expected [s3cret, nil]: %q, %v\n", key, err)
+	}
+}
+
+func TestAPIKeyFromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "api-key")
+	fn := APIKeyFromFile(path)
+
+	if _, err := fn(context.Background()); err == nil {
+		t.Errorf("expected error for missing file")
+	}
+
+	write := func(content string, mtime time.Time) {
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatalf("unexpected: %v\n", err)
+		}
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatalf("unexpected: %v\n", err)
+		}
+	}
+	then := time.Now().Add(-time.Hour)
+
+	write("first\n", then)
+	key, err := fn(context.Background())
+	if key != "first" || err != nil {
+		t.Errorf("expected [first, nil]: %q, %v\n", key, err)
+	}
+
+	write("rotate\n", then.Add(time.Minute))
+	key, err = fn(context.Background())
+	if key != "rotate" || err != nil {
+		t.Errorf("expected [rotate, nil]: %q, %v\n", key, err)
+	}
+
+	write("  \n", then.Add(2*time.Minute))
+	if _, err = fn(context.Background()); err == nil {
+		t.Errorf("expected error for empty file")
+	}
+}
+
+func TestAPIKeyFromCommand(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "key")
+	if err := os.WriteFile(src, []byte("first\n"), 0600); err != nil {
+		t.Fatalf("unexpected: %v\n", err)
+	}
+	clock := newFakeClock()
+	fn := apiKeyFromCommand(clock, time.Minute, "cat", src)
+
+	key, err := fn(context.Background())
+	if key != "first" || err != nil {
+		t.Skipf("cat unavailable? %q, %v\n", key, err)
+	}
+
+	if err := os.WriteFile(src, []byte("rotated\n"), 0600); err != nil {
+		t.Fatalf("unexpected: %v\n", err)
+	}
+	key, _ = fn(context.Background())
+	if key != "first" {
+		t.Errorf("expected cached key: %q\n", key)
+	}
+
+	clock.Advance(time.Minute)
+	key, _ = fn(context.Background())
+	if key != "rotated" {
+		t.Errorf("expected rotated key: %q\n", key)
+	}
+
+	fn = APIKeyFromCommand(time.Minute, filepath.Join(dir, "missing"))
+	if _, err := fn(context.Background()); err == nil {
+		t.Errorf("expected error")
+	}
+}
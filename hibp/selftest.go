@@ -0,0 +1,69 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+)
+
+// selfTestPassword is the best-known entry in the corpus; every complete
+// copy of the data reports it as having been seen in a huge number of breaches.
+const selfTestPassword = "password"
+
+// selfTestMinCount is a deliberately conservative floor for the count of
+// selfTestPassword, so that older mirrors of the data still pass.
+const selfTestMinCount = 100000
+
+// SelfTest verifies that the Finder can reach its configured source and make
+// sense of what comes back. It looks up a well-known password, checks that it
+// reports a large non-zero count, and that the matched line survives being
+// parsed and formatted again unchanged.
+//
+// It is intended for service startup checks and smoke tests; a nil return
+// means lookups through this Finder are expected to work.
+func (f *Finder) SelfTest(ctx context.Context) error {
+	full := []byte(fmt.Sprintf("%X", f.hashPassword(selfTestPassword)))
+	body, err := f.fetchPrefix(ctx, f.mode, full[:prefixSize])
+	if err != nil {
+		return fmt.Errorf("hibp: self-test: %v", err)
+	}
+
+	line, err := findSuffix(full[prefixSize:], bytes.NewReader(body), f.maxLineLength())
+	if err != nil {
+		return fmt.Errorf("hibp: self-test: %v", err)
+	}
+	if len(line) == 0 {
+		return fmt.Errorf("hibp: self-test: no entry for well-known hash %s", full)
+	}
+	n, err := parseCount(line)
+	if err != nil {
+		return fmt.Errorf("hibp: self-test: %v", err)
+	}
+	if n < selfTestMinCount {
+		return fmt.Errorf("hibp: self-test: count %d for well-known hash is below %d", n, selfTestMinCount)
+	}
+	if rt := fmt.Sprintf("%s:%d", full[prefixSize:], n); rt != string(line) {
+		return fmt.Errorf("hibp: self-test: round trip mismatch: %q != %q", rt, line)
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSelfTest(t *testing.T) {
+	testCases := []struct {
+		name string
+		body string
+		xErr string
+	}{
+		{
+			"healthy",
+			"003D68EB55068C33ACE09247EE4C639306B:3\n1E4C9B93F3F0682250B6CF8331B7EE68FD8:9545824\n",
+			"",
+		},
+		{
+			"missing",
+			"003D68EB55068C33ACE09247EE4C639306B:3\n",
+			"no entry",
+		},
+		{
+			"too small",
+			"1E4C9B93F3F0682250B6CF8331B7EE68FD8:12\n",
+			"below",
+		},
+		{
+			"leading zeros",
+			"1E4C9B93F3F0682250B6CF8331B7EE68FD8:09545824\n",
+			"round trip",
+		},
+		{
+			"garbage",
+			"1E4C9B93F3F0682250B6CF8331B7EE68FD8:lots\n",
+			"invalid count",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if !strings.HasSuffix(r.URL.Path, "/5BAA6") {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				w.Write([]byte(tc.body))
+			}))
+			defer ts.Close()
+
+			f := NewFinder(
+				WithClient(ts.Client()),
+				WithURLTemplate(fmt.Sprintf("%s/%%s", ts.URL)),
+			)
+			err := f.SelfTest(context.Background())
+			if tc.xErr == "" {
+				if err != nil {
+					t.Errorf("unexpected: %v\n", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.xErr) {
+				t.Errorf("expected %q: %v\n", tc.xErr, err)
+			}
+		})
+	}
+}
+
+func TestSelfTestCanceled(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request: %s\n", r.URL)
+	}))
+	defer ts.Close()
+
+	f := NewFinder(
+		WithClient(ts.Client()),
+		WithURLTemplate(fmt.Sprintf("%s/%%s", ts.URL)),
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := f.SelfTest(ctx); err == nil {
+		t.Errorf("expected error")
+	}
+}
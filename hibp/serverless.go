@@ -0,0 +1,106 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"crypto/rand"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Settings of the shared client and cache used by WithServerless.
+const (
+	// ServerlessTimeout bounds each request, well inside the time limits
+	// functions usually run under.
+	ServerlessTimeout = 10 * time.Second
+	// ServerlessCacheSize is the number of ranges kept, about 2MB.
+	ServerlessCacheSize = 64
+	// ServerlessCacheTTL is how long ranges are kept when the response
+	// doesn't say.
+	ServerlessCacheTTL = time.Hour
+)
+
+var (
+	serverlessOnce   sync.Once
+	serverlessClient *http.Client
+	serverlessCache  *MemoryCache
+	serverlessSecret []byte
+)
+
+// WithServerless sets a Finder up for short-lived serverless functions,
+// which typically create one per invocation, in a process that is reused
+// for a while and then frozen or thrown away.
+//
+// Unless other options say otherwise, such Finders share a package-level
+// http.Client, so that warm invocations reuse the connections made by
+// earlier ones, and a small package-level MemoryCache (ServerlessCacheSize
+// ranges), allocated on first use so cold starts don't pay for it. As the
+// cache is shared whatever the backend, Finders using this option should
+// all use the same one. Nothing in the Finder runs in the background
+// between lookups either way.
+//
+// WithDNSCache and WithIPPreference give a Finder a transport of its own,
+// and so don't mix well with this option.
+func WithServerless() func(f *Finder) {
+	return func(f *Finder) {
+		f.serverless = true
+	}
+}
+
+// serverlessDefaults fills in what WithServerless shares between Finders,
+// where other options haven't.
+func (f *Finder) serverlessDefaults() {
+	serverlessOnce.Do(func() {
+		serverlessClient = &http.Client{
+			Timeout: ServerlessTimeout,
+			Transport: &http.Transport{
+				Proxy: http.ProxyFromEnvironment,
+				DialContext: (&net.Dialer{
+					Timeout:   5 * time.Second,
+					KeepAlive: 30 * time.Second,
+				}).DialContext,
+				ForceAttemptHTTP2:     true,
+				MaxIdleConns:          16,
+				MaxIdleConnsPerHost:   16,
+				IdleConnTimeout:       90 * time.Second,
+				TLSHandshakeTimeout:   5 * time.Second,
+				ExpectContinueTimeout: time.Second,
+			},
+		}
+		serverlessCache = NewMemoryCache(ServerlessCacheSize, ServerlessCacheTTL)
+		serverlessSecret = make([]byte, cacheKeySize)
+		if _, err := rand.Read(serverlessSecret); err != nil {
+			panic("hibp: generating cache secret: " + err.Error())
+		}
+	})
+	if f.conn == nil {
+		f.conn = serverlessClient
+	}
+	if f.cache == nil && f.source == nil {
+		f.cache = serverlessCache
+		if len(f.cacheSecret) == 0 {
+			// The Finders sharing the cache must agree on its keys.
+			f.cacheSecret = serverlessSecret
+		}
+	}
+}
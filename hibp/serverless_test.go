@@ -0,0 +1,67 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWithServerless(t *testing.T) {
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Write([]byte(data))
+	}))
+	defer ts.Close()
+	tmpl := WithURLTemplate(fmt.Sprintf("%s/%%s", ts.URL))
+
+	// One Finder per invocation, as a function handler would.
+	for i := 0; i < 3; i++ {
+		f := NewFinder(tmpl, WithServerless())
+		if f.conn != serverlessClient || f.cache != serverlessCache {
+			t.Fatalf("expected the shared client and cache\n")
+		}
+		if n, err := f.FindPassword("melobie"); n != 401 || err != nil {
+			t.Errorf("expected [401, nil]: %d, %v\n", n, err)
+		}
+	}
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Errorf("expected 1 call: %d\n", got)
+	}
+	if serverlessClient.Timeout != ServerlessTimeout {
+		t.Errorf("expected %v: %v\n", ServerlessTimeout, serverlessClient.Timeout)
+	}
+
+	// Other options win, whatever their order.
+	c := NewMemoryCache(1, time.Minute)
+	f := NewFinder(WithServerless(), WithClient(ts.Client()), WithCache(c))
+	if f.conn != ts.Client() || f.cache != c {
+		t.Errorf("expected the given client and cache\n")
+	}
+	if f := NewFinder(WithServerless(), WithSource(DirSource("."))); f.cache != nil {
+		t.Errorf("expected no cache for a Source\n")
+	}
+}
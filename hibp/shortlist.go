@@ -0,0 +1,86 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io"
+)
+
+// Shortlist holds the counts of the most breached hashes, so lookups of
+// the handful of passwords that dominate real traffic are answered locally.
+type Shortlist struct {
+	counts map[string]int64
+}
+
+// LoadShortlist reads a Shortlist from lines of HASH:COUNT, where HASH is a
+// full hex encoded SHA1 or NTLM hash. This is the format of the downloadable
+// corpus ordered by prevalence, so its first lines make a Shortlist as they
+// are. Blank lines are skipped.
+func LoadShortlist(r io.Reader) (*Shortlist, error) {
+	s := &Shortlist{counts: map[string]int64{}}
+	scanner := bufio.NewScanner(r)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		i := bytes.Index(line, delim)
+		if i < 0 {
+			return nil, fmt.Errorf("%w: line %d: %q", ErrMalformedLine, lineNo, line)
+		}
+		sum := make([]byte, hex.DecodedLen(i))
+		if _, err := hex.Decode(sum, line[:i]); err != nil {
+			return nil, fmt.Errorf("%w: line %d: %v", ErrMalformedLine, lineNo, err)
+		}
+		n, err := parseCount(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		s.counts[string(sum)] = n
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Lookup returns the count for sum, and whether it is on the Shortlist.
+func (s *Shortlist) Lookup(sum []byte) (int64, bool) {
+	n, ok := s.counts[string(sum)]
+	return n, ok
+}
+
+// Len returns the number of hashes on the Shortlist.
+func (s *Shortlist) Len() int {
+	return len(s.counts)
+}
+
+// WithShortlist has Find answer from the given Shortlist, without making a
+// request, for any hash on it.
+func WithShortlist(s *Shortlist) func(f *Finder) {
+	return func(f *Finder) {
+		f.shortlist = s
+	}
+}
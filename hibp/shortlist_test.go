@@ -0,0 +1,108 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const shortlistContent = `
+7C4A8D09CA3762AF61E59520943DC26494F8941B:37359195
+5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8:9545824
+
+8846F7EAEE8FB117AD06BDD830B7586C:2597813
+`
+
+func TestShortlist(t *testing.T) {
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte(data))
+	}))
+	defer ts.Close()
+
+	s, err := LoadShortlist(strings.NewReader(shortlistContent))
+	if err != nil {
+		t.Fatalf("unexpected: %v\n", err)
+	}
+	if s.Len() != 3 {
+		t.Errorf("expected 3: %d\n", s.Len())
+	}
+
+	f := NewFinder(
+		WithClient(ts.Client()),
+		WithURLTemplate(fmt.Sprintf("%s/%%s", ts.URL)),
+		WithShortlist(s),
+	)
+	n, err := f.FindPassword("123456")
+	if n != 37359195 || err != nil {
+		t.Errorf("expected [37359195, nil]: %d, %v\n", n, err)
+	}
+	if requests != 0 {
+		t.Errorf("expected no requests: %d\n", requests)
+	}
+
+	n, err = f.FindPassword("lauragpe")
+	if n != 229 || err != nil {
+		t.Errorf("expected [229, nil]: %d, %v\n", n, err)
+	}
+	if requests != 1 {
+		t.Errorf("expected 1 request: %d\n", requests)
+	}
+
+	f = NewFinder(WithShortlist(s), WithHashMode(NTLM))
+	n, err = f.FindPassword("password")
+	if n != 2597813 || err != nil {
+		t.Errorf("expected [2597813, nil]: %d, %v\n", n, err)
+	}
+
+	h := sha1.Sum([]byte("password"))
+	if n, ok := s.Lookup(h[:]); !ok || n != 9545824 {
+		t.Errorf("expected [9545824, true]: %d, %t\n", n, ok)
+	}
+}
+
+func TestLoadShortlistErrors(t *testing.T) {
+	testCases := []struct {
+		name    string
+		content string
+		xErr    error
+	}{
+		{"no count", "7C4A8D09CA3762AF61E59520943DC26494F8941B\n", ErrMalformedLine},
+		{"not hex", "7C4A8D09CA3762AF61E59520943DC26494F8941Z:1\n", ErrMalformedLine},
+		{"bad count", "7C4A8D09CA3762AF61E59520943DC26494F8941B:-1\n", ErrInvalidCount},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := LoadShortlist(strings.NewReader(tc.content))
+			if !errors.Is(err, tc.xErr) {
+				t.Errorf("expected %v: %v\n", tc.xErr, err)
+			}
+		})
+	}
+}
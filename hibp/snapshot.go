@@ -0,0 +1,129 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SnapshotCurrent is the name of the file in a SnapshotDir naming the
+// current snapshot.
+const SnapshotCurrent = "CURRENT"
+
+// SnapshotDir is a directory holding versioned copies of the corpus, each
+// a subdirectory laid out as DirSource expects, along with a file,
+// SnapshotCurrent, holding the name of the current one. An updater builds
+// the next snapshot alongside the others and then calls Publish, which
+// replaces SnapshotCurrent atomically, so readers see either the old
+// snapshot or the new one, never a mix.
+//
+// A SnapshotDir is itself a Source that follows SnapshotCurrent, and so
+// moves to a new snapshot as soon as it is published. A job that must see
+// one version of the corpus throughout, such as a bulk audit, should pin
+// one with Current instead:
+//
+//	src, err := hibp.SnapshotDir("/var/lib/hibp").Current()
+//	if err != nil {
+//		return err
+//	}
+//	f := hibp.NewFinder(hibp.WithSource(src))
+//
+// Nothing here removes old snapshots; that is up to the updater, once no
+// job can be using them.
+type SnapshotDir string
+
+// Current returns the current snapshot, as a DirSource that keeps reading
+// from it after another is published.
+func (s SnapshotDir) Current() (DirSource, error) {
+	b, err := ioutil.ReadFile(filepath.Join(string(s), SnapshotCurrent))
+	if err != nil {
+		return "", err
+	}
+	name := strings.TrimSpace(string(b))
+	if err := checkSnapshotName(name); err != nil {
+		return "", err
+	}
+	return DirSource(filepath.Join(string(s), name)), nil
+}
+
+// Publish makes the snapshot called name, which must already be a
+// subdirectory of s, the current one.
+func (s SnapshotDir) Publish(name string) error {
+	if err := checkSnapshotName(name); err != nil {
+		return err
+	}
+	fi, err := os.Stat(filepath.Join(string(s), name))
+	if err != nil {
+		return err
+	}
+	if !fi.IsDir() {
+		return fmt.Errorf("hibp: snapshot %q is not a directory", name)
+	}
+
+	tmp, err := ioutil.TempFile(string(s), SnapshotCurrent+".tmp*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.WriteString(name + "\n")
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0o644)
+	}
+	if err == nil {
+		err = replaceFile(tmp.Name(), filepath.Join(string(s), SnapshotCurrent))
+	}
+	if err != nil {
+		removeFile(tmp.Name())
+	}
+	return err
+}
+
+// String returns the directory, which tells SnapshotDirs apart in cache
+// keys.
+func (s SnapshotDir) String() string {
+	return string(s)
+}
+
+// Range implements Source, reading from whichever snapshot is current.
+func (s SnapshotDir) Range(ctx context.Context, mode HashMode, prefix string) ([]byte, error) {
+	src, err := s.Current()
+	if err != nil {
+		return nil, err
+	}
+	return src.Range(ctx, mode, prefix)
+}
+
+// checkSnapshotName returns an error unless name names a directory
+// directly inside a SnapshotDir.
+func checkSnapshotName(name string) error {
+	if name == "" || name == "." || name == ".." || name == SnapshotCurrent ||
+		strings.ContainsAny(name, `/\`) || filepath.Base(name) != name {
+		return fmt.Errorf("hibp: invalid snapshot name %q", name)
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeSnapshot creates a snapshot called name in root, with a range for
+// the prefix 21BD1 holding body.
+func writeSnapshot(t *testing.T, root, name, body string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Join(root, name), 0o755); err != nil {
+		t.Fatalf("unexpected: %v\n", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(root, name, "21BD1.txt"), []byte(body), 0o644); err != nil {
+		t.Fatalf("unexpected: %v\n", err)
+	}
+}
+
+func TestSnapshotDir(t *testing.T) {
+	root := t.TempDir()
+	snap := SnapshotDir(root)
+
+	if _, err := snap.Current(); !os.IsNotExist(err) {
+		t.Errorf("expected nothing current yet: %v\n", err)
+	}
+
+	writeSnapshot(t, root, "2017-12-01", data)
+	if err := snap.Publish("2017-12-01"); err != nil {
+		t.Fatalf("unexpected: %v\n", err)
+	}
+	pinned, err := snap.Current()
+	if err != nil {
+		t.Fatalf("unexpected: %v\n", err)
+	}
+
+	// The next snapshot counts melobie once more.
+	writeSnapshot(t, root, "2017-12-02", "012A7CA357541F0AC487871FEEC1891C49C:402\n")
+	if err := snap.Publish("2017-12-02"); err != nil {
+		t.Fatalf("unexpected: %v\n", err)
+	}
+
+	testCases := []struct {
+		name string
+		src  Source
+		exp  int64
+	}{
+		{
+			"pinned",
+			pinned,
+			401,
+		},
+		{
+			"following",
+			snap,
+			402,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			f := NewFinder(WithSource(tc.src))
+			n, err := f.FindPassword("melobie")
+			if err != nil {
+				t.Fatalf("unexpected: %v\n", err)
+			}
+			if n != tc.exp {
+				t.Errorf("expected %d: %d\n", tc.exp, n)
+			}
+		})
+	}
+
+	// Nothing but the snapshots and the pointer is left behind.
+	names, _ := ioutil.ReadDir(root)
+	if len(names) != 3 {
+		t.Errorf("expected two snapshots and %s: %v\n", SnapshotCurrent, names)
+	}
+}
+
+func TestSnapshotDirPublishErrors(t *testing.T) {
+	root := t.TempDir()
+	snap := SnapshotDir(root)
+	ioutil.WriteFile(filepath.Join(root, "file"), nil, 0o644)
+
+	for _, name := range []string{"", ".", "..", "../elsewhere", `a\b`, SnapshotCurrent, "missing", "file"} {
+		t.Run(name, func(t *testing.T) {
+			if err := snap.Publish(name); err == nil {
+				t.Errorf("expected an error\n")
+			}
+		})
+	}
+	if _, err := os.Stat(filepath.Join(root, SnapshotCurrent)); !os.IsNotExist(err) {
+		t.Errorf("expected nothing published: %v\n", err)
+	}
+}
+
+func TestSnapshotDirBadCurrent(t *testing.T) {
+	root := t.TempDir()
+	ioutil.WriteFile(filepath.Join(root, SnapshotCurrent), []byte("../../etc\n"), 0o644)
+	if _, err := SnapshotDir(root).Current(); err == nil {
+		t.Errorf("expected an error\n")
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+)
+
+// A Source supplies range bodies in place of the backend's HTTP API, for
+// Finders reading a copy of the corpus staged somewhere else, such as on
+// local disk (DirSource) or in object storage (see the s3source package).
+// Everything else about lookups is unchanged: bodies are cached, retried
+// and parsed as if they had come from the API.
+//
+// A Source is used by all lookups through a Finder, so it must be safe for
+// concurrent use. Cached ranges are keyed by the type of the Source, and
+// by its String if it implements fmt.Stringer, so Sources of one type that
+// share a cache should implement it.
+type Source interface {
+	// Range returns the body of the range for prefix, five upper case hex
+	// digits, for hashes of the given mode, in the format of the API.
+	Range(ctx context.Context, mode HashMode, prefix string) ([]byte, error)
+}
+
+// WithSource has range bodies read from s rather than requested from the
+// API. Options that only make sense for HTTP, such as WithRateLimit or
+// WithAPIKey, don't apply to them.
+func WithSource(s Source) func(f *Finder) {
+	return func(f *Finder) {
+		f.source = s
+	}
+}
+
+// DirSource is a Source reading from a directory with one file per range,
+// named after its prefix with a ".txt" extension, as written by the
+// official downloader. Ranges of NTLM hashes are read from the "ntlm"
+// subdirectory.
+type DirSource string
+
+// String returns the directory, which tells DirSources apart in cache
+// keys.
+func (d DirSource) String() string {
+	return string(d)
+}
+
+// Range implements Source. A prefix that isn't five hex digits is an
+// error, so it can't name a file outside the directory.
+func (d DirSource) Range(ctx context.Context, mode HashMode, prefix string) ([]byte, error) {
+	if len(prefix) != prefixSize || !isHex([]byte(prefix)) {
+		return nil, fmt.Errorf("%w: prefix %q", ErrInvalidHex, prefix)
+	}
+	dir := string(d)
+	if mode == NTLM {
+		dir = filepath.Join(dir, "ntlm")
+	}
+	return ioutil.ReadFile(filepath.Join(dir, prefix+".txt"))
+}
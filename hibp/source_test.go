@@ -0,0 +1,126 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type countingSource struct {
+	DirSource
+	calls int
+}
+
+func (s *countingSource) Range(ctx context.Context, mode HashMode, prefix string) ([]byte, error) {
+	s.calls++
+	return s.DirSource.Range(ctx, mode, prefix)
+}
+
+func TestWithSource(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hibp-source")
+	if err != nil {
+		t.Fatalf("unexpected: %v\n", err)
+	}
+	defer os.RemoveAll(dir)
+	os.Mkdir(filepath.Join(dir, "ntlm"), 0755)
+	files := map[string]string{
+		"21BD1.txt":                        data,
+		filepath.Join("ntlm", "8846F.txt"): "7EAEE8FB117AD06BDD830B7586C:52\n",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("unexpected: %v\n", err)
+		}
+	}
+
+	testCases := []struct {
+		name  string
+		mode  HashMode
+		pwd   string
+		exp   int64
+		exErr bool
+	}{
+		{
+			"sha1",
+			SHA1,
+			"melobie",
+			401,
+			false,
+		},
+		{
+			"missing range",
+			SHA1,
+			"password",
+			0,
+			true,
+		},
+		{
+			"ntlm",
+			NTLM,
+			"password",
+			52,
+			false,
+		},
+		{
+			"ntlm missing range",
+			NTLM,
+			"melobie",
+			0,
+			true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			src := &countingSource{DirSource: DirSource(dir)}
+			f := NewFinder(WithSource(src), WithHashMode(tc.mode), WithCache(NewMemoryCache(10, time.Hour)))
+			for i := 0; i < 2; i++ {
+				n, err := f.FindPassword(tc.pwd)
+				if n != tc.exp {
+					t.Errorf("expected %d: %d\n", tc.exp, n)
+				}
+				if tc.exErr != errors.Is(err, os.ErrNotExist) {
+					t.Errorf("expected not exist %t: %v\n", tc.exErr, err)
+				}
+			}
+			if exp := 1; !tc.exErr && src.calls != exp {
+				t.Errorf("expected %d calls: %d\n", exp, src.calls)
+			}
+		})
+	}
+}
+
+func TestDirSourcePrefix(t *testing.T) {
+	for _, prefix := range []string{"", "21BD", "21BD1A", "../21", "21BD\\", "..\\x"} {
+		t.Run(prefix, func(t *testing.T) {
+			_, err := DirSource(os.TempDir()).Range(context.Background(), SHA1, prefix)
+			if !errors.Is(err, ErrInvalidHex) {
+				t.Errorf("expected %v: %v\n", ErrInvalidHex, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,160 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// sniffSize is how much of a streamed body checkContent gets to see.
+const sniffSize = 512
+
+// rangeCount returns the count for suffix in the range for prefix, and
+// whether the range came from the cache. A failure to retrieve the range
+// is returned as err, and a problem with its content as bad.
+//
+// With a RangeIndex, a body retrieved here is parsed and added to it, for
+// lookup to find (see indexCount). Without a Cache, a Source
+// or a RangeIndex there's no use for the body past this lookup, so rather
+// than reading it in full, it's scanned as it arrives, and only up to the
+// line for suffix, unless other lookups in the same range are waiting to
+// share it; see streamCount.
+func (f *Finder) rangeCount(ctx context.Context, mode HashMode, prefix, suffix []byte) (n int64, hit bool, bad, err error) {
+	if f.cache == nil && f.source == nil && f.index == nil {
+		n, bad, err = f.streamCount(ctx, mode, prefix, suffix)
+		return n, false, bad, err
+	}
+	body, ttl, hit, err := f.rangeBody(ctx, mode, prefix)
+	if err != nil {
+		return 0, false, nil, err
+	}
+	if f.index != nil && ttl >= 0 {
+		if r, err := f.parseRange(mode, body); err == nil {
+			f.index.put(f.clock.Now(), f.indexName, indexKey(mode, prefix), r, ttl)
+			return r.count(suffix), hit, nil, nil
+		}
+	}
+	n, bad = f.count(suffix, body)
+	return n, hit, bad, nil
+}
+
+// streamCount is rangeCount, scanning the response body for suffix as it
+// is read. Concurrent lookups in the same range still share one request:
+// the first makes it, keeping what it reads, and once it has its own line
+// it either leaves the flight, and stops reading, if no one has joined,
+// or reads the rest of the body for those that have, who then search it
+// as fetchShared would have them do.
+func (f *Finder) streamCount(ctx context.Context, mode HashMode, prefix, suffix []byte) (n int64, bad, err error) {
+	key := f.cacheKey(mode, prefix)
+	c, leader := f.flights.join(key)
+	for !leader {
+		if err := f.flights.wait(ctx, c); err != nil {
+			return 0, nil, err
+		}
+		if !abandoned(c.err) || ctx.Err() != nil {
+			if c.err != nil {
+				return 0, nil, c.err
+			}
+			n, bad = f.count(suffix, c.body)
+			return n, bad, nil
+		}
+		// The lookup making the request gave up on it; make it again.
+		c, leader = f.flights.join(key)
+	}
+
+	var shared []byte
+	err = f.guarded(ctx, func() error {
+		return f.get(ctx, mode, prefix, nil, func(resp *http.Response) error {
+			max := f.maxBodySize()
+			// Read what's left, so the connection can be reused.
+			defer io.Copy(ioutil.Discard, io.LimitReader(resp.Body, max))
+
+			var kept bytes.Buffer
+			body := &cappedReader{r: io.TeeReader(resp.Body, &kept), max: max}
+			r := bufio.NewReaderSize(body, sniffSize)
+			head, err := r.Peek(sniffSize)
+			if err != nil && err != io.EOF {
+				return err
+			}
+			if err := checkContent(resp.Header.Get("Content-Type"), head); err != nil {
+				return err
+			}
+			line, err := findSuffix(suffix, r, f.maxLineLength())
+			switch {
+			case errors.Is(err, ErrLineTooLong):
+				n, bad = 0, err
+			case err != nil:
+				return err
+			case body.err != nil:
+				// The scanner hands out what it has when reading fails,
+				// and the line could have been cut short.
+				return body.err
+			case len(line) == 0 || (f.padding && isPadding(line)):
+				n, bad = 0, nil
+			default:
+				n, bad = parseCount(line)
+			}
+			if f.flights.leave(key, c) {
+				return nil
+			}
+			// Others are waiting for the range; everything read so far,
+			// the scanner's buffer included, is in kept.
+			if _, err := io.Copy(ioutil.Discard, body); err != nil {
+				return err
+			}
+			shared = kept.Bytes()
+			return nil
+		})
+	})
+	f.flights.finish(key, c, shared, 0, err)
+	if err != nil {
+		return 0, nil, err
+	}
+	return n, bad, nil
+}
+
+// cappedReader reads from r, failing with ErrBodyTooLarge once more than
+// max bytes have been read. It keeps the first error other than io.EOF.
+type cappedReader struct {
+	r    io.Reader
+	max  int64
+	read int64
+	err  error
+}
+
+func (c *cappedReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.read += int64(n)
+	if c.read > c.max {
+		err = fmt.Errorf("%w: over %d bytes", ErrBodyTooLarge, c.max)
+	}
+	if err != nil && err != io.EOF && c.err == nil {
+		c.err = err
+	}
+	return n, err
+}
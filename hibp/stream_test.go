@@ -0,0 +1,278 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestStreamCount(t *testing.T) {
+	filler := strings.Repeat("FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF:1\n", 100)
+
+	testCases := []struct {
+		name  string
+		ctype string
+		body  string
+		opts  []func(*Finder)
+		exp   int64
+		xErr  error
+	}{
+		{
+			"found",
+			"text/plain",
+			data,
+			nil,
+			401,
+			nil,
+		},
+		{
+			"missing",
+			"",
+			filler,
+			nil,
+			0,
+			nil,
+		},
+		{
+			"html",
+			"",
+			"\n  <html>" + data,
+			nil,
+			0,
+			ErrUnexpectedContent,
+		},
+		{
+			"too large",
+			"",
+			filler + data,
+			[]func(*Finder){WithMaxBodySize(1000)},
+			0,
+			ErrBodyTooLarge,
+		},
+		{
+			"found before too large",
+			"",
+			data + filler,
+			[]func(*Finder){WithMaxBodySize(1000)},
+			401,
+			nil,
+		},
+		{
+			"line too long",
+			"",
+			strings.Repeat("A", 300) + "\n" + data,
+			nil,
+			0,
+			ErrLineTooLong,
+		},
+		{
+			"bad count",
+			"",
+			"012A7CA357541F0AC487871FEEC1891C49C:many\n",
+			nil,
+			0,
+			ErrInvalidCount,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tc.ctype != "" {
+					w.Header().Set("Content-Type", tc.ctype)
+				}
+				w.Write([]byte(tc.body))
+			}))
+			defer ts.Close()
+
+			opts := append([]func(*Finder){
+				WithClient(ts.Client()),
+				WithURLTemplate(fmt.Sprintf("%s/%%s", ts.URL)),
+			}, tc.opts...)
+			f := NewFinder(opts...)
+			n, err := f.FindPassword("melobie")
+			if !errors.Is(err, tc.xErr) {
+				t.Errorf("expected %v: %v\n", tc.xErr, err)
+			}
+			if n != tc.exp {
+				t.Errorf("expected %d: %d\n", tc.exp, n)
+			}
+		})
+	}
+}
+
+func TestStreamCountReusesConnections(t *testing.T) {
+	var conns int32
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The match is on the first line, well before the end.
+		w.Write([]byte(data))
+		w.Write([]byte(strings.Repeat("FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF:1\n", 1000)))
+	}))
+	ts.Config.ConnState = func(c net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&conns, 1)
+		}
+	}
+	ts.Start()
+	defer ts.Close()
+
+	f := NewFinder(
+		WithClient(ts.Client()),
+		WithURLTemplate(fmt.Sprintf("%s/%%s", ts.URL)),
+	)
+	for i := 0; i < 3; i++ {
+		if n, err := f.FindPassword("melobie"); n != 401 || err != nil {
+			t.Fatalf("expected [401, nil]: %d, %v\n", n, err)
+		}
+	}
+	if got := atomic.LoadInt32(&conns); got != 1 {
+		t.Errorf("expected 1 connection: %d\n", got)
+	}
+}
+
+func TestStreamCountAllocations(t *testing.T) {
+	body := []byte(data + strings.Repeat("FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF:1\n", 20000))
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(body)
+	}))
+	defer ts.Close()
+
+	allocated := func(f *Finder) uint64 {
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		for i := 0; i < 5; i++ {
+			if n, err := f.FindPassword("melobie"); n != 401 || err != nil {
+				t.Fatalf("expected [401, nil]: %d, %v\n", n, err)
+			}
+		}
+		runtime.ReadMemStats(&after)
+		return after.TotalAlloc - before.TotalAlloc
+	}
+
+	streamed := allocated(NewFinder(
+		WithClient(ts.Client()),
+		WithURLTemplate(fmt.Sprintf("%s/%%s", ts.URL)),
+	))
+	// A cache that never keeps anything, so every lookup reads a body.
+	buffered := allocated(NewFinder(
+		WithClient(ts.Client()),
+		WithURLTemplate(fmt.Sprintf("%s/%%s", ts.URL)),
+		WithCache(NewMemoryCache(0, time.Hour)),
+	))
+	if streamed*4 > buffered {
+		t.Errorf("expected streaming to allocate far less: %d vs %d bytes\n", streamed, buffered)
+	}
+}
+
+func BenchmarkStreamCount(b *testing.B) {
+	// About the size of a SHA1 range from the API.
+	body := []byte(data + strings.Repeat("FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF:1\n", 900))
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(body)
+	}))
+	defer ts.Close()
+
+	benchmarks := []struct {
+		name    string
+		options []func(*Finder)
+	}{
+		{"streamed", nil},
+		// A cache that never keeps anything, so every lookup reads a body.
+		{"buffered", []func(*Finder){WithCache(NewMemoryCache(0, time.Hour))}},
+	}
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			f := NewFinder(append([]func(*Finder){
+				WithClient(ts.Client()),
+				WithURLTemplate(fmt.Sprintf("%s/%%s", ts.URL)),
+			}, bm.options...)...)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if n, err := f.FindPassword("melobie"); n != 401 || err != nil {
+					b.Fatalf("expected [401, nil]: %d, %v\n", n, err)
+				}
+			}
+		})
+	}
+}
+
+func TestStreamCountShared(t *testing.T) {
+	// lauragpe comes first, and melobie after a body's worth of filler.
+	body := []byte("0018A45C4D1DEF81644B54AB7F969B88D65:229\n" +
+		strings.Repeat("0FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF:1\n", 2000) +
+		"012A7CA357541F0AC487871FEEC1891C49C:401\n")
+	var calls int32
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		w.Write(body)
+	}))
+	defer ts.Close()
+
+	f := NewFinder(
+		WithClient(ts.Client()),
+		WithURLTemplate(fmt.Sprintf("%s/%%s", ts.URL)),
+	)
+	leader := make(chan int64)
+	go func() {
+		n, _ := f.FindPassword("lauragpe")
+		leader <- n
+	}()
+	for atomic.LoadInt32(&calls) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	follower := make(chan int64)
+	go func() {
+		n, _ := f.FindPassword("melobie")
+		follower <- n
+	}()
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+
+	if n := <-leader; n != 229 {
+		t.Errorf("expected 229: %d\n", n)
+	}
+	if n := <-follower; n != 401 {
+		t.Errorf("expected 401: %d\n", n)
+	}
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Errorf("expected 1 call: %d\n", got)
+	}
+
+	// Alone, a lookup stops reading at its line, and the next one makes a
+	// request of its own.
+	if n, err := f.FindPassword("lauragpe"); n != 229 || err != nil {
+		t.Errorf("expected [229, nil]: %d, %v\n", n, err)
+	}
+	if got := atomic.LoadInt32(&calls); got != 2 {
+		t.Errorf("expected 2 calls: %d\n", got)
+	}
+}
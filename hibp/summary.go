@@ -0,0 +1,210 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// Band groups pwned hashes by how many times they have been seen, as a
+// rough measure of how urgently they need dealing with.
+type Band string
+
+const (
+	// BandLow is for hashes seen 1 to 9 times.
+	BandLow Band = "low"
+	// BandMedium is for hashes seen 10 to 999 times.
+	BandMedium Band = "medium"
+	// BandHigh is for hashes seen 1,000 to 99,999 times.
+	BandHigh Band = "high"
+	// BandCritical is for hashes seen 100,000 times or more.
+	BandCritical Band = "critical"
+)
+
+// BandOf returns the Band for a hash seen count times, or an empty Band
+// for one never seen.
+func BandOf(count int64) Band {
+	switch {
+	case count <= 0:
+		return ""
+	case count < 10:
+		return BandLow
+	case count < 1000:
+		return BandMedium
+	case count < 100000:
+		return BandHigh
+	}
+	return BandCritical
+}
+
+// Summary describes what a bulk job did, in a form that can be encoded as
+// JSON.
+type Summary struct {
+	// Token marks how far the job got; see ResumeToken.
+	Token ResumeToken `json:"token"`
+	// Inputs is the number of non-blank lines processed.
+	Inputs int64 `json:"inputs"`
+	// Unique is the number of those that were looked up with the Finder.
+	Unique int64 `json:"unique"`
+	// Duplicates is the number answered from an earlier line of the same
+	// job instead; see WithDedup.
+	Duplicates int64 `json:"duplicates"`
+	// Pwned is the number of inputs seen more often than the Finder's
+	// threshold (see WithThreshold), and Bands breaks them down by Band.
+	Pwned int64          `json:"pwned"`
+	Bands map[Band]int64 `json:"bands,omitempty"`
+	// Errors counts the inputs that failed, by ErrorCode. The error that
+	// stopped the job, if any, is returned rather than counted.
+	Errors map[ErrorCode]int64 `json:"errors,omitempty"`
+	// Duration is how long the job ran for.
+	Duration time.Duration `json:"duration_ns"`
+	// Requests is the number of range requests the Finder made while the
+	// job ran, retries included. If the Finder was also used for other
+	// things meanwhile, their requests are counted too.
+	Requests int64 `json:"requests"`
+	// CacheHits is the number of Unique inputs answered without a request.
+	CacheHits int64 `json:"cache_hits"`
+}
+
+// HitRate returns the share of Unique inputs answered without a request,
+// from 0 to 1.
+func (s Summary) HitRate() float64 {
+	if s.Unique == 0 {
+		return 0
+	}
+	return float64(s.CacheHits) / float64(s.Unique)
+}
+
+// add counts res, the Result for a line of the job.
+func (s *Summary) add(res Result, dup bool, threshold int64) {
+	s.Inputs++
+	if dup {
+		s.Duplicates++
+	} else {
+		s.Unique++
+		if res.CacheHit {
+			s.CacheHits++
+		}
+	}
+	if res.Code != "" {
+		if s.Errors == nil {
+			s.Errors = map[ErrorCode]int64{}
+		}
+		s.Errors[res.Code]++
+		return
+	}
+	if res.Count > threshold {
+		if s.Bands == nil {
+			s.Bands = map[Band]int64{}
+		}
+		s.Pwned++
+		s.Bands[BandOf(res.Count)]++
+	}
+}
+
+// requestCount returns the number of range requests made so far.
+func (f *Finder) requestCount() uint64 {
+	return atomic.LoadUint64(&f.requests)
+}
+
+// resultRow is a Result as written by a ResultWriter.
+type resultRow struct {
+	Input string    `json:"input"`
+	Count int64     `json:"count"`
+	Code  ErrorCode `json:"code,omitempty"`
+	Error string    `json:"error,omitempty"`
+}
+
+// ResultWriter writes Results, and the Summary of the job they came from,
+// in a Format: for FormatCSV, an "input,count,code,error" header and a row
+// per Result, and for FormatJSONL, an object per Result with those fields.
+// Its Write method fits the fn argument of BulkChecker.Run. It is not
+// safe for concurrent use.
+type ResultWriter struct {
+	csv  *csv.Writer
+	json *json.Encoder
+	w    io.Writer
+}
+
+// NewResultWriter returns a ResultWriter writing to w in the given format.
+func NewResultWriter(w io.Writer, format Format) (*ResultWriter, error) {
+	rw := &ResultWriter{w: w}
+	switch format {
+	case FormatCSV:
+		rw.csv = csv.NewWriter(w)
+		if err := rw.csv.Write([]string{"input", "count", "code", "error"}); err != nil {
+			return nil, err
+		}
+	case FormatJSONL:
+		rw.json = json.NewEncoder(w)
+	default:
+		return nil, fmt.Errorf("hibp: unknown result format %d", format)
+	}
+	return rw, nil
+}
+
+// Write writes res.
+func (rw *ResultWriter) Write(res Result) error {
+	row := resultRow{Input: res.Input, Count: res.Count, Code: res.Code}
+	if res.Err != nil {
+		row.Error = res.Err.Error()
+	}
+	if rw.json != nil {
+		return rw.json.Encode(row)
+	}
+	return rw.csv.Write([]string{row.Input, strconv.FormatInt(row.Count, 10), string(row.Code), row.Error})
+}
+
+// WriteSummary writes s after the Results, and flushes. For FormatJSONL it
+// is an object with just a "summary" field; for FormatCSV it is a comment
+// line, "# summary " followed by the same JSON, which a csv.Reader with
+// Comment set to '#' skips.
+func (rw *ResultWriter) WriteSummary(s Summary) error {
+	if rw.json != nil {
+		return rw.json.Encode(struct {
+			Summary Summary `json:"summary"`
+		}{s})
+	}
+	if err := rw.Flush(); err != nil {
+		return err
+	}
+	b, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(rw.w, "# summary %s\n", b)
+	return err
+}
+
+// Flush writes out anything buffered.
+func (rw *ResultWriter) Flush() error {
+	if rw.csv == nil {
+		return nil
+	}
+	rw.csv.Flush()
+	return rw.csv.Error()
+}
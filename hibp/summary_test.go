@@ -0,0 +1,207 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBandOf(t *testing.T) {
+	testCases := []struct {
+		count int64
+		exp   Band
+	}{
+		{0, ""},
+		{1, BandLow},
+		{9, BandLow},
+		{10, BandMedium},
+		{999, BandMedium},
+		{1000, BandHigh},
+		{99999, BandHigh},
+		{100000, BandCritical},
+		{3861493, BandCritical},
+	}
+
+	for _, tc := range testCases {
+		t.Run(fmt.Sprint(tc.count), func(t *testing.T) {
+			if got := BandOf(tc.count); got != tc.exp {
+				t.Errorf("expected %q: %q\n", tc.exp, got)
+			}
+		})
+	}
+}
+
+func TestRunSummary(t *testing.T) {
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Write([]byte(data))
+	}))
+	defer ts.Close()
+
+	b := NewBulkChecker(NewFinder(
+		WithClient(ts.Client()),
+		WithURLTemplate(fmt.Sprintf("%s/%%s", ts.URL)),
+		WithCache(NewMemoryCache(10, time.Hour)),
+		WithThreshold(300),
+	))
+	input := strings.Join([]string{
+		hexSum("melobie"),
+		hexSum("lauragpe"),
+		hexSum("melobie"),
+		"not a hash",
+		hexSum("gonna-miss"),
+	}, "\n")
+	sum, err := b.RunSummary(context.Background(), strings.NewReader(input), ResumeToken{}, func(Result) error {
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected: %v\n", err)
+	}
+
+	if sum.Inputs != 5 || sum.Unique != 5 || sum.Duplicates != 0 {
+		t.Errorf("expected 5 unique inputs: %+v\n", sum)
+	}
+	// lauragpe, at 229, is under the threshold.
+	if sum.Pwned != 2 || fmt.Sprint(sum.Bands) != "map[medium:2]" {
+		t.Errorf("expected 2 medium: %d %v\n", sum.Pwned, sum.Bands)
+	}
+	if fmt.Sprint(sum.Errors) != "map[invalid_input:1]" {
+		t.Errorf("expected 1 invalid input: %v\n", sum.Errors)
+	}
+	if got := atomic.LoadInt32(&calls); sum.Requests != int64(got) {
+		t.Errorf("expected %d requests: %d\n", got, sum.Requests)
+	}
+	// melobie and lauragpe share a range.
+	if sum.CacheHits != 2 {
+		t.Errorf("expected 2 cache hits: %d\n", sum.CacheHits)
+	}
+	if got := sum.HitRate(); got != 0.4 {
+		t.Errorf("expected 0.4: %v\n", got)
+	}
+	if sum.Duration < 0 {
+		t.Errorf("expected a duration: %v\n", sum.Duration)
+	}
+}
+
+func TestSummaryHitRateEmpty(t *testing.T) {
+	if got := (Summary{}).HitRate(); got != 0 {
+		t.Errorf("expected 0: %v\n", got)
+	}
+}
+
+func TestResultWriter(t *testing.T) {
+	results := []Result{
+		{Input: "one", Count: 401},
+		{Input: "two, quoted", Count: 0},
+		{Input: "three", Code: CodeInvalidInput, Err: fmt.Errorf("bad input")},
+	}
+	sum := Summary{Inputs: 3, Unique: 3, Pwned: 1, Bands: map[Band]int64{BandMedium: 1}}
+
+	t.Run("csv", func(t *testing.T) {
+		var buf bytes.Buffer
+		rw, err := NewResultWriter(&buf, FormatCSV)
+		if err != nil {
+			t.Fatalf("unexpected: %v\n", err)
+		}
+		for _, res := range results {
+			if err := rw.Write(res); err != nil {
+				t.Fatalf("unexpected: %v\n", err)
+			}
+		}
+		if err := rw.WriteSummary(sum); err != nil {
+			t.Fatalf("unexpected: %v\n", err)
+		}
+
+		r := csv.NewReader(bytes.NewReader(buf.Bytes()))
+		r.Comment = '#'
+		rows, err := r.ReadAll()
+		if err != nil {
+			t.Fatalf("unexpected: %v\n", err)
+		}
+		exp := `[[input count code error] [one 401  ] [two, quoted 0  ] [three 0 invalid_input bad input]]`
+		if got := fmt.Sprint(rows); got != exp {
+			t.Errorf("expected %s: %s\n", exp, got)
+		}
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		last := lines[len(lines)-1]
+		if !strings.HasPrefix(last, "# summary ") {
+			t.Fatalf("expected a summary comment: %q\n", last)
+		}
+		var got Summary
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(last, "# summary ")), &got); err != nil {
+			t.Fatalf("unexpected: %v\n", err)
+		}
+		if got.Pwned != 1 || got.Bands[BandMedium] != 1 {
+			t.Errorf("expected %+v: %+v\n", sum, got)
+		}
+	})
+
+	t.Run("jsonl", func(t *testing.T) {
+		var buf bytes.Buffer
+		rw, err := NewResultWriter(&buf, FormatJSONL)
+		if err != nil {
+			t.Fatalf("unexpected: %v\n", err)
+		}
+		for _, res := range results {
+			if err := rw.Write(res); err != nil {
+				t.Fatalf("unexpected: %v\n", err)
+			}
+		}
+		if err := rw.WriteSummary(sum); err != nil {
+			t.Fatalf("unexpected: %v\n", err)
+		}
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		exp := []string{
+			`{"input":"one","count":401}`,
+			`{"input":"two, quoted","count":0}`,
+			`{"input":"three","count":0,"code":"invalid_input","error":"bad input"}`,
+		}
+		if len(lines) != 4 || fmt.Sprint(lines[:3]) != fmt.Sprint(exp) {
+			t.Fatalf("expected %q: %q\n", exp, lines)
+		}
+		var got struct{ Summary Summary }
+		if err := json.Unmarshal([]byte(lines[3]), &got); err != nil {
+			t.Fatalf("unexpected: %v\n", err)
+		}
+		if got.Summary.Inputs != 3 || got.Summary.Bands[BandMedium] != 1 {
+			t.Errorf("expected %+v: %+v\n", sum, got.Summary)
+		}
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		if _, err := NewResultWriter(&bytes.Buffer{}, Format(9)); err == nil {
+			t.Errorf("expected an error\n")
+		}
+	})
+}
@@ -0,0 +1,51 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import "errors"
+
+// WithThreshold sets how many sightings IsPwned tolerates. A password
+// seen more than n times is considered pwned; the default of zero rejects
+// any sighting at all.
+func WithThreshold(n int64) func(f *Finder) {
+	return func(f *Finder) {
+		f.threshold = n
+	}
+}
+
+// Compromised reports whether the given sha1.Sum() has been seen in
+// breaches more than threshold times. A hash on the Finder's Blocklist is
+// compromised whatever its count, as it is Pwned to Verify.
+func (f *Finder) Compromised(sum []byte, threshold int64) (bool, error) {
+	n, err := f.Find(sum)
+	if errors.Is(err, ErrBlocklisted) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return n > threshold, nil
+}
+
+// IsPwned is Compromised with the threshold set by WithThreshold.
+func (f *Finder) IsPwned(sum []byte) (bool, error) {
+	return f.Compromised(sum, f.threshold)
+}
@@ -0,0 +1,109 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCompromised(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(data))
+	}))
+	defer ts.Close()
+
+	testCases := []struct {
+		name      string
+		pwd       string
+		threshold int64
+		exp       bool
+	}{
+		{
+			"any sighting",
+			"lauragpe",
+			0,
+			true,
+		},
+		{
+			"under",
+			"lauragpe",
+			500,
+			false,
+		},
+		{
+			"at",
+			"lauragpe",
+			229,
+			false,
+		},
+		{
+			"over",
+			"lauragpe",
+			228,
+			true,
+		},
+		{
+			"unseen",
+			"gonna-miss",
+			0,
+			false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			f := NewFinder(
+				WithClient(ts.Client()),
+				WithURLTemplate(fmt.Sprintf("%s/%%s", ts.URL)),
+				WithThreshold(tc.threshold),
+			)
+			h := sha1.Sum([]byte(tc.pwd))
+
+			got, err := f.Compromised(h[:], tc.threshold)
+			if got != tc.exp || err != nil {
+				t.Errorf("expected [%t, nil]: %t, %v\n", tc.exp, got, err)
+			}
+			got, err = f.IsPwned(h[:])
+			if got != tc.exp || err != nil {
+				t.Errorf("expected [%t, nil]: %t, %v\n", tc.exp, got, err)
+			}
+		})
+	}
+
+	f := NewFinder()
+	got, err := f.IsPwned([]byte("short"))
+	if got || !errors.Is(err, ErrInvalidDigestLength) {
+		t.Errorf("expected [false, %v]: %t, %v\n", ErrInvalidDigestLength, got, err)
+	}
+
+	// Blocklisted hashes are compromised without a lookup.
+	f = NewFinder(WithBlocklist(NewBlocklist("gonna-miss")), WithURLTemplate("http://127.0.0.1:0/%s"))
+	h := sha1.Sum([]byte("gonna-miss"))
+	got, err = f.Compromised(h[:], 1000)
+	if !got || err != nil {
+		t.Errorf("expected [true, nil]: %t, %v\n", got, err)
+	}
+}
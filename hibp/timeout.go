@@ -0,0 +1,58 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"net/http"
+	"time"
+)
+
+// DefaultTimeout bounds each request made with the client a Finder or
+// BreachClient uses when none is given.
+const DefaultTimeout = 30 * time.Second
+
+// defaultClient is shared by Finders and BreachClients not given a client
+// of their own. Unlike http.DefaultClient it has a timeout, so a backend
+// that stops responding can't hang a lookup with no deadline forever. It
+// uses http.DefaultTransport, for its dial and TLS handshake timeouts.
+var defaultClient = &http.Client{Timeout: DefaultTimeout}
+
+// WithTimeout bounds each request to the backend, from dialing to reading
+// the end of the body, to d, in place of DefaultTimeout or the timeout of
+// the client given to WithClient (which is copied, not changed). Retries
+// are separate requests, each with the full timeout; use a context
+// deadline to bound a lookup as a whole.
+func WithTimeout(d time.Duration) func(f *Finder) {
+	return func(f *Finder) {
+		f.timeout = d
+	}
+}
+
+// withTimeout returns client, or a copy of it with the timeout d if that's
+// positive.
+func withTimeout(client *http.Client, d time.Duration) *http.Client {
+	if d <= 0 {
+		return client
+	}
+	c := *client
+	c.Timeout = d
+	return &c
+}
@@ -0,0 +1,81 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDefaultClient(t *testing.T) {
+	f := NewFinder()
+	if f.conn == http.DefaultClient || f.conn.Timeout != DefaultTimeout {
+		t.Errorf("expected a client with a %v timeout: %v\n", DefaultTimeout, f.conn.Timeout)
+	}
+	if c := NewBreachClient(); c.conn.Timeout != DefaultTimeout {
+		t.Errorf("expected a client with a %v timeout: %v\n", DefaultTimeout, c.conn.Timeout)
+	}
+}
+
+func TestWithTimeout(t *testing.T) {
+	done := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-done:
+		case <-r.Context().Done():
+		}
+	}))
+	defer ts.Close()
+	defer close(done)
+
+	client := ts.Client()
+	f := NewFinder(
+		WithClient(client),
+		WithURLTemplate(fmt.Sprintf("%s/%%s", ts.URL)),
+		WithTimeout(20*time.Millisecond),
+		WithMaxAttempts(1),
+	)
+	if client.Timeout != 0 {
+		t.Errorf("expected the given client unchanged: %v\n", client.Timeout)
+	}
+
+	start := time.Now()
+	_, err := f.Find(make([]byte, 20))
+	var ne net.Error
+	if !errors.As(err, &ne) || !ne.Timeout() {
+		t.Errorf("expected a timeout: %v\n", err)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("expected to give up quickly: %v\n", d)
+	}
+}
+
+func TestWithTimeoutServerless(t *testing.T) {
+	f := NewFinder(WithServerless(), WithTimeout(time.Second))
+	if f.conn.Timeout != time.Second || f.conn.Transport != serverlessClient.Transport {
+		t.Errorf("expected the shared transport with a %v timeout: %v\n", time.Second, f.conn.Timeout)
+	}
+}
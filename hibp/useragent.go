@@ -0,0 +1,75 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"context"
+	"net/http"
+)
+
+// Version is the version of this package.
+const Version = "0.1.0"
+
+// DefaultUserAgent is the User-Agent sent with requests unless
+// WithUserAgent or WithBreachUserAgent replaces it.
+const DefaultUserAgent = "go-hibp/" + Version
+
+// WithUserAgent replaces DefaultUserAgent as the User-Agent sent with range
+// requests. The service asks that it describe the calling application,
+// such as "acme-signup/1.2".
+func WithUserAgent(ua string) func(f *Finder) {
+	return func(f *Finder) {
+		f.userAgent = ua
+	}
+}
+
+type userAgentKey struct{}
+
+// ContextWithUserAgent returns a copy of ctx that adds component (such as
+// the name of the calling service) to the User-Agent of requests made with
+// it. This lets shared gateways attribute upstream traffic to internal
+// consumers. Components added by nested calls are appended in order.
+func ContextWithUserAgent(ctx context.Context, component string) context.Context {
+	if component == "" {
+		return ctx
+	}
+	if prev := userAgentComponents(ctx); prev != "" {
+		component = prev + " " + component
+	}
+	return context.WithValue(ctx, userAgentKey{}, component)
+}
+
+func userAgentComponents(ctx context.Context) string {
+	s, _ := ctx.Value(userAgentKey{}).(string)
+	return s
+}
+
+// setUserAgent sets the User-Agent header of req to ua, or DefaultUserAgent
+// if it is empty, followed by any components added to ctx.
+func setUserAgent(ctx context.Context, req *http.Request, ua string) {
+	if ua == "" {
+		ua = DefaultUserAgent
+	}
+	if c := userAgentComponents(ctx); c != "" {
+		ua += " " + c
+	}
+	req.Header.Set("User-Agent", ua)
+}
@@ -0,0 +1,124 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestContextWithUserAgent(t *testing.T) {
+	var got string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.UserAgent()
+		w.Write([]byte(data))
+	}))
+	defer ts.Close()
+
+	testCases := []struct {
+		name       string
+		ua         string
+		components []string
+		exp        string
+	}{
+		{
+			"none",
+			"",
+			nil,
+			DefaultUserAgent,
+		},
+		{
+			"empty",
+			"",
+			[]string{""},
+			DefaultUserAgent,
+		},
+		{
+			"one",
+			"",
+			[]string{"signup-service"},
+			DefaultUserAgent + " signup-service",
+		},
+		{
+			"nested",
+			"",
+			[]string{"gateway/2.1", "", "signup-service"},
+			DefaultUserAgent + " gateway/2.1 signup-service",
+		},
+		{
+			"option",
+			"acme-signup/1.2",
+			nil,
+			"acme-signup/1.2",
+		},
+		{
+			"option and context",
+			"acme-signup/1.2",
+			[]string{"gateway/2.1"},
+			"acme-signup/1.2 gateway/2.1",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			f := NewFinder(
+				WithClient(ts.Client()),
+				WithURLTemplate(fmt.Sprintf("%s/%%s", ts.URL)),
+				WithUserAgent(tc.ua),
+			)
+			ctx := context.Background()
+			for _, c := range tc.components {
+				ctx = ContextWithUserAgent(ctx, c)
+			}
+			if _, err := f.fetchPrefix(ctx, SHA1, []byte("21BD1")); err != nil {
+				t.Fatalf("unexpected: %v\n", err)
+			}
+			if got != tc.exp {
+				t.Errorf("expected %q: %q\n", tc.exp, got)
+			}
+		})
+	}
+}
+
+func TestWithBreachUserAgent(t *testing.T) {
+	var got string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.UserAgent()
+		w.Write([]byte(`["Email addresses"]`))
+	}))
+	defer ts.Close()
+
+	c := NewBreachClient(
+		WithBreachBaseURL(ts.URL),
+		WithBreachHTTPClient(ts.Client()),
+		WithBreachUserAgent("acme-audit/1.2"),
+	)
+	ctx := ContextWithUserAgent(context.Background(), "nightly")
+	if _, err := c.DataClasses(ctx); err != nil {
+		t.Fatalf("unexpected: %v\n", err)
+	}
+	if exp := "acme-audit/1.2 nightly"; got != exp {
+		t.Errorf("expected %q: %q\n", exp, got)
+	}
+}
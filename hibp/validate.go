@@ -0,0 +1,101 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// ErrLineCount is wrapped by the error ValidateRangeBody returns for a
+// range with an implausible number of entries.
+var ErrLineCount = errors.New("hibp: unexpected number of range entries")
+
+// RangeBounds is the number of real (not padding) entries a range is
+// expected to hold. A Max of zero means there is no upper bound.
+type RangeBounds struct {
+	Min int
+	Max int
+}
+
+// DefaultRangeBounds are the bounds documented for the API: every one of the
+// 16^5 prefixes has at least 381 and at most 584 entries. They only hold
+// for the corpus they were documented for; as it grows, supply bounds that
+// match the data being validated.
+var DefaultRangeBounds = RangeBounds{Min: 381, Max: 584}
+
+// ValidateRangeBody checks that body looks like a complete range response:
+// every line is a hex suffix (all of the same length) and a count, and the
+// number of entries, not counting padding, is within bounds. It returns that
+// number of entries.
+//
+// It is meant for mirrors and health checks verifying the data they serve;
+// Find does not need it.
+func ValidateRangeBody(body []byte, bounds RangeBounds) (int, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 0, 4096), DefaultMaxLineLength+2)
+	entries := 0
+	suffixLen := -1
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		i := bytes.Index(line, delim)
+		if i <= 0 || !isHex(line[:i]) {
+			return entries, fmt.Errorf("%w: line %d: %q", ErrMalformedLine, lineNo, line)
+		}
+		if suffixLen < 0 {
+			suffixLen = i
+		} else if i != suffixLen {
+			return entries, fmt.Errorf("%w: line %d: suffix of %d digits, expected %d", ErrMalformedLine, lineNo, i, suffixLen)
+		}
+		if _, err := parseCount(line); err != nil {
+			return entries, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		if !isPadding(line) {
+			entries++
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		if err == bufio.ErrTooLong {
+			return entries, fmt.Errorf("%w: over %d bytes", ErrLineTooLong, DefaultMaxLineLength)
+		}
+		return entries, err
+	}
+	if entries < bounds.Min || (bounds.Max > 0 && entries > bounds.Max) {
+		return entries, fmt.Errorf("%w: %d not in [%d, %d]", ErrLineCount, entries, bounds.Min, bounds.Max)
+	}
+	return entries, nil
+}
+
+func isHex(b []byte) bool {
+	for _, c := range b {
+		switch {
+		case '0' <= c && c <= '9', 'A' <= c && c <= 'F', 'a' <= c && c <= 'f':
+		default:
+			return false
+		}
+	}
+	return true
+}
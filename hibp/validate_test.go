@@ -0,0 +1,114 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidateRangeBody(t *testing.T) {
+	loose := RangeBounds{Min: 1}
+
+	testCases := []struct {
+		name    string
+		body    string
+		bounds  RangeBounds
+		entries int
+		xErr    error
+	}{
+		{
+			"sample",
+			data,
+			loose,
+			5,
+			nil,
+		},
+		{
+			"padded",
+			paddedData,
+			loose,
+			1,
+			nil,
+		},
+		{
+			"crlf",
+			"0018A45C4D1DEF81644B54AB7F969B88D65:229\r\n0E8B0DBE9C2C1A2F7D7A05A1B1A3BDD2A1F:3\r\n",
+			RangeBounds{Min: 2, Max: 2},
+			2,
+			nil,
+		},
+		{
+			"too few",
+			data,
+			DefaultRangeBounds,
+			5,
+			ErrLineCount,
+		},
+		{
+			"too many",
+			data,
+			RangeBounds{Min: 1, Max: 4},
+			5,
+			ErrLineCount,
+		},
+		{
+			"html",
+			"<html>\n</html>\n",
+			loose,
+			0,
+			ErrMalformedLine,
+		},
+		{
+			"mixed suffix lengths",
+			"0018A45C4D1DEF81644B54AB7F969B88D65:229\n7EAEE8FB117AD06BDD830B7586C:2\n",
+			loose,
+			1,
+			ErrMalformedLine,
+		},
+		{
+			"bad count",
+			"0018A45C4D1DEF81644B54AB7F969B88D65:lots\n",
+			loose,
+			0,
+			ErrInvalidCount,
+		},
+		{
+			"empty",
+			"",
+			loose,
+			0,
+			ErrLineCount,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			n, err := ValidateRangeBody([]byte(tc.body), tc.bounds)
+			if !errors.Is(err, tc.xErr) {
+				t.Errorf("expected %v: %v\n", tc.xErr, err)
+			}
+			if n != tc.entries {
+				t.Errorf("expected %d: %d\n", tc.entries, n)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"context"
+	"errors"
+)
+
+// Status is the outcome of a Verify: whether a hash is known to be
+// breached, known not to be, or couldn't be checked at all.
+type Status int
+
+const (
+	// Unknown means the hash couldn't be checked, because of a bad input
+	// or an unavailable backend. It is the zero Status, so a Verdict
+	// that was never filled in doesn't read as clean.
+	Unknown Status = iota
+	// Clean means the backend was asked, and has seen the hash no more
+	// often than the threshold allows.
+	Clean
+	// Pwned means the hash has been seen more often than the threshold
+	// allows, or is on the local Blocklist.
+	Pwned
+)
+
+func (s Status) String() string {
+	switch s {
+	case Clean:
+		return "clean"
+	case Pwned:
+		return "pwned"
+	}
+	return "unknown"
+}
+
+// Verdict is the outcome of Verify.
+type Verdict struct {
+	Status Status
+	// Count is the number of times the hash has been seen in breaches;
+	// zero unless the hash was actually looked up.
+	Count int64
+	// Reason classifies Err for an Unknown verdict, or is CodeBlocklisted
+	// for a hash that is Pwned because of the Blocklist. It is empty
+	// otherwise.
+	Reason ErrorCode
+	// Err is what kept the hash from being checked; it is nil unless the
+	// Status is Unknown. It is set even when AllowOnError, here or in
+	// WithKillSwitch, would have had Find report a zero count instead.
+	Err error
+}
+
+// unverifiedError carries a failure that lookup's caller answers with a
+// zero count, because the configured fallback says to.
+type unverifiedError struct {
+	err error
+}
+
+func (e *unverifiedError) Error() string { return e.err.Error() }
+func (e *unverifiedError) Unwrap() error { return e.err }
+
+// Verify looks up sum like FindContext, but tells a hash that was checked
+// and found clean apart from one that couldn't be checked, which Find
+// conflates under AllowOnError. The threshold is the one set by
+// WithThreshold.
+func (f *Finder) Verify(ctx context.Context, sum []byte) Verdict {
+	n, _, err := f.lookup(ctx, f.mode, sum)
+	var ue *unverifiedError
+	switch {
+	case errors.As(err, &ue):
+		return Verdict{Status: Unknown, Reason: Classify(ue.err), Err: ue.err}
+	case errors.Is(err, ErrBlocklisted):
+		return Verdict{Status: Pwned, Reason: CodeBlocklisted}
+	case err != nil:
+		return Verdict{Status: Unknown, Reason: Classify(err), Err: err}
+	case n > f.threshold:
+		return Verdict{Status: Pwned, Count: n}
+	}
+	return Verdict{Status: Clean, Count: n}
+}
@@ -0,0 +1,160 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"context"
+	"crypto/sha1"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestVerify(t *testing.T) {
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(data))
+	}))
+	defer up.Close()
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+
+	melobie := sha1.Sum([]byte("melobie"))
+	miss := sha1.Sum([]byte("gonna-miss"))
+	off := func() bool { return true }
+
+	testCases := []struct {
+		name   string
+		ts     *httptest.Server
+		sum    []byte
+		opts   []func(*Finder)
+		status Status
+		count  int64
+		reason ErrorCode
+	}{
+		{
+			"pwned",
+			up,
+			melobie[:],
+			nil,
+			Pwned,
+			401,
+			"",
+		},
+		{
+			"under threshold",
+			up,
+			melobie[:],
+			[]func(*Finder){WithThreshold(401)},
+			Clean,
+			401,
+			"",
+		},
+		{
+			"clean",
+			up,
+			miss[:],
+			nil,
+			Clean,
+			0,
+			"",
+		},
+		{
+			"bad input",
+			up,
+			miss[:10],
+			nil,
+			Unknown,
+			0,
+			CodeInvalidInput,
+		},
+		{
+			"blocklisted",
+			up,
+			miss[:],
+			[]func(*Finder){WithBlocklist(NewBlocklist("gonna-miss"))},
+			Pwned,
+			0,
+			CodeBlocklisted,
+		},
+		{
+			"backend down",
+			down,
+			miss[:],
+			nil,
+			Unknown,
+			0,
+			CodeBackendDown,
+		},
+		{
+			"backend down, allowed",
+			down,
+			miss[:],
+			[]func(*Finder){WithDegradedMode(AllowOnError)},
+			Unknown,
+			0,
+			CodeBackendDown,
+		},
+		{
+			"disabled, allowed",
+			up,
+			miss[:],
+			[]func(*Finder){WithKillSwitch(off, AllowOnError)},
+			Unknown,
+			0,
+			CodeDisabled,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := append([]func(*Finder){
+				WithClient(tc.ts.Client()),
+				WithURLTemplate(fmt.Sprintf("%s/%%s", tc.ts.URL)),
+			}, tc.opts...)
+			f := NewFinder(opts...)
+
+			v := f.Verify(context.Background(), tc.sum)
+			if v.Status != tc.status {
+				t.Errorf("expected %v: %v (%v)\n", tc.status, v.Status, v.Err)
+			}
+			if v.Count != tc.count {
+				t.Errorf("expected %d: %d\n", tc.count, v.Count)
+			}
+			if v.Reason != tc.reason {
+				t.Errorf("expected %q: %q\n", tc.reason, v.Reason)
+			}
+			if (v.Status == Unknown) != (v.Err != nil) {
+				t.Errorf("expected an error only for unknown: %v\n", v.Err)
+			}
+		})
+	}
+}
+
+func TestStatusString(t *testing.T) {
+	for s, exp := range map[Status]string{Unknown: "unknown", Clean: "clean", Pwned: "pwned"} {
+		if got := s.String(); got != exp {
+			t.Errorf("expected %q: %q\n", exp, got)
+		}
+	}
+}
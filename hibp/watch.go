@@ -0,0 +1,169 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BucketChange reports a change in a watched range, or a failure to check
+// one.
+type BucketChange struct {
+	// Prefix is the range's prefix, in upper case.
+	Prefix string
+	// Body is the new content of the range; nil if Err is set.
+	Body []byte
+	// Err is set if the range couldn't be checked this time round.
+	Err error
+}
+
+// BucketWatcher polls a set of ranges and reports when their content
+// changes, so that re-validation can target the hashes in those ranges
+// instead of re-auditing everything after each update of the corpus.
+//
+// Requests are conditional (If-None-Match, with the ETag of the last
+// response), so ranges that haven't changed cost little to check. For
+// backends without ETags, the content is compared instead, leaving out
+// padding (see WithPadding). Polls are made like lookups: retried, minding
+// the circuit breaker, and not at all while the kill switch is on, which
+// is reported as ErrDisabled.
+type BucketWatcher struct {
+	finder   *Finder
+	prefixes []string
+	interval time.Duration
+	fn       func(BucketChange)
+
+	mu   sync.Mutex // guards seen; held for the whole of Poll
+	seen map[string]bucketState
+}
+
+// bucketState is what's remembered about a range between polls.
+type bucketState struct {
+	etag   string
+	digest [sha256.Size]byte
+}
+
+// NewBucketWatcher watches the ranges of prefixes (5 hex digits, in either
+// case) through f, checking them every interval, and calls fn with each
+// change.
+func NewBucketWatcher(f *Finder, interval time.Duration, prefixes []string, fn func(BucketChange)) (*BucketWatcher, error) {
+	clean := make([]string, len(prefixes))
+	for i, p := range prefixes {
+		if len(p) != prefixSize || !isHex([]byte(p)) {
+			return nil, fmt.Errorf("%w: prefix %q", ErrInvalidHex, p)
+		}
+		clean[i] = strings.ToUpper(p)
+	}
+	return &BucketWatcher{
+		finder:   f,
+		prefixes: clean,
+		interval: interval,
+		fn:       fn,
+		seen:     map[string]bucketState{},
+	}, nil
+}
+
+// Poll checks every watched range once. The first time a range is
+// retrieved, its content is only recorded; changes are reported from the
+// next Poll on. Failures are reported to the callback too, and the range
+// is checked again next time.
+func (w *BucketWatcher) Poll(ctx context.Context) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, p := range w.prefixes {
+		if ctx.Err() != nil {
+			return
+		}
+		w.poll(ctx, p)
+	}
+}
+
+func (w *BucketWatcher) poll(ctx context.Context, prefix string) {
+	f := w.finder
+	prev, known := w.seen[prefix]
+	if f.killed != nil && f.killed() {
+		w.fn(BucketChange{Prefix: prefix, Err: ErrDisabled})
+		return
+	}
+	header := http.Header{}
+	if prev.etag != "" {
+		header.Set("If-None-Match", prev.etag)
+	}
+	var resp *http.Response
+	var body []byte
+	err := f.guarded(ctx, func() error {
+		var err error
+		resp, body, err = f.request(ctx, f.mode, prefix, header)
+		return err
+	})
+	if err == nil && body != nil {
+		err = checkContent(resp.Header.Get("Content-Type"), body)
+	}
+	if err != nil {
+		w.fn(BucketChange{Prefix: prefix, Err: err})
+		return
+	}
+	if resp.StatusCode == http.StatusNotModified {
+		return
+	}
+	state := bucketState{etag: resp.Header.Get("ETag"), digest: rangeDigest(body)}
+	w.seen[prefix] = state
+	if known && state.digest != prev.digest {
+		w.fn(BucketChange{Prefix: prefix, Body: body})
+	}
+}
+
+// rangeDigest hashes the entries of a range body, leaving out padding,
+// which is random and would make every response look like a change.
+func rangeDigest(body []byte) [sha256.Size]byte {
+	h := sha256.New()
+	for _, line := range bytes.Split(body, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 || isPadding(line) {
+			continue
+		}
+		h.Write(line)
+		h.Write([]byte("\n"))
+	}
+	var sum [sha256.Size]byte
+	h.Sum(sum[:0])
+	return sum
+}
+
+// Run polls right away and then every interval, until ctx is done, which
+// it returns the error of.
+func (w *BucketWatcher) Run(ctx context.Context) error {
+	for {
+		w.Poll(ctx)
+		select {
+		case <-w.finder.clock.After(w.interval):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,246 @@
+// Copyright © 2017 Nelz
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hibp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// bucketServer serves a version of each range that tests can bump, with
+// ETags if asked to.
+type bucketServer struct {
+	*httptest.Server
+	mu          sync.Mutex
+	versions    map[string]int
+	etags       bool
+	notModified int32
+}
+
+func newBucketServer(etags bool) *bucketServer {
+	s := &bucketServer{versions: map[string]int{}, etags: etags}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		prefix := r.URL.Path[1:]
+		s.mu.Lock()
+		v := s.versions[prefix]
+		s.mu.Unlock()
+		if v < 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if s.etags {
+			etag := fmt.Sprintf(`"%s-%d"`, prefix, v)
+			if r.Header.Get("If-None-Match") == etag {
+				atomic.AddInt32(&s.notModified, 1)
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("ETag", etag)
+		}
+		fmt.Fprintf(w, "0018A45C4D1DEF81644B54AB7F969B88D65:%d\n", 100+v)
+	}))
+	return s
+}
+
+func (s *bucketServer) set(prefix string, v int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.versions[prefix] = v
+}
+
+func TestBucketWatcherPoll(t *testing.T) {
+	for _, etags := range []bool{true, false} {
+		t.Run(fmt.Sprintf("etags %t", etags), func(t *testing.T) {
+			ts := newBucketServer(etags)
+			defer ts.Close()
+
+			f := NewFinder(
+				WithClient(ts.Client()),
+				WithURLTemplate(fmt.Sprintf("%s/%%s", ts.URL)),
+			)
+			var changes []BucketChange
+			w, err := NewBucketWatcher(f, time.Minute, []string{"21bd1", "FFFFF"}, func(c BucketChange) {
+				changes = append(changes, c)
+			})
+			if err != nil {
+				t.Fatalf("unexpected: %v\n", err)
+			}
+
+			ctx := context.Background()
+			w.Poll(ctx)
+			w.Poll(ctx)
+			if len(changes) != 0 {
+				t.Errorf("expected no changes: %v\n", changes)
+			}
+			exp := map[bool]int32{true: 2, false: 0}[etags]
+			if got := atomic.LoadInt32(&ts.notModified); got != exp {
+				t.Errorf("expected %d not modified: %d\n", exp, got)
+			}
+
+			ts.set("FFFFF", 1)
+			w.Poll(ctx)
+			if len(changes) != 1 {
+				t.Fatalf("expected 1 change: %v\n", changes)
+			}
+			if c := changes[0]; c.Prefix != "FFFFF" || c.Err != nil || string(c.Body) != "0018A45C4D1DEF81644B54AB7F969B88D65:101\n" {
+				t.Errorf("unexpected: %+v\n", c)
+			}
+
+			ts.set("21BD1", -1)
+			w.Poll(ctx)
+			if len(changes) != 2 || Classify(changes[1].Err) != CodeBackendDown {
+				t.Errorf("expected a failure: %v\n", changes)
+			}
+		})
+	}
+}
+
+func TestBucketWatcherInvalid(t *testing.T) {
+	_, err := NewBucketWatcher(NewFinder(), time.Minute, []string{"21BD1", "XYZ12"}, func(BucketChange) {})
+	if !errors.Is(err, ErrInvalidHex) {
+		t.Errorf("expected %v: %v\n", ErrInvalidHex, err)
+	}
+}
+
+func TestBucketWatcherRun(t *testing.T) {
+	ts := newBucketServer(true)
+	defer ts.Close()
+
+	clock := newFakeClock()
+	f := NewFinder(
+		WithClient(ts.Client()),
+		WithURLTemplate(fmt.Sprintf("%s/%%s", ts.URL)),
+		WithClock(clock),
+	)
+	changes := make(chan BucketChange, 1)
+	w, err := NewBucketWatcher(f, time.Minute, []string{"21BD1"}, func(c BucketChange) {
+		changes <- c
+	})
+	if err != nil {
+		t.Fatalf("unexpected: %v\n", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- w.Run(ctx) }()
+
+	for clock.pending() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	ts.set("21BD1", 1)
+	clock.Advance(time.Minute)
+	if c := <-changes; c.Prefix != "21BD1" {
+		t.Errorf("unexpected: %+v\n", c)
+	}
+
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("expected %v: %v\n", context.Canceled, err)
+	}
+}
+
+func TestBucketWatcherPadding(t *testing.T) {
+	var polls, version int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&polls, 1)
+		if r.Header.Get("Add-Padding") != "true" {
+			t.Errorf("expected padding asked for\n")
+		}
+		// Different padding, and so a different ETag, every time.
+		w.Header().Set("ETag", fmt.Sprintf(`"%d"`, n))
+		fmt.Fprintf(w, "0018A45C4D1DEF81644B54AB7F969B88D65:%d\n", 100+atomic.LoadInt32(&version))
+		fmt.Fprintf(w, "%035X:0\n", n)
+	}))
+	defer ts.Close()
+
+	var changes []BucketChange
+	w, err := NewBucketWatcher(NewFinder(
+		WithClient(ts.Client()),
+		WithURLTemplate(fmt.Sprintf("%s/%%s", ts.URL)),
+		WithPadding(true),
+	), time.Minute, []string{"21BD1"}, func(c BucketChange) {
+		changes = append(changes, c)
+	})
+	if err != nil {
+		t.Fatalf("unexpected: %v\n", err)
+	}
+	w.Poll(context.Background())
+	w.Poll(context.Background())
+	if len(changes) != 0 {
+		t.Errorf("expected padding not to count as a change: %v\n", changes)
+	}
+	atomic.StoreInt32(&version, 1)
+	w.Poll(context.Background())
+	if len(changes) != 1 || changes[0].Err != nil {
+		t.Errorf("expected 1 change: %v\n", changes)
+	}
+}
+
+func TestBucketWatcherGuarded(t *testing.T) {
+	var requests int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Every other request is throttled, and retried.
+		if atomic.AddInt32(&requests, 1)%2 == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		fmt.Fprint(w, data)
+	}))
+	defer ts.Close()
+
+	var off atomic.Bool
+	f := NewFinder(
+		WithClient(ts.Client()),
+		WithURLTemplate(fmt.Sprintf("%s/%%s", ts.URL)),
+		WithKillSwitch(off.Load, DenyOnError),
+	)
+	var changes []BucketChange
+	w, err := NewBucketWatcher(f, time.Minute, []string{"21BD1"}, func(c BucketChange) {
+		changes = append(changes, c)
+	})
+	if err != nil {
+		t.Fatalf("unexpected: %v\n", err)
+	}
+	w.Poll(context.Background())
+	if len(changes) != 0 {
+		t.Errorf("expected the throttled poll retried: %v\n", changes)
+	}
+	if got := f.requestCount(); got != 2 {
+		t.Errorf("expected 2 requests counted: %d\n", got)
+	}
+
+	off.Store(true)
+	w.Poll(context.Background())
+	if len(changes) != 1 || !errors.Is(changes[0].Err, ErrDisabled) {
+		t.Errorf("expected %v: %v\n", ErrDisabled, changes)
+	}
+	if got := atomic.LoadInt32(&requests); got != 2 {
+		t.Errorf("expected no request while disabled: %d\n", got)
+	}
+}